@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+const configUsage = `Usage:
+  aw config explain [--json] [profile]  Show the effective profile and where each field came from`
+
+func runConfig(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 1
+	}
+	switch args[0] {
+	case "explain":
+		return runConfigExplain(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown config command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 1
+	}
+}
+
+func runConfigExplain(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("config explain", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of annotated YAML")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	// Accept flags after the profile name too.
+	name := fs.Arg(0)
+	if fs.NArg() > 0 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 1
+		}
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: aw config explain [--json] [profile]")
+		return 1
+	}
+
+	cfg, err := profile.Load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if name == "" {
+		name = cfg.Default
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: no profile given and no default profile configured")
+		return 1
+	}
+
+	if err := explainProfile(os.Stdout, cfg, name, *asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func explainProfile(w io.Writer, cfg *profile.Config, name string, asJSON bool) error {
+	e, err := cfg.Explain(name)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		out, err := e.JSON()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	}
+
+	out, err := e.YAML()
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "# Effective profile %q\n", name); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
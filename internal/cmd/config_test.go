@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+func TestExplainProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".agent-workspace.yml")
+	content := `
+profiles:
+  dev:
+    environment: host
+    launch: shell
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := profile.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := explainProfile(&buf, cfg, "dev", false); err != nil {
+		t.Fatalf("explainProfile() error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# Effective profile \"dev\"\n") {
+		t.Errorf("output should start with a header, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "launch: shell # repo profile ("+path+")") {
+		t.Errorf("output missing annotated launch line:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := explainProfile(&buf, cfg, "dev", true); err != nil {
+		t.Fatalf("explainProfile(json) error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"profile": "dev"`) {
+		t.Errorf("JSON output missing profile name:\n%s", buf.String())
+	}
+
+	if err := explainProfile(&buf, cfg, "missing", false); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestRunConfig_Usage(t *testing.T) {
+	if code := runConfig(context.Background(), nil); code != 1 {
+		t.Errorf("runConfig(nil) = %d, want 1", code)
+	}
+	if code := runConfig(context.Background(), []string{"bogus"}); code != 1 {
+		t.Errorf("runConfig(bogus) = %d, want 1", code)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hiragram/agent-workspace/internal/doctor"
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+const doctorUsage = "Usage: aw doctor [profile]"
+
+func runDoctor(ctx context.Context, args []string) int {
+	if len(args) > 1 || (len(args) == 1 && (args[0] == "-h" || args[0] == "--help")) {
+		fmt.Fprintln(os.Stderr, doctorUsage)
+		return 1
+	}
+
+	cfg, err := profile.Load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if err := profile.ValidateConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	name := cfg.Default
+	if len(args) == 1 {
+		name = args[0]
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: no profile given and no default profile configured")
+		return 1
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: profile %q not found\n", name)
+		return 1
+	}
+
+	results := doctor.Check(ctx, p)
+	if !printDoctorReport(os.Stdout, name, p, results) {
+		return 1
+	}
+	return 0
+}
+
+// printDoctorReport prints one line per check, with a fix below each warning
+// or failure, and a summary. It returns false if any check failed.
+func printDoctorReport(w io.Writer, name string, p profile.Profile, results []doctor.Result) bool {
+	fmt.Fprintf(w, "Checking profile %q (%s)\n\n", name, describeProfile(p))
+
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Name))
+	}
+	var failed, warned int
+	for _, r := range results {
+		fmt.Fprintf(w, "  %-4s  %-*s  %s\n", r.Status, width, r.Name, r.Detail)
+		if r.Hint != "" {
+			fmt.Fprintf(w, "        %-*s  fix: %s\n", width, "", r.Hint)
+		}
+		switch r.Status {
+		case doctor.Fail:
+			failed++
+		case doctor.Warn:
+			warned++
+		}
+	}
+
+	fmt.Fprintln(w)
+	switch {
+	case failed > 0:
+		fmt.Fprintf(w, "%d failed, %d warning(s): profile %q cannot run until the failures are fixed.\n", failed, warned, name)
+	case warned > 0:
+		fmt.Fprintf(w, "%d warning(s): profile %q can run, but some features will not work.\n", warned, name)
+	default:
+		fmt.Fprintln(w, "All checks passed.")
+	}
+	return failed == 0
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/doctor"
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+func TestPrintDoctorReport(t *testing.T) {
+	p := profile.Profile{Environment: profile.EnvironmentDocker, Launch: profile.LaunchClaude}
+	results := []doctor.Result{
+		{Name: "git", Status: doctor.Pass, Detail: "git version 2.45.0"},
+		{Name: "docker", Status: doctor.Fail, Detail: "docker daemon is not running", Hint: "start Docker Desktop"},
+		{Name: "~/.claude", Status: doctor.Warn, Detail: "not found"},
+	}
+
+	var buf bytes.Buffer
+	if ok := printDoctorReport(&buf, "claude", p, results); ok {
+		t.Error("printDoctorReport() = true, want false with a failing check")
+	}
+	want := `Checking profile "claude" (docker + claude)
+
+  ok    git        git version 2.45.0
+  FAIL  docker     docker daemon is not running
+                   fix: start Docker Desktop
+  warn  ~/.claude  not found
+
+1 failed, 1 warning(s): profile "claude" cannot run until the failures are fixed.
+`
+	if buf.String() != want {
+		t.Errorf("report =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if ok := printDoctorReport(&buf, "claude", p, results[:1]); !ok || !strings.Contains(buf.String(), "All checks passed.") {
+		t.Errorf("report with only passes = %v\n%s", ok, buf.String())
+	}
+}
+
+func TestRunDoctor_Usage(t *testing.T) {
+	if code := runDoctor(context.Background(), []string{"a", "b"}); code != 1 {
+		t.Errorf("runDoctor(a, b) = %d, want 1", code)
+	}
+}
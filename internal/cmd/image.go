@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/stage"
+)
+
+const imageUsage = `Usage:
+  aw image rebuild [--no-cache] [profile]  Build the profile's Docker image even if it exists`
+
+func runImage(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, imageUsage)
+		return 1
+	}
+	switch args[0] {
+	case "rebuild":
+		return runImageRebuild(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown image command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, imageUsage)
+		return 1
+	}
+}
+
+func runImageRebuild(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("image rebuild", flag.ContinueOnError)
+	noCache := fs.Bool("no-cache", false, "do not use cached layers, e.g. to pick up newer packages")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	// Accept flags after the profile name too.
+	name := fs.Arg(0)
+	if fs.NArg() > 0 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 1
+		}
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: aw image rebuild [--no-cache] [profile]")
+		return 1
+	}
+
+	cfg, err := profile.Load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if err := profile.ValidateConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if name == "" {
+		name = cfg.Default
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: no profile given and no default profile configured")
+		return 1
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: profile %q not found\n", name)
+		return 1
+	}
+	if p.Environment != profile.EnvironmentDocker {
+		fmt.Fprintf(os.Stderr, "Error: profile %q does not run in docker\n", name)
+		return 1
+	}
+	if p.Image != "" {
+		fmt.Fprintf(os.Stderr, "Error: profile %q runs the prebuilt image %s; there is nothing to build\n", name, p.Image)
+		return 1
+	}
+
+	client, err := newDockerClient(string(p.Docker.EffectiveClient()), string(p.EffectiveRuntime()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	ec := &pipeline.ExecutionContext{Profile: p}
+	imageName, err := stage.RebuildImage(ctx, ec, client, *noCache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Built %s\n", imageName)
+	return 0
+}
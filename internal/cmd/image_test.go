@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunImage_Usage(t *testing.T) {
+	if code := runImage(context.Background(), nil); code != 1 {
+		t.Errorf("runImage(nil) = %d, want 1", code)
+	}
+	if code := runImage(context.Background(), []string{"bogus"}); code != 1 {
+		t.Errorf("runImage(bogus) = %d, want 1", code)
+	}
+	if code := runImage(context.Background(), []string{"rebuild", "a", "b"}); code != 1 {
+		t.Errorf("runImage(rebuild a b) = %d, want 1", code)
+	}
+}
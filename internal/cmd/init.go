@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/scaffold"
+	"github.com/hiragram/agent-workspace/internal/worktree"
+)
+
+const initUsage = `Usage: aw init [--yes] [--force]
+
+Inspect the repository and write a starter .agent-workspace.yml at its root.
+
+  --yes    accept the detected defaults without prompting
+  --force  overwrite an existing .agent-workspace.yml`
+
+func runInit(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, initUsage) }
+	yes := fs.Bool("yes", false, "accept the detected defaults without prompting")
+	fs.BoolVar(yes, "y", false, "shorthand for --yes")
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, initUsage)
+		return 1
+	}
+
+	repoRoot, err := worktree.RepoRoot(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := initWorkspace(os.Stdin, os.Stdout, repoRoot, *yes, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// initWorkspace inspects repoRoot, asks for the choices (or takes the
+// defaults when yes is set), and writes the config and .gitignore entries.
+func initWorkspace(in io.Reader, out io.Writer, repoRoot string, yes, force bool) error {
+	repo, err := scaffold.Inspect(repoRoot)
+	if err != nil {
+		return err
+	}
+	ask := &prompter{in: bufio.NewReader(in), out: out, yes: yes}
+
+	fmt.Fprintf(out, "Default branch: %s\n", repo.DefaultBase)
+	if found := detected(repo); len(found) > 0 {
+		fmt.Fprintf(out, "Detected: %s\n", strings.Join(found, ", "))
+	}
+	if repo.WorktreesDir != "" {
+		fmt.Fprintf(out, "worktrees/ is tracked by git; aw will use %s/ instead\n", repo.WorktreesDir)
+	}
+	fmt.Fprintln(out)
+
+	if repo.ConfigExists && !force {
+		if yes {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", scaffold.ConfigFileName)
+		}
+		if !ask.confirm(scaffold.ConfigFileName+" already exists. Overwrite?", false) {
+			return fmt.Errorf("not overwriting %s", scaffold.ConfigFileName)
+		}
+	}
+
+	opts := scaffold.DefaultOptions(repo)
+	opts.Base = ask.text("Base ref for new worktrees", opts.Base)
+	for {
+		opts.Default = ask.text("Default profile ("+strings.Join(scaffold.Profiles, ", ")+")", opts.Default)
+		if slices.Contains(scaffold.Profiles, opts.Default) {
+			break
+		}
+		fmt.Fprintf(out, "Unknown profile %q.\n", opts.Default)
+		opts.Default = scaffold.ProfileWorktreeZellij
+	}
+	if opts.OnCreate != "" {
+		opts.OnCreate = ask.text(`Command to run in new worktrees ("none" to skip)`, opts.OnCreate)
+		if opts.OnCreate == "none" {
+			opts.OnCreate = ""
+		}
+	}
+
+	data := scaffold.Render(repo, opts)
+	cfg, err := profile.Parse(data)
+	if err == nil {
+		err = profile.ValidateConfig(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+
+	missing, err := scaffold.MissingGitignoreEntries(repoRoot, repo.GitignoreEntries())
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 && !ask.confirm("Add "+strings.Join(missing, ", ")+" to .gitignore?", true) {
+		missing = nil
+	}
+
+	if err := os.WriteFile(filepath.Join(repoRoot, scaffold.ConfigFileName), data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", scaffold.ConfigFileName, err)
+	}
+	fmt.Fprintf(out, "Wrote %s\n", scaffold.ConfigFileName)
+	if len(missing) > 0 {
+		if err := scaffold.AppendGitignore(repoRoot, missing); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Added %s to .gitignore\n", strings.Join(missing, ", "))
+	}
+	fmt.Fprintf(out, "Run `aw` to start the %q profile, or `aw profiles` to list them.\n", opts.Default)
+	return nil
+}
+
+// detected describes what Inspect found, for the summary printed by aw init.
+func detected(r scaffold.Repo) []string {
+	var found []string
+	if r.Dockerfile != "" {
+		found = append(found, r.Dockerfile)
+	}
+	if r.Devcontainer {
+		found = append(found, ".devcontainer")
+	}
+	for _, pm := range r.PackageManagers {
+		found = append(found, pm.Name+" ("+pm.Marker+")")
+	}
+	return found
+}
+
+// prompter asks questions on in/out. With yes set, or once in is exhausted,
+// every question takes its default.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	yes bool
+}
+
+func (p *prompter) text(question, def string) string {
+	if p.yes {
+		return def
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out)
+		p.yes = true
+		return def
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(p.text(question, hint)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
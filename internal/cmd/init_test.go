@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestInitWorkspace_Yes(t *testing.T) {
+	dir := initTestRepo(t)
+	var out bytes.Buffer
+	if err := initWorkspace(strings.NewReader(""), &out, dir, true, false); err != nil {
+		t.Fatalf("initWorkspace() error: %v", err)
+	}
+
+	cfg, err := profile.LoadFile(filepath.Join(dir, ".agent-workspace.yml"))
+	if err != nil {
+		t.Fatalf("loading written config: %v", err)
+	}
+	if cfg.Default != "worktree-zellij" {
+		t.Errorf("Default = %q, want worktree-zellij", cfg.Default)
+	}
+	if got := cfg.Profiles["worktree-zellij"].Worktree.OnCreate; got != "npm ci" {
+		t.Errorf("on-create = %q, want npm ci", got)
+	}
+	gitignore, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	for _, e := range []string{"/worktrees/", ".aw-env", ".aw-profile-env", ".aw-prompt.md"} {
+		if !strings.Contains(string(gitignore), e+"\n") {
+			t.Errorf(".gitignore missing %s:\n%s", e, gitignore)
+		}
+	}
+	if strings.Contains(out.String(), "[") {
+		t.Errorf("--yes should not prompt:\n%s", out.String())
+	}
+
+	// A second run must not clobber the file without --force.
+	if err := initWorkspace(strings.NewReader(""), &out, dir, true, false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("second initWorkspace() error = %v, want a hint to use --force", err)
+	}
+	if err := initWorkspace(strings.NewReader(""), &out, dir, true, true); err != nil {
+		t.Errorf("initWorkspace(force) error: %v", err)
+	}
+	gitignore2, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if !bytes.Equal(gitignore, gitignore2) {
+		t.Errorf(".gitignore changed on re-run:\n%s", gitignore2)
+	}
+}
+
+func TestInitWorkspace_Interactive(t *testing.T) {
+	dir := initTestRepo(t)
+	// base ref, an unknown then a valid default profile, skip on-create, decline .gitignore
+	answers := "origin/develop\nbogus\nclaude\nnone\nn\n"
+	var out bytes.Buffer
+	if err := initWorkspace(strings.NewReader(answers), &out, dir, false, false); err != nil {
+		t.Fatalf("initWorkspace() error: %v", err)
+	}
+
+	cfg, err := profile.LoadFile(filepath.Join(dir, ".agent-workspace.yml"))
+	if err != nil {
+		t.Fatalf("loading written config: %v", err)
+	}
+	if cfg.Default != "claude" {
+		t.Errorf("Default = %q, want claude", cfg.Default)
+	}
+	wt := cfg.Profiles["worktree-shell"].Worktree
+	if wt.Base != "origin/develop" || wt.OnCreate != "" {
+		t.Errorf("worktree = %+v, want base origin/develop and no on-create", wt)
+	}
+	if !strings.Contains(out.String(), `Unknown profile "bogus"`) {
+		t.Errorf("output should reject the unknown profile:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); !os.IsNotExist(err) {
+		t.Errorf(".gitignore should not be written when declined (stat err = %v)", err)
+	}
+}
+
+func TestPrompter_EOFTakesDefaults(t *testing.T) {
+	var out bytes.Buffer
+	p := &prompter{in: bufio.NewReader(strings.NewReader("")), out: &out}
+	if got := p.text("Q", "def"); got != "def" {
+		t.Errorf("text() at EOF = %q, want def", got)
+	}
+	if !p.confirm("C", true) || p.confirm("C", false) {
+		t.Error("confirm() at EOF should return the default")
+	}
+}
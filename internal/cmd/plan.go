@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hiragram/agent-workspace/internal/launcher"
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+)
+
+// planReport is the output of `aw --dry-run`.
+type planReport struct {
+	Profile  string               `json:"profile"`
+	Stages   []pipeline.StagePlan `json:"stages"`
+	WorkDir  string               `json:"work_dir"`
+	Worktree *plannedWorktree     `json:"worktree,omitempty"`
+	Image    string               `json:"image,omitempty"`
+	Mounts   []plannedMount       `json:"mounts,omitempty"`
+	Env      map[string]string    `json:"env,omitempty"`
+	Command  []string             `json:"command,omitempty"` // what the launch stage runs last
+}
+
+type plannedWorktree struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch"`
+	Base   string `json:"base"`
+	Reused bool   `json:"reused,omitempty"` // an existing worktree is resumed
+}
+
+type plannedMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	Volume   bool   `json:"volume,omitempty"`
+}
+
+// newPlanReport summarises plans and the execution context they left behind.
+func newPlanReport(name string, ec *pipeline.ExecutionContext, plans []pipeline.StagePlan) planReport {
+	r := planReport{
+		Profile: name,
+		Stages:  plans,
+		WorkDir: ec.WorkDir,
+		Image:   ec.DockerImage,
+		Env:     ec.EnvVars,
+	}
+	if ec.WorktreePath != "" {
+		r.Worktree = &plannedWorktree{Path: ec.WorktreePath, Branch: ec.WorktreeBranch, Base: ec.WorktreeBase, Reused: ec.WorktreeReused}
+	}
+	for _, m := range ec.DockerMounts {
+		r.Mounts = append(r.Mounts, plannedMount{Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly, Volume: m.IsVolume})
+	}
+	if len(plans) > 0 {
+		actions := plans[len(plans)-1].Actions
+		for i := len(actions) - 1; i >= 0; i-- {
+			if len(actions[i].Command) > 0 {
+				r.Command = actions[i].Command
+				break
+			}
+		}
+	}
+	return r
+}
+
+func (r planReport) writeJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// print writes the plan stage by stage, followed by a summary.
+func (r planReport) print(w io.Writer, description string) {
+	fmt.Fprintf(w, "Dry run of profile %q (%s); nothing was changed.\n", r.Profile, description)
+
+	for _, sp := range r.Stages {
+		fmt.Fprintf(w, "\n[%s]\n", sp.Stage)
+		for _, a := range sp.Actions {
+			fmt.Fprintf(w, "  %s\n", a.Description)
+			if len(a.Command) > 0 {
+				fmt.Fprintf(w, "    $ %s\n", launcher.ShellJoin(a.Command))
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	if r.Worktree != nil && r.Worktree.Reused {
+		fmt.Fprintf(w, "Worktree: %s (existing branch %s)\n", r.Worktree.Path, orDash(r.Worktree.Branch))
+	} else if r.Worktree != nil {
+		fmt.Fprintf(w, "Worktree: %s (new branch %s from %s)\n", r.Worktree.Path, r.Worktree.Branch, r.Worktree.Base)
+	} else {
+		fmt.Fprintf(w, "Workdir:  %s\n", r.WorkDir)
+	}
+	if r.Image != "" {
+		fmt.Fprintf(w, "Image:    %s\n", r.Image)
+	}
+	if len(r.Mounts) > 0 {
+		fmt.Fprintln(w, "Mounts:")
+		for _, m := range r.Mounts {
+			line := m.Source + " -> " + m.Target
+			switch {
+			case m.Volume:
+				line += " (volume)"
+			case m.ReadOnly:
+				line += " (read-only)"
+			}
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	if len(r.Env) > 0 {
+		keys := make([]string, 0, len(r.Env))
+		for k := range r.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(w, "Env:")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s=%s\n", k, r.Env[k])
+		}
+	}
+	if len(r.Command) > 0 {
+		fmt.Fprintf(w, "Command:  %s\n", launcher.ShellJoin(r.Command))
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   runOptions
+		wantOK bool
+	}{
+		{nil, runOptions{logFormat: "text"}, true},
+		{[]string{"claude"}, runOptions{profile: "claude", logFormat: "text"}, true},
+		{[]string{"--dry-run", "claude"}, runOptions{profile: "claude", dryRun: true, logFormat: "text"}, true},
+		{[]string{"claude", "--dry-run", "--json"}, runOptions{profile: "claude", dryRun: true, json: true, logFormat: "text"}, true},
+		{[]string{"--dry-run"}, runOptions{dryRun: true, logFormat: "text"}, true},
+		{[]string{"--log-format=json", "claude"}, runOptions{profile: "claude", logFormat: "json"}, true},
+		{[]string{"claude", "--keep-on-failure"}, runOptions{profile: "claude", logFormat: "text", keep: true}, true},
+		{[]string{"claude", "--worktree", "calm-otter"}, runOptions{profile: "claude", logFormat: "text", worktree: "calm-otter"}, true},
+		{[]string{"--branch", "fix/login", "claude"}, runOptions{profile: "claude", logFormat: "text", branch: "fix/login"}, true},
+		{[]string{"--worktree", "x", "--branch", "y"}, runOptions{}, false},
+		{[]string{"claude", "--pr", "456"}, runOptions{profile: "claude", logFormat: "text", pr: 456}, true},
+		{[]string{"--issue=123", "--branch", "fix", "claude"}, runOptions{profile: "claude", logFormat: "text", issue: 123, branch: "fix"}, true},
+		{[]string{"--pr", "1", "--issue", "2"}, runOptions{}, false},
+		{[]string{"--worktree", "x", "--pr", "1"}, runOptions{}, false},
+		{[]string{"--offline", "claude"}, runOptions{profile: "claude", logFormat: "text", offline: true}, true},
+		{[]string{"claude", "--offline", "--pr", "1"}, runOptions{}, false},
+		{[]string{"--issue", "-3"}, runOptions{}, false},
+		{[]string{"--pr", "abc"}, runOptions{}, false},
+		{[]string{"--json", "claude"}, runOptions{}, false},
+		{[]string{"--log-format", "yaml"}, runOptions{}, false},
+		{[]string{"claude", "extra"}, runOptions{}, false},
+		{[]string{"--bogus"}, runOptions{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRunArgs(tt.args)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("parseRunArgs(%q) = %+v, %v; want %+v, %v", tt.args, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func testPlanReport() planReport {
+	ec := &pipeline.ExecutionContext{
+		WorkDir:        "/repo/worktrees/calm-otter",
+		WorktreePath:   "/repo/worktrees/calm-otter",
+		WorktreeBranch: "calm-otter",
+		WorktreeBase:   "origin/main",
+		DockerImage:    "claude-code-docker:abc123",
+		DockerMounts: []docker.Mount{
+			{Source: "claude-code-local", Target: "/home/claude/.local", IsVolume: true},
+			{Source: "/home/u/.ssh", Target: "/home/claude/.ssh-host", ReadOnly: true},
+		},
+		EnvVars: map[string]string{"ZED": "1", "FOO": "bar"},
+	}
+	plans := []pipeline.StagePlan{
+		{Stage: "worktree", Actions: []pipeline.Action{{Description: "run on-create hook", Command: []string{"sh", "-c", "npm ci"}}}},
+		{Stage: "launch", Actions: []pipeline.Action{{Description: "run Claude", Command: []string{"docker", "run", "img", "claude"}}}},
+	}
+	return newPlanReport("wt", ec, plans)
+}
+
+func TestPlanReport_Print(t *testing.T) {
+	var buf bytes.Buffer
+	testPlanReport().print(&buf, "worktree + docker + claude")
+	out := buf.String()
+
+	for _, want := range []string{
+		"Dry run of profile \"wt\" (worktree + docker + claude); nothing was changed.",
+		"[worktree]\n  run on-create hook\n    $ sh -c 'npm ci'\n",
+		"Worktree: /repo/worktrees/calm-otter (new branch calm-otter from origin/main)",
+		"Image:    claude-code-docker:abc123\n",
+		"  claude-code-local -> /home/claude/.local (volume)\n",
+		"  /home/u/.ssh -> /home/claude/.ssh-host (read-only)\n",
+		"Env:\n  FOO=bar\n  ZED=1\n",
+		"Command:  docker run img claude\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPlanReport_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPlanReport().writeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Profile  string            `json:"profile"`
+		Worktree map[string]string `json:"worktree"`
+		Image    string            `json:"image"`
+		Mounts   []map[string]any  `json:"mounts"`
+		Env      map[string]string `json:"env"`
+		Command  []string          `json:"command"`
+		Stages   []struct {
+			Stage string `json:"stage"`
+		} `json:"stages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if got.Profile != "wt" || got.Worktree["branch"] != "calm-otter" || got.Image != "claude-code-docker:abc123" {
+		t.Errorf("summary = %+v", got)
+	}
+	if len(got.Mounts) != 2 || got.Mounts[0]["volume"] != true || got.Env["FOO"] != "bar" {
+		t.Errorf("mounts = %v, env = %v", got.Mounts, got.Env)
+	}
+	if strings.Join(got.Command, " ") != "docker run img claude" || len(got.Stages) != 2 {
+		t.Errorf("command = %v, stages = %v", got.Command, got.Stages)
+	}
+}
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/image"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/session"
 	"github.com/hiragram/agent-workspace/internal/stage"
 	"github.com/hiragram/agent-workspace/internal/update"
 	"github.com/hiragram/agent-workspace/internal/version"
@@ -29,22 +34,62 @@ func Run(args []string) int {
 		return 0
 	}
 
+	// Every command below stops what it runs on the first Ctrl-C.
+	ctx, stop := withInterrupt(context.Background(), os.Stderr)
+	defer stop()
+
 	if len(args) > 0 && args[0] == "profiles" {
-		return runProfiles()
+		return runProfiles(ctx)
 	}
 
 	if len(args) > 0 && args[0] == "default-dockerfile" {
 		return runDefaultDockerfile()
 	}
 
-	// Determine profile name
-	profileName := ""
-	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
-		profileName = args[0]
+	if len(args) > 0 && args[0] == "doctor" {
+		return runDoctor(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "init" {
+		return runInit(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "schema" {
+		return runSchema()
+	}
+
+	if len(args) > 0 && args[0] == "ps" {
+		return runPs()
+	}
+
+	if len(args) > 0 && args[0] == "attach" {
+		return runAttach(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "stop" {
+		return runStop(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "worktree" {
+		return runWorktree(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		return runConfig(ctx, args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "image" {
+		return runImage(ctx, args[1:])
+	}
+
+	opts, ok := parseRunArgs(args)
+	if !ok {
+		return 1
 	}
+	profileName := opts.profile
 
 	// Load config
-	cfg, err := profile.Load()
+	cfg, err := profile.Load(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		return 1
@@ -78,6 +123,10 @@ func Run(args []string) int {
 		fmt.Fprintf(os.Stderr, "Error: invalid profile %q: %v\n", profileName, err)
 		return 1
 	}
+	if (opts.worktree != "" || opts.branch != "" || opts.pr > 0 || opts.issue > 0) && p.Worktree == nil {
+		fmt.Fprintf(os.Stderr, "Error: --worktree, --branch, --pr and --issue need a profile with worktree settings; %q has none\n", profileName)
+		return 1
+	}
 
 	// Build execution context
 	homeDir, err := os.UserHomeDir()
@@ -93,11 +142,40 @@ func Run(args []string) int {
 	}
 
 	ec := &pipeline.ExecutionContext{
-		Profile:     p,
-		ProfileName: profileName,
-		HomeDir:     homeDir,
-		OrigWorkDir: workDir,
-		WorkDir:     workDir,
+		Profile:       p,
+		ProfileName:   profileName,
+		HomeDir:       homeDir,
+		OrigWorkDir:   workDir,
+		WorkDir:       workDir,
+		ReuseWorktree: opts.worktree,
+		NewBranch:     opts.branch,
+		PRNumber:      opts.pr,
+		IssueNumber:   opts.issue,
+		Offline:       opts.offline,
+	}
+
+	// Build pipeline stages
+	stages := buildStages(p)
+	pipe := pipeline.New(stages...)
+	pipe.KeepOnFailure = opts.keep
+
+	if opts.dryRun {
+		plans, err := pipe.Plan(ctx, ec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		report := newPlanReport(profileName, ec, plans)
+		if opts.json {
+			err = report.writeJSON(os.Stdout)
+		} else {
+			report.print(os.Stdout, describeProfile(p))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
 	}
 
 	// Warn about on-end limitations
@@ -107,31 +185,144 @@ func Run(args []string) int {
 		fmt.Fprintf(os.Stderr, "Warning: on-end hook will not run with environment: host + launch: %s (process is replaced via exec)\n", p.Launch)
 	}
 
-	// Build pipeline stages
-	stages := buildStages(p)
-	pipe := pipeline.New(stages...)
+	sessionLog := pipeline.NewSessionLog(session.NewRegistry(session.DefaultDir(homeDir)).LogPath)
+	ec.Observer = pipeline.Observers{newLogSink(opts.logFormat), sessionLog}
+	defer func() {
+		if err := sessionLog.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing session log: %v\n", err)
+		}
+	}()
 
-	if err := pipe.Execute(context.Background(), ec); err != nil {
-		runOnEndIfConfigured(ec)
+	// Cleanup must run after an interrupt too.
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	if err := pipe.Execute(ctx, ec); err != nil {
+		if ctx.Err() != nil {
+			stopRunContainer(cleanupCtx, ec)
+		}
+		runOnEndIfConfigured(cleanupCtx, ec)
+		finishSession(ec)
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Interrupted")
+			return interruptedExitCode
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Run `aw doctor %s` to check this machine for what the profile needs.\n", profileName)
 		return 1
 	}
 
-	runOnEndIfConfigured(ec)
+	runOnEndIfConfigured(cleanupCtx, ec)
+	finishSession(ec)
 	return 0
 }
 
-func runOnEndIfConfigured(ec *pipeline.ExecutionContext) {
+// stopRunContainer stops the run's container, which an interrupted docker
+// client may have left running. Errors are ignored: with --rm the container
+// is usually gone already.
+func stopRunContainer(ctx context.Context, ec *pipeline.ExecutionContext) {
+	if ec.ContainerName == "" || ec.DockerClient == nil {
+		return
+	}
+	_ = ec.DockerClient.Stop(ctx, ec.ContainerName)
+}
+
+// newLogSink returns the observer that prints pipeline events on stderr.
+func newLogSink(format string) pipeline.Observer {
+	if format == "json" {
+		return pipeline.NewJSONSink(os.Stderr)
+	}
+	return pipeline.NewHumanSink(os.Stderr)
+}
+
+const runUsage = "Usage: aw [--dry-run [--json]] [--log-format text|json] [--keep-on-failure] [--offline] [--worktree <name|path> | [--branch <name>] [--pr <n> | --issue <n>]] [profile]"
+
+// runOptions are the flags and arguments of `aw [profile]`.
+type runOptions struct {
+	profile   string
+	dryRun    bool   // plan the run without side effects
+	json      bool   // print the dry-run plan as JSON
+	logFormat string // how pipeline events are printed on stderr: text or json
+	keep      bool   // do not roll back a failed run
+	worktree  string // existing worktree to resume
+	branch    string // branch name for the new worktree
+	pr        int    // pull request to check out into the new worktree
+	issue     int    // issue the run is for
+	offline   bool   // do not fetch the base ref
+}
+
+// parseRunArgs parses the arguments of `aw [profile]`. Flags may come before
+// or after the profile name. Parse errors are reported on stderr.
+func parseRunArgs(args []string) (runOptions, bool) {
+	var opts runOptions
+	fs := flag.NewFlagSet("aw", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), runUsage) }
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print what would be done without doing it")
+	fs.BoolVar(&opts.json, "json", false, "with --dry-run, print the plan as JSON")
+	fs.StringVar(&opts.logFormat, "log-format", "text", "print progress as text or as JSON lines")
+	fs.BoolVar(&opts.keep, "keep-on-failure", false, "keep the worktree and session of a failed run for debugging")
+	fs.StringVar(&opts.worktree, "worktree", "", "resume an existing worktree (name, branch or path) instead of creating one")
+	fs.StringVar(&opts.branch, "branch", "", "name the new worktree's branch instead of using worktree.branch")
+	fs.IntVar(&opts.pr, "pr", 0, "check out this pull request into the new worktree")
+	fs.IntVar(&opts.issue, "issue", 0, "start the new worktree for this issue and write it to .aw-prompt.md (needs gh)")
+	fs.BoolVar(&opts.offline, "offline", false, "do not fetch; start the new worktree from the local base ref")
+	if err := fs.Parse(args); err != nil {
+		return opts, false
+	}
+	opts.profile = fs.Arg(0)
+	if fs.NArg() > 0 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return opts, false
+		}
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(fs.Output(), runUsage)
+		return opts, false
+	}
+	if opts.json && !opts.dryRun {
+		fmt.Fprintln(fs.Output(), "Error: --json requires --dry-run")
+		return opts, false
+	}
+	if opts.worktree != "" && (opts.branch != "" || opts.pr != 0 || opts.issue != 0) {
+		fmt.Fprintln(fs.Output(), "Error: --worktree resumes a worktree and cannot be combined with --branch, --pr or --issue")
+		return opts, false
+	}
+	if opts.pr != 0 && opts.issue != 0 {
+		fmt.Fprintln(fs.Output(), "Error: --pr and --issue cannot be used together")
+		return opts, false
+	}
+	if opts.offline && (opts.pr != 0 || opts.issue != 0) {
+		fmt.Fprintln(fs.Output(), "Error: --pr and --issue need the network and cannot be combined with --offline")
+		return opts, false
+	}
+	if opts.pr < 0 || opts.issue < 0 {
+		fmt.Fprintln(fs.Output(), "Error: --pr and --issue take a positive number")
+		return opts, false
+	}
+	if opts.logFormat != "text" && opts.logFormat != "json" {
+		fmt.Fprintf(fs.Output(), "Error: unknown --log-format %q (want text or json)\n", opts.logFormat)
+		return opts, false
+	}
+	return opts, true
+}
+
+func runOnEndIfConfigured(ctx context.Context, ec *pipeline.ExecutionContext) {
 	if ec.Profile.Worktree == nil || ec.Profile.Worktree.OnEnd == "" {
 		return
 	}
 	if ec.WorktreePath == "" {
 		return
 	}
-	fmt.Fprintf(os.Stderr, "Running on-end hook...\n")
-	if err := stage.RunOnEndHook(ec); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: on-end hook failed: %v\n", err)
+	ec.Logf("Running on-end hook...")
+	start := time.Now()
+	if err := stage.RunOnEndHook(ctx, ec); err != nil {
+		ec.Warnf("on-end hook failed: %v", err)
+		return
 	}
+	ec.Emit(pipeline.Event{
+		Kind:     pipeline.HookFinished,
+		Duration: time.Since(start),
+		Fields:   map[string]string{"hook": "on-end"},
+	})
 }
 
 // buildStages creates the pipeline stages based on the profile configuration.
@@ -140,12 +331,17 @@ func buildStages(p profile.Profile) []pipeline.Stage {
 
 	// Stage 1: Worktree (conditional)
 	if p.Worktree != nil {
-		stages = append(stages, &stage.WorktreeStage{})
+		stages = append(stages, &stage.WorktreeStage{Choose: chooseWorktree(os.Stdin, os.Stderr)})
 	}
 
 	// Stage 2: Docker setup (conditional)
 	if p.Environment == profile.EnvironmentDocker {
-		stages = append(stages, stage.NewDockerStage())
+		ds := stage.NewDockerStage()
+		// The client kind and runtime were checked by profile.Validate.
+		if client, err := docker.NewClient(string(p.Docker.EffectiveClient()), string(p.EffectiveRuntime())); err == nil {
+			ds.DockerClient = client
+		}
+		stages = append(stages, ds)
 	}
 
 	// Stage 3: Env loading (conditional — only for Docker, where custom env vars are needed)
@@ -153,24 +349,31 @@ func buildStages(p profile.Profile) []pipeline.Stage {
 		stages = append(stages, &stage.EnvStage{})
 	}
 
-	// Stage 4: Launch (always)
+	// Stage 4: Session registration (always)
+	stages = append(stages, &stage.SessionStage{})
+
+	// Stage 5: Launch (always)
 	stages = append(stages, &stage.LaunchStage{})
 
 	return stages
 }
 
-func runProfiles() int {
-	cfg, err := profile.Load()
+func runProfiles(ctx context.Context) int {
+	cfg, err := profile.Load(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		return 1
 	}
 
-	// Show config source
+	// Show config sources
 	if cfg.Source.IsBuiltin {
 		fmt.Println("Source: built-in default (no .agent-workspace.yml found)")
 	} else {
-		fmt.Printf("Source: %s\n", cfg.Source.FilePath)
+		fmt.Println("Sources (later layers override earlier ones):")
+		fmt.Printf("  %-8s built-in default\n", profile.LayerBuiltin)
+		for _, f := range cfg.Source.Files {
+			fmt.Printf("  %-8s %s\n", f.Layer, f.Path)
+		}
 	}
 	fmt.Println()
 
@@ -179,15 +382,21 @@ func runProfiles() int {
 }
 
 func printAvailableProfiles(cfg *profile.Config) {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	fmt.Println("Available profiles:")
-	for name, p := range cfg.Profiles {
+	for _, name := range names {
 		marker := "  "
 		if name == cfg.Default {
 			marker = "* "
 		}
 
-		desc := describeProfile(p)
-		fmt.Printf("  %s%s  (%s)\n", marker, name, desc)
+		desc := describeProfile(cfg.Profiles[name])
+		fmt.Printf("  %s%s  (%s)%s\n", marker, name, desc, describeLayers(cfg.Source.ProfileLayers[name]))
 	}
 	fmt.Println()
 	fmt.Println("Usage: aw <profile-name>")
@@ -209,6 +418,19 @@ func describeProfile(p profile.Profile) string {
 	return strings.Join(parts, " + ")
 }
 
+// describeLayers formats the config layers a profile was declared in, e.g.
+// " [repo, local]". It returns "" when the layers are unknown.
+func describeLayers(layers []profile.Layer) string {
+	if len(layers) == 0 {
+		return ""
+	}
+	parts := make([]string, len(layers))
+	for i, l := range layers {
+		parts[i] = string(l)
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
 func runDefaultDockerfile() int {
 	_, err := os.Stdout.Write(image.DefaultDockerfile())
 	if err != nil {
@@ -218,6 +440,19 @@ func runDefaultDockerfile() int {
 	return 0
 }
 
+func runSchema() int {
+	out, err := profile.Schema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating schema: %v\n", err)
+		return 1
+	}
+	if _, err := os.Stdout.Write(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
 // hasVersionFlag checks if the args contain --version or -v.
 func hasVersionFlag(args []string) bool {
 	for _, a := range args {
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"testing"
 
 	"github.com/hiragram/agent-workspace/internal/pipeline"
@@ -14,9 +15,9 @@ func TestBuildStages_DockerClaude(t *testing.T) {
 	}
 	stages := buildStages(p)
 
-	// Should have DockerStage + EnvStage + LaunchStage = 3 stages
-	if len(stages) != 3 {
-		t.Fatalf("got %d stages, want 3", len(stages))
+	// Should have DockerStage + EnvStage + SessionStage + LaunchStage = 4 stages
+	if len(stages) != 4 {
+		t.Fatalf("got %d stages, want 4", len(stages))
 	}
 	if stages[0].Name() != "docker" {
 		t.Errorf("stage[0] = %q, want 'docker'", stages[0].Name())
@@ -24,8 +25,11 @@ func TestBuildStages_DockerClaude(t *testing.T) {
 	if stages[1].Name() != "env" {
 		t.Errorf("stage[1] = %q, want 'env'", stages[1].Name())
 	}
-	if stages[2].Name() != "launch" {
-		t.Errorf("stage[2] = %q, want 'launch'", stages[2].Name())
+	if stages[2].Name() != "session" {
+		t.Errorf("stage[2] = %q, want 'session'", stages[2].Name())
+	}
+	if stages[3].Name() != "launch" {
+		t.Errorf("stage[3] = %q, want 'launch'", stages[3].Name())
 	}
 }
 
@@ -37,15 +41,18 @@ func TestBuildStages_WorktreeHostShell(t *testing.T) {
 	}
 	stages := buildStages(p)
 
-	// Should have WorktreeStage + LaunchStage = 2 stages
-	if len(stages) != 2 {
-		t.Fatalf("got %d stages, want 2", len(stages))
+	// Should have WorktreeStage + SessionStage + LaunchStage = 3 stages
+	if len(stages) != 3 {
+		t.Fatalf("got %d stages, want 3", len(stages))
 	}
 	if stages[0].Name() != "worktree" {
 		t.Errorf("stage[0] = %q, want 'worktree'", stages[0].Name())
 	}
-	if stages[1].Name() != "launch" {
-		t.Errorf("stage[1] = %q, want 'launch'", stages[1].Name())
+	if stages[1].Name() != "session" {
+		t.Errorf("stage[1] = %q, want 'session'", stages[1].Name())
+	}
+	if stages[2].Name() != "launch" {
+		t.Errorf("stage[2] = %q, want 'launch'", stages[2].Name())
 	}
 }
 
@@ -57,9 +64,9 @@ func TestBuildStages_WorktreeDockerZellij(t *testing.T) {
 	}
 	stages := buildStages(p)
 
-	// Should have WorktreeStage + DockerStage + EnvStage + LaunchStage = 4 stages
-	if len(stages) != 4 {
-		t.Fatalf("got %d stages, want 4", len(stages))
+	// Should have WorktreeStage + DockerStage + EnvStage + SessionStage + LaunchStage = 5 stages
+	if len(stages) != 5 {
+		t.Fatalf("got %d stages, want 5", len(stages))
 	}
 	if stages[0].Name() != "worktree" {
 		t.Errorf("stage[0] = %q, want 'worktree'", stages[0].Name())
@@ -70,8 +77,11 @@ func TestBuildStages_WorktreeDockerZellij(t *testing.T) {
 	if stages[2].Name() != "env" {
 		t.Errorf("stage[2] = %q, want 'env'", stages[2].Name())
 	}
-	if stages[3].Name() != "launch" {
-		t.Errorf("stage[3] = %q, want 'launch'", stages[3].Name())
+	if stages[3].Name() != "session" {
+		t.Errorf("stage[3] = %q, want 'session'", stages[3].Name())
+	}
+	if stages[4].Name() != "launch" {
+		t.Errorf("stage[4] = %q, want 'launch'", stages[4].Name())
 	}
 }
 
@@ -82,12 +92,15 @@ func TestBuildStages_HostClaude(t *testing.T) {
 	}
 	stages := buildStages(p)
 
-	// Should have LaunchStage only = 1 stage
-	if len(stages) != 1 {
-		t.Fatalf("got %d stages, want 1", len(stages))
+	// Should have SessionStage + LaunchStage = 2 stages
+	if len(stages) != 2 {
+		t.Fatalf("got %d stages, want 2", len(stages))
+	}
+	if stages[0].Name() != "session" {
+		t.Errorf("stage[0] = %q, want 'session'", stages[0].Name())
 	}
-	if stages[0].Name() != "launch" {
-		t.Errorf("stage[0] = %q, want 'launch'", stages[0].Name())
+	if stages[1].Name() != "launch" {
+		t.Errorf("stage[1] = %q, want 'launch'", stages[1].Name())
 	}
 }
 
@@ -99,7 +112,7 @@ func TestRunOnEndIfConfigured_SkipsWhenNoWorktree(t *testing.T) {
 		},
 	}
 	// Should not panic or error
-	runOnEndIfConfigured(ec)
+	runOnEndIfConfigured(context.Background(), ec)
 }
 
 func TestRunOnEndIfConfigured_SkipsWhenNoOnEnd(t *testing.T) {
@@ -112,7 +125,7 @@ func TestRunOnEndIfConfigured_SkipsWhenNoOnEnd(t *testing.T) {
 		WorktreePath: "/some/path",
 	}
 	// Should not panic or error
-	runOnEndIfConfigured(ec)
+	runOnEndIfConfigured(context.Background(), ec)
 }
 
 func TestRunOnEndIfConfigured_SkipsWhenWorktreePathEmpty(t *testing.T) {
@@ -125,7 +138,7 @@ func TestRunOnEndIfConfigured_SkipsWhenWorktreePathEmpty(t *testing.T) {
 		WorktreePath: "",
 	}
 	// Should not panic or error (WorktreeStage didn't run)
-	runOnEndIfConfigured(ec)
+	runOnEndIfConfigured(context.Background(), ec)
 }
 
 func TestRunDefaultDockerfile_ReturnsZero(t *testing.T) {
@@ -167,3 +180,13 @@ func TestDescribeProfile(t *testing.T) {
 		})
 	}
 }
+
+func TestDescribeLayers(t *testing.T) {
+	if got := describeLayers(nil); got != "" {
+		t.Errorf("describeLayers(nil) = %q, want empty", got)
+	}
+	got := describeLayers([]profile.Layer{profile.LayerBuiltin, profile.LayerLocal})
+	if got != " [builtin, local]" {
+		t.Errorf("describeLayers() = %q, want %q", got, " [builtin, local]")
+	}
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/launcher"
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/session"
+)
+
+// Package-level vars for testing.
+var (
+	listZellijSessions = launcher.ListZellijSessions
+	newDockerClient    = docker.NewClient
+	processStart       = session.ProcessStart
+)
+
+// sessionRegistry returns the registry in the default location.
+func sessionRegistry() (*session.Registry, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return session.NewRegistry(session.DefaultDir(homeDir)), nil
+}
+
+// liveZellijSessions returns the set of zellij sessions currently known to
+// zellij. A missing zellij binary yields an empty set.
+func liveZellijSessions() map[string]bool {
+	names, err := listZellijSessions()
+	if err != nil {
+		return nil
+	}
+	live := make(map[string]bool, len(names))
+	for _, n := range names {
+		live[n] = true
+	}
+	return live
+}
+
+// sessionAlive reports whether the process behind s is still running.
+// Zellij sessions outlive the aw process (they can be detached), so they are
+// checked by name; everything else is tied to the lifetime of the aw process,
+// or of the program it exec'd.
+func sessionAlive(s session.Session, zellij map[string]bool) bool {
+	if s.ZellijSession != "" {
+		return zellij[s.ZellijSession]
+	}
+	if s.PIDStart == "" {
+		// Recorded without a start time: the pid may have been reused.
+		return processAlive(s.PID)
+	}
+	return sessionProcess(s)
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// sessionProcess reports whether s.PID is still the process that started
+// s, rather than a later one that reuses its pid. It is false for records
+// without a start time, whose process cannot be told apart.
+func sessionProcess(s session.Session) bool {
+	if s.PID <= 0 || s.PIDStart == "" {
+		return false
+	}
+	start, err := processStart(s.PID)
+	return err == nil && start == s.PIDStart
+}
+
+// finishSession removes the session record once the launched process has
+// exited. Detached zellij sessions keep their record so they can be
+// reattached with `aw attach`.
+func finishSession(ec *pipeline.ExecutionContext) {
+	if ec.SessionID == "" {
+		return
+	}
+	reg, err := sessionRegistry()
+	if err != nil {
+		return
+	}
+	s, err := reg.Find(ec.SessionID)
+	if err != nil {
+		return
+	}
+	if s.ZellijSession != "" && liveZellijSessions()[s.ZellijSession] {
+		fmt.Fprintf(os.Stderr, "Session %s is still running (reattach: aw attach %s)\n", s.ID, s.ID)
+		return
+	}
+	if err := reg.Remove(s.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+func runPs() int {
+	reg, err := sessionRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	sessions, err := reg.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Sessions whose process is gone without removing the record, e.g. a
+	// host session aw exec'd, are pruned.
+	zellij := liveZellijSessions()
+	live := sessions[:0]
+	for _, s := range sessions {
+		if sessionAlive(s, zellij) {
+			live = append(live, s)
+		} else if err := reg.Remove(s.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if len(live) == 0 {
+		fmt.Println("No sessions.")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPROFILE\tBRANCH\tCONTAINER\tZELLIJ\tCREATED\tWORKDIR")
+	for _, s := range live {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.ID, s.Profile,
+			orDash(s.WorktreeBranch), orDash(s.Container), orDash(s.ZellijSession),
+			s.CreatedAt.Local().Format("2006-01-02 15:04"), s.WorkDir)
+	}
+	_ = w.Flush()
+	return 0
+}
+
+func runAttach(ctx context.Context, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: aw attach <session>")
+		return 1
+	}
+	reg, err := sessionRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	s, err := reg.Find(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !sessionAlive(s, liveZellijSessions()) {
+		fmt.Fprintf(os.Stderr, "Error: session %s is not running (remove it with: aw stop %s)\n", s.ID, s.ID)
+		return 1
+	}
+
+	switch {
+	case s.ZellijSession != "":
+		err = launcher.AttachZellijSession(s.ZellijSession)
+	case s.Container != "":
+		var client docker.Client
+		if client, err = newDockerClient(s.DockerClient, s.Runtime); err == nil {
+			err = client.Attach(ctx, s.Container)
+		}
+	default:
+		err = fmt.Errorf("session %s runs directly on the host and cannot be attached", s.ID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runStop(ctx context.Context, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: aw stop <session>")
+		return 1
+	}
+	reg, err := sessionRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	s, err := reg.Find(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if sessionAlive(s, liveZellijSessions()) {
+		// Stop the container first: killing the zellij pane only kills the
+		// docker CLI client, not the container itself.
+		if s.Container != "" {
+			if err := stopContainer(ctx, s); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: stopping container %s: %v\n", s.Container, err)
+			}
+		}
+		switch {
+		case s.ZellijSession != "":
+			if err := launcher.KillZellijSession(s.ZellijSession); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: killing zellij session %s: %v\n", s.ZellijSession, err)
+			}
+		case s.Container == "" && s.PID != os.Getpid():
+			// The pid may belong to an unrelated process by now; only the
+			// process that started the session is signalled.
+			if !sessionProcess(s) {
+				fmt.Fprintf(os.Stderr, "Warning: not signalling pid %d: it cannot be verified to be session %s\n", s.PID, s.ID)
+				break
+			}
+			if err := syscall.Kill(s.PID, syscall.SIGTERM); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: signalling pid %d: %v\n", s.PID, err)
+			}
+		}
+	}
+
+	if err := reg.Remove(s.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Stopped session %s\n", s.ID)
+	if s.WorktreePath != "" {
+		fmt.Printf("Worktree kept at %s\n", s.WorktreePath)
+	}
+	return 0
+}
+
+func stopContainer(ctx context.Context, s session.Session) error {
+	client, err := newDockerClient(s.DockerClient, s.Runtime)
+	if err != nil {
+		return err
+	}
+	return client.Stop(ctx, s.Container)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
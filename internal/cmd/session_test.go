@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/session"
+)
+
+func TestSessionAlive(t *testing.T) {
+	zellij := map[string]bool{"live-one": true}
+
+	tests := []struct {
+		name string
+		s    session.Session
+		want bool
+	}{
+		{"zellij session running", session.Session{ZellijSession: "live-one"}, true},
+		{"zellij session gone", session.Session{ZellijSession: "dead-one", PID: os.Getpid()}, false},
+		{"host process running", session.Session{PID: os.Getpid()}, true},
+		{"host process verified", session.Session{PID: os.Getpid(), PIDStart: selfStart(t)}, true},
+		{"pid reused", session.Session{PID: os.Getpid(), PIDStart: "0"}, false},
+		{"no pid recorded", session.Session{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionAlive(tt.s, zellij); got != tt.want {
+				t.Errorf("sessionAlive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func selfStart(t *testing.T) string {
+	t.Helper()
+	start, err := session.ProcessStart(os.Getpid())
+	if err != nil {
+		t.Fatalf("ProcessStart() error: %v", err)
+	}
+	return start
+}
+
+func withSessionHome(t *testing.T) *session.Registry {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+	return session.NewRegistry(filepath.Join(home, ".local", "state", "agent-workspace", "sessions"))
+}
+
+func TestFinishSession_RemovesExitedSession(t *testing.T) {
+	reg := withSessionHome(t)
+	if err := reg.Save(session.Session{ID: "deadbeef", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	finishSession(&pipeline.ExecutionContext{SessionID: "deadbeef"})
+
+	if _, err := reg.Find("deadbeef"); err == nil {
+		t.Error("session record should be removed after the run finishes")
+	}
+}
+
+func TestFinishSession_KeepsDetachedZellijSession(t *testing.T) {
+	reg := withSessionHome(t)
+	orig := listZellijSessions
+	listZellijSessions = func() ([]string, error) { return []string{"calm-otter-dawn"}, nil }
+	defer func() { listZellijSessions = orig }()
+
+	if err := reg.Save(session.Session{ID: "cafef00d", ZellijSession: "calm-otter-dawn", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	finishSession(&pipeline.ExecutionContext{SessionID: "cafef00d"})
+
+	if _, err := reg.Find("cafef00d"); err != nil {
+		t.Errorf("detached zellij session should keep its record: %v", err)
+	}
+}
+
+func TestRunStop_RemovesExitedSession(t *testing.T) {
+	reg := withSessionHome(t)
+	orig := listZellijSessions
+	listZellijSessions = func() ([]string, error) { return nil, nil }
+	defer func() { listZellijSessions = orig }()
+
+	if err := reg.Save(session.Session{ID: "0badc0de", ZellijSession: "gone", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runStop(context.Background(), []string{"0bad"}); code != 0 {
+		t.Fatalf("runStop() = %d, want 0", code)
+	}
+	if _, err := reg.Find("0badc0de"); err == nil {
+		t.Error("session record should be removed by stop")
+	}
+}
+
+func TestRunStop_Usage(t *testing.T) {
+	if code := runStop(context.Background(), nil); code != 1 {
+		t.Errorf("runStop(nil) = %d, want 1", code)
+	}
+}
+
+func TestRunStop_DoesNotSignalReusedPid(t *testing.T) {
+	reg := withSessionHome(t)
+	// A process that took over the pid of an exited host session.
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cmd.Process.Kill(); _ = cmd.Wait() }()
+
+	for _, start := range []string{"", "0"} {
+		if err := reg.Save(session.Session{ID: "feedface", PID: cmd.Process.Pid, PIDStart: start, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if code := runStop(context.Background(), []string{"feedface"}); code != 0 {
+			t.Fatalf("runStop() = %d, want 0", code)
+		}
+		if !processAlive(cmd.Process.Pid) {
+			t.Fatalf("start %q: runStop() signalled a process that is not the session's", start)
+		}
+	}
+}
+
+func TestRunPs_PrunesExitedSessions(t *testing.T) {
+	reg := withSessionHome(t)
+	orig := listZellijSessions
+	listZellijSessions = func() ([]string, error) { return nil, nil }
+	defer func() { listZellijSessions = orig }()
+
+	for _, s := range []session.Session{
+		{ID: "11111111", PID: os.Getpid(), PIDStart: selfStart(t), CreatedAt: time.Now()},
+		{ID: "22222222", PID: os.Getpid(), PIDStart: "0", CreatedAt: time.Now()},
+		{ID: "33333333", ZellijSession: "gone", CreatedAt: time.Now()},
+	} {
+		if err := reg.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code := runPs(); code != 0 {
+		t.Fatalf("runPs() = %d, want 0", code)
+	}
+	got, err := reg.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "11111111" {
+		t.Errorf("sessions after ps = %v, want only the running one", got)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Package-level vars for testing.
+var (
+	interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	forceExit        = os.Exit
+)
+
+// interruptedExitCode is the conventional status for a run stopped by Ctrl-C.
+const interruptedExitCode = 130
+
+// withInterrupt returns a context that is cancelled by the first SIGINT or
+// SIGTERM, so running commands stop and aw can roll back and run on-end
+// hooks. A second signal exits immediately without cleaning up. Call stop
+// to restore the default signal handling.
+//
+// Interactive sessions put the terminal in raw mode, so Ctrl-C inside
+// Claude, a shell or zellij reaches them rather than aw.
+func withInterrupt(parent context.Context, w io.Writer) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, interruptSignals...)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-done:
+			return
+		}
+		fmt.Fprintln(w, "\nInterrupted; cleaning up (press Ctrl-C again to quit now)")
+		cancel()
+
+		select {
+		case <-sigs:
+			fmt.Fprintln(w, "Quitting without cleaning up")
+			forceExit(interruptedExitCode)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel()
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWithInterrupt(t *testing.T) {
+	origSignals, origExit := interruptSignals, forceExit
+	t.Cleanup(func() { interruptSignals, forceExit = origSignals, origExit })
+	// SIGUSR1 stands in for Ctrl-C so the test runner is not interrupted.
+	interruptSignals = []os.Signal{syscall.SIGUSR1}
+	exited := make(chan int, 1)
+	forceExit = func(code int) { exited <- code }
+
+	var out bytes.Buffer
+	ctx, stop := withInterrupt(context.Background(), &out)
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled by the first signal")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exited:
+		if code != interruptedExitCode {
+			t.Errorf("exit code = %d, want %d", code, interruptedExitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second signal did not force an exit")
+	}
+	if !strings.Contains(out.String(), "press Ctrl-C again") {
+		t.Errorf("output = %q, want a hint about force-quitting", out.String())
+	}
+}
+
+func TestWithInterrupt_Stop(t *testing.T) {
+	ctx, stop := withInterrupt(context.Background(), &bytes.Buffer{})
+	stop()
+	if ctx.Err() == nil {
+		t.Error("stop() should cancel the context")
+	}
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/session"
+	"github.com/hiragram/agent-workspace/internal/worktree"
+)
+
+const worktreeUsage = `Usage:
+  aw worktree list                 List worktrees created by aw
+  aw worktree rm [--force] <name>  Remove a worktree and its branch
+  aw worktree prune [--dry-run]    Remove worktrees merged into their base ref`
+
+func runWorktree(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, worktreeUsage)
+		return 1
+	}
+	switch args[0] {
+	case "list", "ls":
+		return runWorktreeList(ctx)
+	case "rm", "remove":
+		return runWorktreeRm(ctx, args[1:])
+	case "prune":
+		return runWorktreePrune(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown worktree command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, worktreeUsage)
+		return 1
+	}
+}
+
+// awWorktrees returns the repository root and the worktrees aw created in it,
+// i.e. those living in a worktrees directory of any configured profile.
+func awWorktrees(ctx context.Context) (string, []worktree.Worktree, error) {
+	repoRoot, err := worktree.RepoRoot(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := profile.Load(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	dirs, err := worktreeDirs(cfg, repoRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	wts, err := worktree.List(ctx, repoRoot, dirs)
+	if err != nil {
+		return "", nil, err
+	}
+	return repoRoot, wts, nil
+}
+
+// worktreeDirs maps every worktrees directory used by cfg to the base ref of
+// the profile that uses it. The default profile wins when several profiles
+// share a directory.
+func worktreeDirs(cfg *profile.Config, repoRoot string) (map[string]string, error) {
+	dirs := make(map[string]string)
+	add := func(p profile.Profile, override bool) error {
+		if p.Worktree == nil {
+			return nil
+		}
+		dir, err := worktree.ResolveDir(p.Worktree.Dir, repoRoot)
+		if err != nil {
+			return err
+		}
+		if _, ok := dirs[dir]; !ok || override {
+			dirs[dir] = p.Worktree.EffectiveBase()
+		}
+		return nil
+	}
+
+	for _, p := range cfg.Profiles {
+		if err := add(p, false); err != nil {
+			return nil, err
+		}
+	}
+	if p, ok := cfg.Profiles[cfg.Default]; ok {
+		if err := add(p, true); err != nil {
+			return nil, err
+		}
+	}
+	if len(dirs) == 0 {
+		// No worktree profiles: still look in the default location.
+		if err := add(profile.Profile{Worktree: &profile.WorktreeConfig{}}, false); err != nil {
+			return nil, err
+		}
+	}
+	return dirs, nil
+}
+
+// runningSessionFor returns the running session using the worktree at path, if any.
+func runningSessionFor(path string) (session.Session, bool) {
+	reg, err := sessionRegistry()
+	if err != nil {
+		return session.Session{}, false
+	}
+	sessions, err := reg.List()
+	if err != nil {
+		return session.Session{}, false
+	}
+	zellij := liveZellijSessions()
+	for _, s := range sessions {
+		if s.WorktreePath == path && sessionAlive(s, zellij) {
+			return s, true
+		}
+	}
+	return session.Session{}, false
+}
+
+func runWorktreeList(ctx context.Context) int {
+	repoRoot, wts, err := awWorktrees(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(wts) == 0 {
+		fmt.Println("No worktrees created by aw.")
+		return 0
+	}
+	printWorktrees(ctx, os.Stdout, repoRoot, wts)
+	return 0
+}
+
+func printWorktrees(ctx context.Context, out io.Writer, repoRoot string, wts []worktree.Worktree) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBRANCH\tBASE\tSTATE\tAHEAD\tBEHIND\tPATH")
+	for _, wt := range wts {
+		st, err := worktree.GetStatus(ctx, repoRoot, wt)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t-\t-\t%s\n", wt.Name(), orDash(wt.Branch), wt.Base, "error", wt.Path)
+			continue
+		}
+		state := "clean"
+		if st.Dirty {
+			state = "dirty"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			wt.Name(), orDash(wt.Branch), wt.Base, state, st.Ahead, st.Behind, wt.Path)
+	}
+	_ = w.Flush()
+}
+
+// chooseWorktree returns a WorktreeStage.Choose that lists wts on out and
+// reads the user's pick from in. An empty answer, or end of input, means a
+// new worktree.
+func chooseWorktree(in io.Reader, out io.Writer) func([]worktree.Worktree) (worktree.Worktree, bool) {
+	ask := &prompter{in: bufio.NewReader(in), out: out}
+	return func(wts []worktree.Worktree) (worktree.Worktree, bool) {
+		fmt.Fprintln(out, "Existing worktrees:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for i, wt := range wts {
+			used := "last used " + worktree.LastUsed(wt).Local().Format("2006-01-02 15:04")
+			if s, ok := runningSessionFor(wt.Path); ok {
+				used += ", in use by session " + s.ID
+			}
+			fmt.Fprintf(w, "  %d)\t%s\t%s\t%s\n", i+1, wt.Name(), orDash(wt.Branch), used)
+		}
+		_ = w.Flush()
+
+		for {
+			answer := ask.text(fmt.Sprintf("Resume which worktree (1-%d), or n for a new one", len(wts)), "n")
+			if answer == "n" || answer == "new" {
+				return worktree.Worktree{}, false
+			}
+			if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(wts) {
+				return wts[i-1], true
+			}
+			fmt.Fprintf(out, "Please enter a number from 1 to %d, or n.\n", len(wts))
+		}
+	}
+}
+
+func runWorktreeRm(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("worktree rm", flag.ContinueOnError)
+	force := fs.Bool("force", false, "remove even with uncommitted changes or unpushed commits")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: aw worktree rm [--force] <name>")
+		return 1
+	}
+
+	repoRoot, wts, err := awWorktrees(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	wt, err := worktree.Find(wts, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if s, ok := runningSessionFor(wt.Path); ok && !*force {
+		fmt.Fprintf(os.Stderr, "Error: worktree %s is in use by running session %s (stop it with: aw stop %s)\n", wt.Name(), s.ID, s.ID)
+		return 1
+	}
+
+	if err := worktree.Remove(ctx, repoRoot, wt, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %s\n", wt.Name())
+	return 0
+}
+
+func runWorktreePrune(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("worktree prune", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only print what would be removed")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	repoRoot, wts, err := awWorktrees(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	failed := false
+	removed := 0
+	for _, wt := range wts {
+		if wt.Branch == "" {
+			continue
+		}
+		st, err := worktree.GetStatus(ctx, repoRoot, wt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		if !st.Merged {
+			continue
+		}
+		if st.Dirty {
+			fmt.Fprintf(os.Stderr, "Skipping %s: uncommitted changes\n", wt.Name())
+			continue
+		}
+		if s, ok := runningSessionFor(wt.Path); ok {
+			fmt.Fprintf(os.Stderr, "Skipping %s: in use by session %s\n", wt.Name(), s.ID)
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("Would remove %s (merged into %s)\n", wt.Name(), wt.Base)
+			continue
+		}
+		if err := worktree.Remove(ctx, repoRoot, wt, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Removed %s (merged into %s)\n", wt.Name(), wt.Base)
+		removed++
+	}
+
+	if !*dryRun && removed == 0 && !failed {
+		fmt.Println("Nothing to prune.")
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+func TestWorktreeDirs(t *testing.T) {
+	cfg := &profile.Config{
+		Default: "shell",
+		Profiles: map[string]profile.Profile{
+			"shell":  {Worktree: &profile.WorktreeConfig{Base: "origin/develop"}},
+			"other":  {Worktree: &profile.WorktreeConfig{Base: "origin/main"}},
+			"shared": {Worktree: &profile.WorktreeConfig{Dir: "/tmp/aw-wt"}},
+			"plain":  {},
+		},
+	}
+
+	dirs, err := worktreeDirs(cfg, "/repo")
+	if err != nil {
+		t.Fatalf("worktreeDirs() error: %v", err)
+	}
+	if len(dirs) != 2 {
+		t.Fatalf("got %d dirs, want 2: %v", len(dirs), dirs)
+	}
+	if dirs["/repo/worktrees"] != "origin/develop" {
+		t.Errorf("/repo/worktrees base = %q, want default profile's base origin/develop", dirs["/repo/worktrees"])
+	}
+	if dirs["/tmp/aw-wt"] != "origin/main" {
+		t.Errorf("/tmp/aw-wt base = %q, want origin/main", dirs["/tmp/aw-wt"])
+	}
+}
+
+func TestWorktreeDirs_NoWorktreeProfiles(t *testing.T) {
+	cfg := &profile.Config{Profiles: map[string]profile.Profile{"plain": {}}}
+
+	dirs, err := worktreeDirs(cfg, "/repo")
+	if err != nil {
+		t.Fatalf("worktreeDirs() error: %v", err)
+	}
+	if _, ok := dirs["/repo/worktrees"]; !ok || len(dirs) != 1 {
+		t.Errorf("dirs = %v, want only the default /repo/worktrees", dirs)
+	}
+}
+
+func TestRunWorktree_Usage(t *testing.T) {
+	if code := runWorktree(context.Background(), nil); code != 1 {
+		t.Errorf("runWorktree(nil) = %d, want 1", code)
+	}
+	if code := runWorktree(context.Background(), []string{"bogus"}); code != 1 {
+		t.Errorf("runWorktree(bogus) = %d, want 1", code)
+	}
+}
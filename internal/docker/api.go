@@ -0,0 +1,799 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultHost is the daemon address used when DOCKER_HOST is not set.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// APIClient implements Client by talking HTTP to the Docker Engine API,
+// without requiring the docker CLI. Podman serves a compatible API, so the
+// same client works against its socket.
+type APIClient struct {
+	// Host is the daemon address: "unix:///path/to/docker.sock" or
+	// "tcp://host:port". If empty, CheckAvailable picks the default socket
+	// of Runtime.
+	Host string
+
+	// Runtime selects the default socket when Host is empty: RuntimeDocker
+	// (DefaultHost), RuntimePodman (PodmanHost), or RuntimeAuto to try both.
+	Runtime string
+
+	// Stdin, Stdout and Stderr default to the process's standard streams.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	httpClient *http.Client
+	baseURL    string
+	info       RuntimeInfo
+}
+
+// NewAPIClient creates an APIClient for $DOCKER_HOST. If it is unset, the
+// host is chosen by CheckAvailable.
+func NewAPIClient() *APIClient {
+	return &APIClient{Host: os.Getenv("DOCKER_HOST")}
+}
+
+// apiError is the error body returned by the Engine API.
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// statusError is returned for a response with an error status, so callers
+// can tell "not found" from other failures.
+type statusError struct {
+	Status int
+	msg    string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func (c *APIClient) stdin() io.Reader {
+	if c.Stdin != nil {
+		return c.Stdin
+	}
+	return os.Stdin
+}
+
+func (c *APIClient) stdout() io.Writer {
+	if c.Stdout != nil {
+		return c.Stdout
+	}
+	return os.Stdout
+}
+
+func (c *APIClient) stderr() io.Writer {
+	if c.Stderr != nil {
+		return c.Stderr
+	}
+	return os.Stderr
+}
+
+// init lazily sets up the HTTP client for c.Host.
+func (c *APIClient) init() error {
+	if c.httpClient != nil {
+		return nil
+	}
+	if c.Host == "" {
+		c.Host = DefaultHost
+		if runtimeCandidates(c.Runtime)[0] == RuntimePodman {
+			c.Host = PodmanHost()
+		}
+	}
+	host := c.Host
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return fmt.Errorf("parsing docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		c.httpClient = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}}
+		c.baseURL = "http://docker"
+	case "tcp", "http":
+		c.httpClient = &http.Client{Transport: &http.Transport{}}
+		c.baseURL = "http://" + u.Host
+	default:
+		return fmt.Errorf("unsupported docker host %q (must be unix:// or tcp://)", host)
+	}
+	return nil
+}
+
+// do sends a request to the Engine API and returns the response. Responses
+// with a 4xx/5xx status are turned into errors carrying the daemon's message.
+func (c *APIClient) do(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to the docker daemon at %s: %w", c.Host, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer func() { _ = resp.Body.Close() }()
+		data, _ := io.ReadAll(resp.Body)
+		var apiErr apiError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, &statusError{resp.StatusCode, fmt.Sprintf("%s %s: %s", method, path, apiErr.Message)}
+		}
+		return nil, &statusError{resp.StatusCode, fmt.Sprintf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))}
+	}
+	return resp, nil
+}
+
+// doJSON sends in as a JSON body (if non-nil) and decodes the response into
+// out (if non-nil).
+func (c *APIClient) doJSON(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	header := http.Header{}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(ctx, method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// CheckAvailable pings the daemon and detects which runtime serves it.
+// Without an explicit Host, the default socket of each candidate runtime is
+// tried in turn and the first one that answers is kept.
+func (c *APIClient) CheckAvailable() error {
+	if c.Host != "" {
+		return c.check(c.Host)
+	}
+
+	var errs []string
+	for _, rt := range runtimeCandidates(c.Runtime) {
+		host := DefaultHost
+		if rt == RuntimePodman {
+			host = PodmanHost()
+		}
+		err := c.check(host)
+		if err == nil {
+			return nil
+		}
+		c.Host = ""
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 1 {
+		return errors.New(errs[0])
+	}
+	return fmt.Errorf("no container runtime available (%s)", strings.Join(errs, "; "))
+}
+
+// check pings host and records the runtime behind it.
+func (c *APIClient) check(host string) error {
+	c.Host = host
+	c.httpClient = nil
+	ctx := context.Background()
+	if err := c.doJSON(ctx, http.MethodGet, "/_ping", nil, nil, nil); err != nil {
+		return err
+	}
+
+	var version struct {
+		Platform   struct{ Name string }
+		Components []struct{ Name string }
+	}
+	var info struct {
+		SecurityOptions []string
+	}
+	// Both are best-effort: a daemon that answers /_ping is usable even if
+	// it does not describe itself.
+	_ = c.doJSON(ctx, http.MethodGet, "/version", nil, nil, &version)
+	_ = c.doJSON(ctx, http.MethodGet, "/info", nil, nil, &info)
+
+	c.info = RuntimeInfo{Name: RuntimeDocker}
+	names := []string{version.Platform.Name}
+	for _, comp := range version.Components {
+		names = append(names, comp.Name)
+	}
+	for _, n := range names {
+		if strings.Contains(strings.ToLower(n), "podman") {
+			c.info.Name = RuntimePodman
+		}
+	}
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, "rootless") {
+			c.info.Rootless = true
+		}
+	}
+	return nil
+}
+
+// RuntimeInfo reports the runtime detected by CheckAvailable.
+func (c *APIClient) RuntimeInfo() RuntimeInfo {
+	return c.info
+}
+
+// buildMessage is one line of the JSON stream returned by POST /build and
+// POST /images/create.
+type buildMessage struct {
+	Stream   string `json:"stream"`
+	Status   string `json:"status"`
+	ID       string `json:"id"`
+	Progress string `json:"progress"`
+	Error    string `json:"error"`
+}
+
+// Build builds an image from contextDir, streaming the build output.
+func (c *APIClient) Build(ctx context.Context, imageName, contextDir string, opts BuildOptions) error {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeTar(pw, contextDir))
+	}()
+	defer func() { _ = pr.Close() }()
+
+	query := url.Values{"t": {imageName}, "rm": {"1"}}
+	if len(opts.Args) > 0 {
+		args, _ := json.Marshal(opts.Args) // a map of strings always encodes
+		query.Set("buildargs", string(args))
+	}
+	if opts.Target != "" {
+		query.Set("target", opts.Target)
+	}
+	if opts.Platform != "" {
+		query.Set("platform", opts.Platform)
+	}
+	if opts.NoCache {
+		query.Set("nocache", "1")
+	}
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+	resp, err := c.do(ctx, http.MethodPost, "/build", query, pr, header)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg buildMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading build output: %w", err)
+		}
+
+		switch {
+		case msg.Error != "":
+			return fmt.Errorf("%s", strings.TrimSpace(msg.Error))
+		case msg.Stream != "":
+			_, _ = io.WriteString(c.stdout(), msg.Stream)
+		case msg.Status != "":
+			_, _ = fmt.Fprintln(c.stdout(), msg.Status)
+		}
+	}
+}
+
+// writeTar writes dir as a tar archive to w.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ImageExists reports whether imageName is in the daemon's image store.
+func (c *APIClient) ImageExists(ctx context.Context, imageName string) (bool, error) {
+	err := c.doJSON(ctx, http.MethodGet, "/images/"+imageName+"/json", nil, nil, nil)
+	var se *statusError
+	if errors.As(err, &se) && se.Status == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Pull pulls imageName from its registry, streaming the progress. The
+// daemon's credentials are not used, so private registries need the cli
+// client.
+func (c *APIClient) Pull(ctx context.Context, imageName string) error {
+	query := url.Values{"fromImage": {imageName}}
+	if !hasTag(imageName) {
+		// Without a tag the daemon would pull every tag of the repository.
+		query.Set("tag", "latest")
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/images/create", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg buildMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading pull output: %w", err)
+		}
+		switch {
+		case msg.Error != "":
+			return fmt.Errorf("%s", strings.TrimSpace(msg.Error))
+		case msg.Progress != "":
+			// Download progress is redrawn many times a second; skip it.
+		case msg.ID != "":
+			_, _ = fmt.Fprintf(c.stdout(), "%s: %s\n", msg.ID, msg.Status)
+		case msg.Status != "":
+			_, _ = fmt.Fprintln(c.stdout(), msg.Status)
+		}
+	}
+}
+
+// hasTag reports whether the image reference names a tag or digest, as in
+// "registry:5000/name:tag" or "name@sha256:...".
+func hasTag(ref string) bool {
+	if strings.Contains(ref, "@") {
+		return true
+	}
+	return strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":")
+}
+
+// VolumeCreate creates a named volume (idempotent).
+func (c *APIClient) VolumeCreate(ctx context.Context, volumeName string) error {
+	return c.doJSON(ctx, http.MethodPost, "/volumes/create", nil, map[string]string{"Name": volumeName}, nil)
+}
+
+// containerCreateRequest is the subset of POST /containers/create used by aw.
+type containerCreateRequest struct {
+	Image        string
+	Cmd          []string `json:",omitempty"`
+	Env          []string `json:",omitempty"`
+	WorkingDir   string   `json:",omitempty"`
+	User         string   `json:",omitempty"`
+	Tty          bool
+	OpenStdin    bool
+	StdinOnce    bool
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	HostConfig   hostConfig
+}
+
+type hostConfig struct {
+	Binds      []string `json:",omitempty"`
+	AutoRemove bool
+	UsernsMode string `json:",omitempty"`
+}
+
+// createRequest translates a RunConfig into a container create request
+// equivalent to `docker run -it --rm`.
+func createRequest(config RunConfig) containerCreateRequest {
+	req := containerCreateRequest{
+		Image:        config.ImageName,
+		Cmd:          config.Command,
+		WorkingDir:   config.WorkDir,
+		Tty:          true,
+		OpenStdin:    true,
+		StdinOnce:    true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		User:         config.User,
+		HostConfig:   hostConfig{AutoRemove: true, UsernsMode: config.UserNS},
+	}
+	for key, val := range config.EnvVars {
+		req.Env = append(req.Env, fmt.Sprintf("%s=%s", key, val))
+	}
+	for _, m := range config.Mounts {
+		bind := fmt.Sprintf("%s:%s", m.Source, m.Target)
+		if m.ReadOnly {
+			bind += ":ro"
+		}
+		req.HostConfig.Binds = append(req.HostConfig.Binds, bind)
+	}
+	return req
+}
+
+// Run creates and starts a container, attaches the terminal to it and waits
+// for it to exit. A non-zero exit status is returned as an error.
+func (c *APIClient) Run(ctx context.Context, config RunConfig) error {
+	var query url.Values
+	if config.Name != "" {
+		query = url.Values{"name": {config.Name}}
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/create", query, createRequest(config), &created); err != nil {
+		return fmt.Errorf("creating container: %w", err)
+	}
+
+	// Register the wait before starting so a fast exit (and AutoRemove)
+	// cannot race us.
+	waitResp, err := c.do(ctx, http.MethodPost, "/containers/"+created.ID+"/wait", url.Values{"condition": {"next-exit"}}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("waiting for container: %w", err)
+	}
+	defer func() { _ = waitResp.Body.Close() }()
+
+	stream, err := c.attach(ctx, created.ID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+		return fmt.Errorf("starting container: %w", err)
+	}
+
+	outputDone := c.pipeTerminal(ctx, created.ID, stream)
+
+	var result struct {
+		StatusCode int
+		Error      *struct{ Message string }
+	}
+	if err := json.NewDecoder(waitResp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("waiting for container: %w", err)
+	}
+	<-outputDone
+
+	if result.Error != nil && result.Error.Message != "" {
+		return fmt.Errorf("container: %s", result.Error.Message)
+	}
+	if result.StatusCode != 0 {
+		return fmt.Errorf("container exited with status %d", result.StatusCode)
+	}
+	return nil
+}
+
+// Output runs a container without a terminal until it exits and returns its
+// standard output. A non-zero exit status is returned as an error carrying
+// the container's standard error.
+func (c *APIClient) Output(ctx context.Context, config RunConfig) (string, error) {
+	req := createRequest(config)
+	req.Tty, req.OpenStdin, req.StdinOnce, req.AttachStdin = false, false, false, false
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/create", nil, req, &created); err != nil {
+		return "", fmt.Errorf("creating container: %w", err)
+	}
+
+	waitResp, err := c.do(ctx, http.MethodPost, "/containers/"+created.ID+"/wait", url.Values{"condition": {"next-exit"}}, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("waiting for container: %w", err)
+	}
+	defer func() { _ = waitResp.Body.Close() }()
+
+	stream, err := c.attachStream(ctx, created.ID, url.Values{"stream": {"1"}, "stdout": {"1"}, "stderr": {"1"}})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+		return "", fmt.Errorf("starting container: %w", err)
+	}
+
+	var stdout, stderr strings.Builder
+	if err := demux(stream, &stdout, &stderr); err != nil {
+		return "", fmt.Errorf("reading container output: %w", err)
+	}
+
+	var result struct {
+		StatusCode int
+		Error      *struct{ Message string }
+	}
+	if err := json.NewDecoder(waitResp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("waiting for container: %w", err)
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		return "", fmt.Errorf("container: %s", result.Error.Message)
+	}
+	if result.StatusCode != 0 {
+		return stdout.String(), exitError(result.StatusCode, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// demux splits the stream of a container without a terminal, in which
+// every frame starts with an 8-byte header naming the stream and the
+// length, into stdout and stderr.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// Attach attaches the terminal to a running container until the container
+// exits or the user detaches.
+func (c *APIClient) Attach(ctx context.Context, containerName string) error {
+	stream, err := c.attach(ctx, containerName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+	// Detach when ctx is cancelled, leaving the container running.
+	stop := context.AfterFunc(ctx, func() { _ = stream.Close() })
+	defer stop()
+
+	<-c.pipeTerminal(ctx, containerName, stream)
+	return ctx.Err()
+}
+
+// attach opens a hijacked stdin/stdout stream to the container.
+func (c *APIClient) attach(ctx context.Context, id string) (io.ReadWriteCloser, error) {
+	return c.attachStream(ctx, id, url.Values{"stream": {"1"}, "stdin": {"1"}, "stdout": {"1"}, "stderr": {"1"}})
+}
+
+// attachStream opens a hijacked stream to the container for the streams
+// selected by query.
+func (c *APIClient) attachStream(ctx context.Context, id string, query url.Values) (io.ReadWriteCloser, error) {
+	header := http.Header{"Connection": {"Upgrade"}, "Upgrade": {"tcp"}}
+	var conn net.Conn
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { conn = info.Conn },
+	})
+	resp, err := c.do(ctx, http.MethodPost, "/containers/"+id+"/attach", query, nil, header)
+	if err != nil {
+		return nil, fmt.Errorf("attaching to container: %w", err)
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("attaching to container: daemon did not upgrade the connection (status %d)", resp.StatusCode)
+	}
+	return &hijackedStream{ReadWriteCloser: rwc, conn: conn}, nil
+}
+
+// hijackedStream is the stream of an attach request. Closing its writing
+// half tells the container that stdin has ended.
+type hijackedStream struct {
+	io.ReadWriteCloser
+	conn net.Conn // the connection under the stream: a *net.UnixConn or *net.TCPConn
+}
+
+// CloseWrite shuts down the writing half of the connection.
+func (s *hijackedStream) CloseWrite() error {
+	if cw, ok := s.conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// pipeTerminal copies stdin to the stream and the stream to stdout, putting
+// the local terminal into raw mode and keeping the container's TTY size in
+// sync with it. The returned channel is closed once the output stream has
+// ended and the terminal has been restored.
+func (c *APIClient) pipeTerminal(ctx context.Context, id string, stream io.ReadWriteCloser) <-chan struct{} {
+	f, ok := c.stdin().(*os.File)
+	if !ok || !isTerminal(int(f.Fd())) {
+		return c.copyStreams(stream)
+	}
+	fd := int(f.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return c.copyStreams(stream)
+	}
+
+	c.resize(ctx, id, fd)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+
+	out := c.copyStreams(stream)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer restore()
+		defer signal.Stop(winch)
+		for {
+			select {
+			case <-winch:
+				c.resize(ctx, id, fd)
+			case <-out:
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// copyStreams copies stdin to the stream and the stream to stdout. The
+// returned channel is closed once the output stream has ended and stdin is
+// no longer read, so that nothing typed after the session is lost to it.
+// The end of stdin is passed on to the container.
+func (c *APIClient) copyStreams(stream io.ReadWriteCloser) <-chan struct{} {
+	output := make(chan struct{})
+	input := make(chan struct{})
+	go func() {
+		defer close(input)
+		if c.copyInput(stream, output) {
+			if cw, ok := stream.(interface{ CloseWrite() error }); ok {
+				_ = cw.CloseWrite()
+			}
+		}
+	}()
+	go func() {
+		_, _ = io.Copy(c.stdout(), stream)
+		close(output)
+	}()
+	done := make(chan struct{})
+	go func() {
+		<-output
+		<-input
+		close(done)
+	}()
+	return done
+}
+
+// stdinPoll is how long copyInput waits for input before checking whether
+// to stop.
+const stdinPoll = 50 * time.Millisecond
+
+// copyInput copies stdin to w until stdin ends, and reports whether it did,
+// or until stop is closed. The process's stdin is only read once it has
+// input, so that a read never outlasts stop. Other readers cannot be
+// interrupted; they are left to finish on their own.
+func (c *APIClient) copyInput(w io.Writer, stop <-chan struct{}) bool {
+	f, ok := c.stdin().(*os.File)
+	if !ok {
+		ended := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(w, c.stdin())
+			close(ended)
+		}()
+		select {
+		case <-ended:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	fd := int(f.Fd())
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		ready, err := waitReadable(fd, stdinPoll)
+		if err == syscall.EINTR || (err == nil && !ready) {
+			continue
+		}
+		if err != nil {
+			return false
+		}
+		n, err := syscall.Read(fd, buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return false
+			}
+			continue
+		}
+		switch err {
+		case nil:
+			return true // EOF
+		case syscall.EINTR, syscall.EAGAIN:
+			continue
+		default:
+			return false
+		}
+	}
+}
+
+// resize sets the container's TTY size to that of the local terminal.
+func (c *APIClient) resize(ctx context.Context, id string, fd int) {
+	h, w, err := terminalSize(fd)
+	if err != nil || h == 0 || w == 0 {
+		return
+	}
+	query := url.Values{"h": {strconv.Itoa(h)}, "w": {strconv.Itoa(w)}}
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/"+id+"/resize", query, nil, nil); err != nil {
+		_, _ = fmt.Fprintf(c.stderr(), "Warning: resizing container TTY: %v\n", err)
+	}
+}
+
+// Stop stops a running container.
+func (c *APIClient) Stop(ctx context.Context, containerName string) error {
+	return c.doJSON(ctx, http.MethodPost, "/containers/"+containerName+"/stop", nil, nil, nil)
+}
@@ -0,0 +1,579 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDaemon serves handler on a unix socket and returns an APIClient
+// pointed at it.
+func newTestDaemon(t *testing.T, handler http.Handler) (*APIClient, *bytes.Buffer) {
+	t.Helper()
+	// Keep the socket path short: unix socket paths are limited to ~100 bytes.
+	dir, err := os.MkdirTemp("", "aw-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "d.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	_ = srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	out := &bytes.Buffer{}
+	return &APIClient{
+		Host:   "unix://" + socket,
+		Stdin:  strings.NewReader(""),
+		Stdout: out,
+		Stderr: io.Discard,
+	}, out
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		kind    string
+		runtime string
+		want    string
+		wantErr bool
+	}{
+		{"", "", "*docker.ShellClient", false},
+		{ClientCLI, RuntimePodman, "*docker.ShellClient", false},
+		{ClientAPI, RuntimeAuto, "*docker.APIClient", false},
+		{"grpc", "", "", true},
+		{ClientCLI, "lxc", "", true},
+	}
+	for _, tt := range tests {
+		c, err := NewClient(tt.kind, tt.runtime)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewClient(%q, %q) expected error", tt.kind, tt.runtime)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("NewClient(%q, %q) error: %v", tt.kind, tt.runtime, err)
+		}
+		if got := fmt.Sprintf("%T", c); got != tt.want {
+			t.Errorf("NewClient(%q, %q) = %s, want %s", tt.kind, tt.runtime, got, tt.want)
+		}
+	}
+}
+
+func TestNewAPIClient_DockerHost(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "")
+	c := NewAPIClient()
+	if c.Host != "" {
+		t.Errorf("Host = %q, want empty until CheckAvailable", c.Host)
+	}
+	if err := c.init(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != DefaultHost {
+		t.Errorf("Host = %q, want %q", c.Host, DefaultHost)
+	}
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+	if got := NewAPIClient().Host; got != "tcp://127.0.0.1:2375" {
+		t.Errorf("Host = %q, want DOCKER_HOST value", got)
+	}
+}
+
+func TestAPIClient_UnsupportedHost(t *testing.T) {
+	c := &APIClient{Host: "ssh://user@host"}
+	err := c.CheckAvailable()
+	if err == nil || !strings.Contains(err.Error(), "unsupported docker host") {
+		t.Errorf("CheckAvailable() error = %v, want unsupported docker host", err)
+	}
+}
+
+func TestAPIClient_CheckAvailable(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			_, _ = io.WriteString(w, "OK")
+		case "/version":
+			_, _ = io.WriteString(w, `{"Platform":{"Name":"Docker Engine - Community"}}`)
+		default:
+			// Older daemons may not answer /info; detection must not fail.
+			writeAPIError(w, http.StatusNotFound, "page not found")
+		}
+	}))
+	if err := c.CheckAvailable(); err != nil {
+		t.Errorf("CheckAvailable() error: %v", err)
+	}
+	if got := c.RuntimeInfo(); got != (RuntimeInfo{Name: RuntimeDocker}) {
+		t.Errorf("RuntimeInfo() = %+v, want docker", got)
+	}
+}
+
+func TestAPIClient_CheckAvailable_DetectsPodman(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			_, _ = io.WriteString(w, "OK")
+		case "/version":
+			_, _ = io.WriteString(w, `{"Components":[{"Name":"Podman Engine"}]}`)
+		case "/info":
+			_, _ = io.WriteString(w, `{"SecurityOptions":["name=seccomp","name=rootless"]}`)
+		}
+	}))
+	if err := c.CheckAvailable(); err != nil {
+		t.Fatalf("CheckAvailable() error: %v", err)
+	}
+	want := RuntimeInfo{Name: RuntimePodman, Rootless: true}
+	if got := c.RuntimeInfo(); got != want {
+		t.Errorf("RuntimeInfo() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAPIClient_CheckAvailable_NoDaemon(t *testing.T) {
+	c := &APIClient{Host: "unix:///nonexistent/docker.sock"}
+	err := c.CheckAvailable()
+	if err == nil || !strings.Contains(err.Error(), "cannot connect to the docker daemon") {
+		t.Errorf("CheckAvailable() error = %v, want connection error", err)
+	}
+}
+
+func TestAPIClient_VolumeCreate(t *testing.T) {
+	var got map[string]string
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/volumes/create" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = io.WriteString(w, `{"Name":"claude-code-local"}`)
+	}))
+
+	if err := c.VolumeCreate(context.Background(), "claude-code-local"); err != nil {
+		t.Fatalf("VolumeCreate() error: %v", err)
+	}
+	if got["Name"] != "claude-code-local" {
+		t.Errorf("request body = %v, want Name=claude-code-local", got)
+	}
+}
+
+func TestAPIClient_Build(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "entrypoint.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	var tag string
+	c, out := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag = r.URL.Query().Get("t")
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("reading build context: %v", err)
+				return
+			}
+			files = append(files, hdr.Name)
+		}
+		_, _ = io.WriteString(w, `{"stream":"Step 1/1 : FROM scratch\n"}`+"\n")
+		_, _ = io.WriteString(w, `{"stream":"Successfully tagged img:abc\n"}`+"\n")
+	}))
+
+	if err := c.Build(context.Background(), "img:abc", contextDir, BuildOptions{}); err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	if tag != "img:abc" {
+		t.Errorf("tag = %q, want img:abc", tag)
+	}
+	if strings.Join(files, ",") != "Dockerfile,entrypoint.sh" {
+		t.Errorf("build context files = %v", files)
+	}
+	if !strings.Contains(out.String(), "Successfully tagged img:abc") {
+		t.Errorf("build output not streamed, got %q", out.String())
+	}
+}
+
+func TestAPIClient_BuildError(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, `{"stream":"Step 1/2 : RUN false\n"}`+"\n")
+		_, _ = io.WriteString(w, `{"errorDetail":{"message":"returned a non-zero code: 1"},"error":"The command '/bin/sh -c false' returned a non-zero code: 1"}`+"\n")
+	}))
+
+	err := c.Build(context.Background(), "img", t.TempDir(), BuildOptions{})
+	if err == nil || !strings.Contains(err.Error(), "returned a non-zero code: 1") {
+		t.Errorf("Build() error = %v, want daemon error message", err)
+	}
+}
+
+func TestAPIClient_BuildOptions(t *testing.T) {
+	var query url.Values
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+
+	opts := BuildOptions{Args: map[string]string{"GO_VERSION": "1.23"}, Target: "dev", Platform: "linux/amd64", NoCache: true}
+	if err := c.Build(context.Background(), "img", t.TempDir(), opts); err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	want := url.Values{
+		"t":         {"img"},
+		"rm":        {"1"},
+		"buildargs": {`{"GO_VERSION":"1.23"}`},
+		"target":    {"dev"},
+		"platform":  {"linux/amd64"},
+		"nocache":   {"1"},
+	}
+	if query.Encode() != want.Encode() {
+		t.Errorf("query = %s, want %s", query.Encode(), want.Encode())
+	}
+}
+
+func TestAPIClient_ImageExists(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/claude-code-docker:abc/json":
+			_, _ = io.WriteString(w, `{"Id":"sha256:abc"}`)
+		case "/images/claude-code-docker:def/json":
+			writeAPIError(w, http.StatusNotFound, "No such image: claude-code-docker:def")
+		default:
+			writeAPIError(w, http.StatusInternalServerError, "boom")
+		}
+	}))
+
+	for _, tt := range []struct {
+		image   string
+		want    bool
+		wantErr bool
+	}{
+		{"claude-code-docker:abc", true, false},
+		{"claude-code-docker:def", false, false},
+		{"other", false, true},
+	} {
+		got, err := c.ImageExists(context.Background(), tt.image)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ImageExists(%q) = %v, %v; want %v, error %v", tt.image, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAPIClient_Pull(t *testing.T) {
+	var queries []url.Values
+	c, out := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/images/create" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		queries = append(queries, r.URL.Query())
+		if r.URL.Query().Get("fromImage") == "acme/private" {
+			_, _ = io.WriteString(w, `{"error":"pull access denied for acme/private"}`+"\n")
+			return
+		}
+		_, _ = io.WriteString(w, `{"status":"Pulling from acme/claude","id":"1.4"}`+"\n")
+		_, _ = io.WriteString(w, `{"status":"Downloading","progress":"[=>   ]","id":"a1b2"}`+"\n")
+		_, _ = io.WriteString(w, `{"status":"Status: Downloaded newer image for acme/claude:1.4"}`+"\n")
+	}))
+
+	if err := c.Pull(context.Background(), "acme/claude:1.4"); err != nil {
+		t.Fatalf("Pull() error: %v", err)
+	}
+	if out.String() != "1.4: Pulling from acme/claude\nStatus: Downloaded newer image for acme/claude:1.4\n" {
+		t.Errorf("pull output = %q", out.String())
+	}
+	if err := c.Pull(context.Background(), "acme/private"); err == nil || !strings.Contains(err.Error(), "pull access denied") {
+		t.Errorf("Pull() error = %v, want daemon message", err)
+	}
+	if got := queries[0].Encode(); got != "fromImage=acme%2Fclaude%3A1.4" {
+		t.Errorf("query = %s", got)
+	}
+	if got := queries[1].Get("tag"); got != "latest" {
+		t.Errorf("tag for an untagged reference = %q, want latest", got)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	tests := map[string]bool{
+		"alpine":                         false,
+		"alpine:3.20":                    true,
+		"localhost:5000/acme/claude":     false,
+		"localhost:5000/acme/claude:1.4": true,
+		"acme/claude@sha256:0123abcd":    true,
+	}
+	for ref, want := range tests {
+		if got := hasTag(ref); got != want {
+			t.Errorf("hasTag(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestAPIClient_StopNotFound(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "No such container: aw-1234")
+	}))
+
+	err := c.Stop(context.Background(), "aw-1234")
+	if err == nil || !strings.Contains(err.Error(), "No such container: aw-1234") {
+		t.Errorf("Stop() error = %v, want daemon message", err)
+	}
+}
+
+// fakeRunDaemon implements the create/wait/attach/start sequence used by Run.
+type fakeRunDaemon struct {
+	t         *testing.T
+	exitCode  int
+	output    string
+	echoStdin bool // reply with stdin, once it ends, instead of output
+	mu        sync.Mutex
+	created   containerCreateRequest
+	name      string
+	calls     []string
+	started   chan struct{}
+	startOnce sync.Once
+}
+
+func (d *fakeRunDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.calls = append(d.calls, r.URL.Path)
+	d.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/containers/create":
+		d.name = r.URL.Query().Get("name")
+		_ = json.NewDecoder(r.Body).Decode(&d.created)
+		_, _ = io.WriteString(w, `{"Id":"c0ffee"}`)
+	case "/containers/c0ffee/wait":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-d.started
+		_, _ = fmt.Fprintf(w, `{"StatusCode":%d}`, d.exitCode)
+	case "/containers/c0ffee/attach":
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			d.t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		_ = buf.Flush()
+		go func() {
+			<-d.started
+			if d.echoStdin {
+				_, _ = io.Copy(conn, buf)
+			} else {
+				_, _ = io.WriteString(conn, d.output)
+			}
+			_ = conn.Close()
+		}()
+	case "/containers/c0ffee/start":
+		w.WriteHeader(http.StatusNoContent)
+		d.startOnce.Do(func() { close(d.started) })
+	default:
+		writeAPIError(w, http.StatusNotFound, "unexpected "+r.URL.Path)
+	}
+}
+
+func TestAPIClient_Run(t *testing.T) {
+	d := &fakeRunDaemon{t: t, output: "hello from container\n", started: make(chan struct{})}
+	c, out := newTestDaemon(t, d)
+
+	err := c.Run(context.Background(), RunConfig{
+		ImageName: "img:abc",
+		Name:      "aw-1234",
+		Mounts: []Mount{
+			{Source: "claude-code-local", Target: "/home/claude/.local", IsVolume: true},
+			{Source: "/host/ssh", Target: "/home/claude/.ssh-host", ReadOnly: true},
+		},
+		EnvVars: map[string]string{"FOO": "bar"},
+		WorkDir: "/workspace",
+		Command: []string{"claude"},
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if d.name != "aw-1234" {
+		t.Errorf("container name = %q, want aw-1234", d.name)
+	}
+	if d.created.Image != "img:abc" || d.created.WorkingDir != "/workspace" || !d.created.Tty || !d.created.HostConfig.AutoRemove {
+		t.Errorf("create request = %+v", d.created)
+	}
+	wantBinds := "claude-code-local:/home/claude/.local,/host/ssh:/home/claude/.ssh-host:ro"
+	if got := strings.Join(d.created.HostConfig.Binds, ","); got != wantBinds {
+		t.Errorf("Binds = %q, want %q", got, wantBinds)
+	}
+	if d.created.User != "" || d.created.HostConfig.UsernsMode != "" {
+		t.Errorf("User/UsernsMode = %q/%q, want defaults", d.created.User, d.created.HostConfig.UsernsMode)
+	}
+	if len(d.created.Env) != 1 || d.created.Env[0] != "FOO=bar" {
+		t.Errorf("Env = %v, want [FOO=bar]", d.created.Env)
+	}
+	if out.String() != "hello from container\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	wantOrder := []string{"/containers/create", "/containers/c0ffee/wait", "/containers/c0ffee/attach", "/containers/c0ffee/start"}
+	if strings.Join(d.calls, ",") != strings.Join(wantOrder, ",") {
+		t.Errorf("call order = %v, want %v", d.calls, wantOrder)
+	}
+}
+
+func TestAPIClient_RunClosesStdin(t *testing.T) {
+	d := &fakeRunDaemon{t: t, echoStdin: true, started: make(chan struct{})}
+	c, out := newTestDaemon(t, d)
+	c.Stdin = strings.NewReader("piped input\n")
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(context.Background(), RunConfig{ImageName: "img", Command: []string{"cat"}}) }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return: the end of stdin was not passed on")
+	}
+	if out.String() != "piped input\n" {
+		t.Errorf("output = %q, want stdin echoed", out.String())
+	}
+}
+
+func TestAPIClient_RunStopsReadingStdin(t *testing.T) {
+	d := &fakeRunDaemon{t: t, output: "bye\n", started: make(chan struct{})}
+	c, _ := newTestDaemon(t, d)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close(); _ = w.Close() }()
+	c.Stdin = r
+
+	if err := c.Run(context.Background(), RunConfig{ImageName: "img", Command: []string{"true"}}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	// Input typed after the session is left for whoever reads stdin next.
+	if _, err := io.WriteString(w, "next\n"); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(r, got); err != nil || string(got) != "next\n" {
+		t.Errorf("read %q, %v after Run(), want the input left on stdin", got, err)
+	}
+}
+
+func TestAPIClient_AttachStopsOnCancel(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = buf.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		_ = buf.Flush()
+		// Keep the session open until the test ends.
+		<-done
+		_ = conn.Close()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Attach(ctx, "aw-1234") }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Attach() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Attach() did not return after ctx was cancelled")
+	}
+}
+
+func TestAPIClient_RunNonZeroExit(t *testing.T) {
+	d := &fakeRunDaemon{t: t, exitCode: 3, started: make(chan struct{})}
+	c, _ := newTestDaemon(t, d)
+
+	err := c.Run(context.Background(), RunConfig{ImageName: "img", Command: []string{"false"}})
+	if err == nil || !strings.Contains(err.Error(), "exited with status 3") {
+		t.Errorf("Run() error = %v, want exit status 3", err)
+	}
+}
+
+func TestAPIClient_RunCreateFails(t *testing.T) {
+	c, _ := newTestDaemon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusConflict, `Conflict. The container name "/aw-1234" is already in use`)
+	}))
+
+	err := c.Run(context.Background(), RunConfig{ImageName: "img", Name: "aw-1234"})
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Run() error = %v, want conflict message", err)
+	}
+}
+
+// frame wraps payload in the header of a multiplexed container stream.
+func frame(stream byte, payload string) string {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return string(header) + payload
+}
+
+func TestAPIClient_Output(t *testing.T) {
+	d := &fakeRunDaemon{t: t, output: frame(1, "claude\n") + frame(2, "warning\n") + frame(1, "/home/claude/.claude\n"), started: make(chan struct{})}
+	c, out := newTestDaemon(t, d)
+
+	got, err := c.Output(context.Background(), RunConfig{ImageName: "img", Command: []string{"id", "-un"}})
+	if err != nil {
+		t.Fatalf("Output() error: %v", err)
+	}
+	if got != "claude\n/home/claude/.claude\n" {
+		t.Errorf("Output() = %q, want stdout only", got)
+	}
+	if d.created.Tty || d.created.OpenStdin || !d.created.HostConfig.AutoRemove {
+		t.Errorf("create request = %+v, want no terminal and no stdin", d.created)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Output() wrote %q to the terminal", out.String())
+	}
+}
+
+func TestAPIClient_OutputNonZeroExit(t *testing.T) {
+	d := &fakeRunDaemon{t: t, exitCode: 126, output: frame(2, "exec: sh: not found\n"), started: make(chan struct{})}
+	c, _ := newTestDaemon(t, d)
+
+	_, err := c.Output(context.Background(), RunConfig{ImageName: "img", Command: []string{"sh"}})
+	if err == nil || !strings.Contains(err.Error(), "status 126: exec: sh: not found") {
+		t.Errorf("Output() error = %v, want the status and stderr", err)
+	}
+}
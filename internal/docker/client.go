@@ -2,9 +2,13 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // Mount represents a Docker mount (bind mount or named volume).
@@ -18,24 +22,85 @@ type Mount struct {
 // RunConfig holds the configuration for running a Docker container.
 type RunConfig struct {
 	ImageName string
+	Name      string // container name; empty lets docker pick one
 	Mounts    []Mount
 	EnvVars   map[string]string
 	WorkDir   string
 	Command   []string
+	User      string // user the entrypoint runs as; empty uses the image default
+	UserNS    string // user namespace mode, e.g. "keep-id" (see RuntimeInfo.UserNS)
+}
+
+// BuildOptions holds the optional settings of an image build.
+type BuildOptions struct {
+	Args     map[string]string // build args
+	Target   string            // stage of a multi-stage Dockerfile to build
+	Platform string            // platform to build for, e.g. "linux/amd64"
+	NoCache  bool              // do not use cached layers
 }
 
 // Client is the interface for Docker operations.
 type Client interface {
 	CheckAvailable() error
-	Build(ctx context.Context, imageName, contextDir string) error
+	Build(ctx context.Context, imageName, contextDir string, opts BuildOptions) error
+	// ImageExists reports whether imageName is present locally.
+	ImageExists(ctx context.Context, imageName string) (bool, error)
+	// Pull pulls imageName from its registry.
+	Pull(ctx context.Context, imageName string) error
 	VolumeCreate(ctx context.Context, volumeName string) error
 	Run(ctx context.Context, config RunConfig) error
+	// Output runs a container without a terminal until it exits and returns
+	// its standard output.
+	Output(ctx context.Context, config RunConfig) (string, error)
+	Stop(ctx context.Context, containerName string) error
+	Attach(ctx context.Context, containerName string) error
+	// RuntimeInfo reports the container engine behind the client. It is only
+	// meaningful after a successful CheckAvailable.
+	RuntimeInfo() RuntimeInfo
+}
+
+// Client kinds accepted by NewClient.
+const (
+	ClientCLI = "cli"
+	ClientAPI = "api"
+)
+
+// NewClient returns the Client implementation for kind: ClientCLI (or empty)
+// for the docker CLI, ClientAPI for the Engine API. runtime selects the
+// container engine (RuntimeDocker, RuntimePodman or RuntimeAuto); empty
+// means RuntimeDocker.
+func NewClient(kind, runtime string) (Client, error) {
+	switch runtime {
+	case "", RuntimeDocker, RuntimePodman, RuntimeAuto:
+		// ok
+	default:
+		return nil, fmt.Errorf("unknown container runtime: %q (must be %q, %q or %q)", runtime, RuntimeDocker, RuntimePodman, RuntimeAuto)
+	}
+
+	switch kind {
+	case "", ClientCLI:
+		return &ShellClient{Runtime: runtime}, nil
+	case ClientAPI:
+		c := NewAPIClient()
+		c.Runtime = runtime
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown docker client: %q (must be %q or %q)", kind, ClientCLI, ClientAPI)
+	}
 }
 
-// ShellClient implements Client by shelling out to the docker CLI.
+// ShellClient implements Client by shelling out to the docker CLI, or to
+// podman, whose CLI is docker-compatible.
 type ShellClient struct {
-	// DockerPath is the path to the docker binary. Defaults to "docker".
+	// DockerPath is the path to the container CLI. If empty, CheckAvailable
+	// picks "docker" or "podman" according to Runtime.
 	DockerPath string
+
+	// Runtime selects the container engine when DockerPath is empty:
+	// RuntimeDocker (default), RuntimePodman, or RuntimeAuto.
+	Runtime string
+
+	info RuntimeInfo
 }
 
 // NewShellClient creates a new ShellClient with default settings.
@@ -47,27 +112,126 @@ func (c *ShellClient) dockerCmd() string {
 	if c.DockerPath != "" {
 		return c.DockerPath
 	}
-	return "docker"
+	return runtimeCandidates(c.Runtime)[0]
 }
 
-// CheckAvailable verifies that docker is installed and the daemon is running.
+// CheckAvailable verifies that the container CLI is installed and its engine
+// is running, and detects which runtime it is. With RuntimeAuto, docker is
+// tried first and podman second.
 func (c *ShellClient) CheckAvailable() error {
-	if _, err := exec.LookPath(c.dockerCmd()); err != nil {
-		return fmt.Errorf("docker is not installed or not in PATH")
+	if c.DockerPath != "" {
+		return c.check(c.DockerPath)
 	}
 
-	cmd := exec.Command(c.dockerCmd(), "info")
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker daemon is not running")
+	candidates := runtimeCandidates(c.Runtime)
+	var errs []string
+	for _, bin := range candidates {
+		err := c.check(bin)
+		if err == nil {
+			c.DockerPath = bin
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(candidates) == 1 {
+		return errors.New(errs[0])
 	}
+	return fmt.Errorf("no container runtime available (%s)", strings.Join(errs, "; "))
+}
+
+// check probes bin and records the runtime behind it. A "docker" binary may
+// be podman's compatibility wrapper, so the runtime is taken from its
+// version string rather than its name.
+func (c *ShellClient) check(bin string) error {
+	name := filepath.Base(bin)
+	if _, err := exec.LookPath(bin); err != nil {
+		return fmt.Errorf("%s is not installed or not in PATH", name)
+	}
+
+	info := RuntimeInfo{Name: RuntimeDocker}
+	if out, err := exec.Command(bin, "--version").Output(); err == nil &&
+		strings.Contains(strings.ToLower(string(out)), "podman") {
+		info.Name = RuntimePodman
+	}
+
+	format := "{{json .SecurityOptions}}"
+	if info.Name == RuntimePodman {
+		format = "{{.Host.Security.Rootless}}"
+	}
+	out, err := exec.Command(bin, "info", "--format", format).Output()
+	if err != nil {
+		if info.Name == RuntimePodman {
+			return fmt.Errorf("%s is not working (%s info failed)", name, name)
+		}
+		return fmt.Errorf("%s daemon is not running", name)
+	}
+	// podman prints "true"; docker lists "name=rootless" among its security options.
+	info.Rootless = strings.Contains(string(out), "rootless") || strings.TrimSpace(string(out)) == "true"
+
+	c.info = info
 	return nil
 }
 
+// RuntimeInfo reports the runtime detected by CheckAvailable.
+func (c *ShellClient) RuntimeInfo() RuntimeInfo {
+	return c.info
+}
+
 // Build builds a Docker image from the given build context directory.
-func (c *ShellClient) Build(ctx context.Context, imageName, contextDir string) error {
-	cmd := exec.CommandContext(ctx, c.dockerCmd(), "build", "-t", imageName, contextDir)
+func (c *ShellClient) Build(ctx context.Context, imageName, contextDir string, opts BuildOptions) error {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), BuildArgs(imageName, contextDir, opts)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// BuildArgs constructs the docker CLI arguments for building imageName from
+// contextDir. This is exported for testing.
+func BuildArgs(imageName, contextDir string, opts BuildOptions) []string {
+	args := []string{"build", "-t", imageName}
+	keys := make([]string, 0, len(opts.Args))
+	for key := range opts.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--build-arg", key+"="+opts.Args[key])
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	return append(args, contextDir)
+}
+
+// ImageExists reports whether imageName is in the local image store.
+func (c *ShellClient) ImageExists(ctx context.Context, imageName string) (bool, error) {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), "image", "inspect", "--format", "{{.Id}}", imageName)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+	// docker says "No such image", podman "image not known".
+	msg := strings.TrimSpace(stderr.String())
+	if lower := strings.ToLower(msg); strings.Contains(lower, "no such image") || strings.Contains(lower, "image not known") {
+		return false, nil
+	}
+	if msg != "" {
+		return false, fmt.Errorf("inspecting image %s: %s", imageName, msg)
+	}
+	return false, fmt.Errorf("inspecting image %s: %w", imageName, err)
+}
+
+// Pull pulls imageName from its registry.
+func (c *ShellClient) Pull(ctx context.Context, imageName string) error {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), "pull", imageName)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -84,10 +248,27 @@ func (c *ShellClient) VolumeCreate(ctx context.Context, volumeName string) error
 // BuildRunArgs constructs the docker CLI arguments for a RunConfig.
 // This is exported for testing.
 func BuildRunArgs(config RunConfig) []string {
-	args := []string{"run", "-it", "--rm"}
+	return runArgs(config, "-it")
+}
+
+// runArgs constructs the arguments of `docker run --rm` with the given
+// extra flags.
+func runArgs(config RunConfig, flags ...string) []string {
+	args := append([]string{"run"}, flags...)
+	args = append(args, "--rm")
 
-	for key, val := range config.EnvVars {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", key, val))
+	if config.Name != "" {
+		args = append(args, "--name", config.Name)
+	}
+
+	// Sorted so the same config always yields the same command line.
+	keys := make([]string, 0, len(config.EnvVars))
+	for key := range config.EnvVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", key, config.EnvVars[key]))
 	}
 
 	for _, m := range config.Mounts {
@@ -102,12 +283,43 @@ func BuildRunArgs(config RunConfig) []string {
 		args = append(args, "--workdir", config.WorkDir)
 	}
 
+	if config.UserNS != "" {
+		args = append(args, "--userns", config.UserNS)
+	}
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+
 	args = append(args, config.ImageName)
 	args = append(args, config.Command...)
 
 	return args
 }
 
+// Output runs a container without a terminal until it exits and returns its
+// standard output. A non-zero exit status is returned as an error carrying
+// the container's standard error.
+func (c *ShellClient) Output(ctx context.Context, config RunConfig) (string, error) {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), runArgs(config)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitError(exitErr.ExitCode(), stderr.String())
+	}
+	return string(out), err
+}
+
+// exitError reports a container that exited with status, with what it
+// printed on standard error.
+func exitError(status int, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("container exited with status %d: %s", status, msg)
+	}
+	return fmt.Errorf("container exited with status %d", status)
+}
+
 // Run runs a Docker container interactively with the given RunConfig.
 func (c *ShellClient) Run(ctx context.Context, config RunConfig) error {
 	args := BuildRunArgs(config)
@@ -117,3 +329,20 @@ func (c *ShellClient) Run(ctx context.Context, config RunConfig) error {
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
+
+// Stop stops a running container by name.
+func (c *ShellClient) Stop(ctx context.Context, containerName string) error {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), "stop", containerName)
+	cmd.Stdout = nil
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Attach attaches the current terminal to a running container.
+func (c *ShellClient) Attach(ctx context.Context, containerName string) error {
+	cmd := exec.CommandContext(ctx, c.dockerCmd(), "attach", containerName)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -1,6 +1,9 @@
 package docker
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -160,3 +163,208 @@ func TestBuildRunArgsNoOptionalFields(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildRunArgsWithName(t *testing.T) {
+	args := BuildRunArgs(RunConfig{ImageName: "img", Name: "aw-1234abcd"})
+
+	found := false
+	for i, a := range args {
+		if a == "--name" && i+1 < len(args) && args[i+1] == "aw-1234abcd" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("expected args to contain --name aw-1234abcd, got %v", args)
+	}
+
+	for _, a := range BuildRunArgs(RunConfig{ImageName: "img"}) {
+		if a == "--name" {
+			t.Error("expected no --name when Name is empty")
+		}
+	}
+}
+
+func TestBuildRunArgsWithUserNS(t *testing.T) {
+	args := strings.Join(BuildRunArgs(RunConfig{ImageName: "img", UserNS: "keep-id", User: "0:0"}), " ")
+	if !strings.Contains(args, "--userns keep-id") {
+		t.Errorf("expected --userns keep-id, got %s", args)
+	}
+	if !strings.Contains(args, "--user 0:0") {
+		t.Errorf("expected --user 0:0, got %s", args)
+	}
+	if strings.Index(args, "--user") > strings.Index(args, "img") {
+		t.Errorf("expected user flags before the image name, got %s", args)
+	}
+}
+
+func TestRuntimeInfoUserNS(t *testing.T) {
+	tests := []struct {
+		info RuntimeInfo
+		want string
+	}{
+		{RuntimeInfo{Name: RuntimeDocker}, ""},
+		{RuntimeInfo{Name: RuntimeDocker, Rootless: true}, ""},
+		{RuntimeInfo{Name: RuntimePodman}, ""},
+		{RuntimeInfo{Name: RuntimePodman, Rootless: true}, "keep-id"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.UserNS(); got != tt.want {
+			t.Errorf("%+v.UserNS() = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+// fakeRuntime writes a fake container CLI named name into dir. version is
+// printed for --version; info is printed for `info` (an empty info makes
+// `info` fail, like a stopped daemon).
+func fakeRuntime(t *testing.T, dir, name, version, info string) {
+	t.Helper()
+	infoCmd := "exit 1"
+	if info != "" {
+		infoCmd = "echo '" + info + "'"
+	}
+	script := "#!/bin/sh\ncase \"$1\" in\n--version) echo '" + version + "' ;;\ninfo) " + infoCmd + " ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShellClientCheckAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		runtime  string
+		setup    func(t *testing.T, dir string)
+		wantPath string
+		wantInfo RuntimeInfo
+		wantErr  string
+	}{
+		{
+			name:    "docker",
+			runtime: RuntimeDocker,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", `["name=seccomp"]`)
+			},
+			wantPath: "docker",
+			wantInfo: RuntimeInfo{Name: RuntimeDocker},
+		},
+		{
+			name:    "rootless docker",
+			runtime: "",
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", `["name=seccomp","name=rootless"]`)
+			},
+			wantPath: "docker",
+			wantInfo: RuntimeInfo{Name: RuntimeDocker, Rootless: true},
+		},
+		{
+			name:    "docker daemon stopped",
+			runtime: RuntimeDocker,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", "")
+			},
+			wantErr: "docker daemon is not running",
+		},
+		{
+			name:    "podman requested but missing",
+			runtime: RuntimePodman,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", "[]")
+			},
+			wantErr: "podman is not installed",
+		},
+		{
+			name:    "rootless podman",
+			runtime: RuntimePodman,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "podman", "podman version 4.9.3", "true")
+			},
+			wantPath: "podman",
+			wantInfo: RuntimeInfo{Name: RuntimePodman, Rootless: true},
+		},
+		{
+			name:    "auto prefers docker",
+			runtime: RuntimeAuto,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", "[]")
+				fakeRuntime(t, dir, "podman", "podman version 4.9.3", "true")
+			},
+			wantPath: "docker",
+			wantInfo: RuntimeInfo{Name: RuntimeDocker},
+		},
+		{
+			name:    "auto falls back to podman",
+			runtime: RuntimeAuto,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "Docker version 27.0.3", "")
+				fakeRuntime(t, dir, "podman", "podman version 4.9.3", "false")
+			},
+			wantPath: "podman",
+			wantInfo: RuntimeInfo{Name: RuntimePodman},
+		},
+		{
+			name:    "auto with nothing available",
+			runtime: RuntimeAuto,
+			setup:   func(t *testing.T, dir string) {},
+			wantErr: "no container runtime available",
+		},
+		{
+			name:    "docker shim for podman",
+			runtime: RuntimeDocker,
+			setup: func(t *testing.T, dir string) {
+				fakeRuntime(t, dir, "docker", "podman version 4.9.3", "true")
+			},
+			wantPath: "docker",
+			wantInfo: RuntimeInfo{Name: RuntimePodman, Rootless: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+			t.Setenv("PATH", dir)
+
+			c := &ShellClient{Runtime: tt.runtime}
+			err := c.CheckAvailable()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckAvailable() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckAvailable() error: %v", err)
+			}
+			if c.DockerPath != tt.wantPath {
+				t.Errorf("DockerPath = %q, want %q", c.DockerPath, tt.wantPath)
+			}
+			if got := c.RuntimeInfo(); got != tt.wantInfo {
+				t.Errorf("RuntimeInfo() = %+v, want %+v", got, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestBuildRunArgs_EnvSorted(t *testing.T) {
+	config := RunConfig{
+		ImageName: "img",
+		EnvVars:   map[string]string{"ZED": "1", "ALPHA": "2", "MID": "3"},
+	}
+	got := strings.Join(BuildRunArgs(config), " ")
+	want := "run -it --rm -e ALPHA=2 -e MID=3 -e ZED=1 img"
+	if got != want {
+		t.Errorf("BuildRunArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	if got := strings.Join(BuildArgs("img:abc", "/ctx", BuildOptions{}), " "); got != "build -t img:abc /ctx" {
+		t.Errorf("BuildArgs() = %q", got)
+	}
+	opts := BuildOptions{Args: map[string]string{"B": "2", "A": "1"}, Target: "dev", Platform: "linux/amd64", NoCache: true}
+	want := "build -t img:abc --build-arg A=1 --build-arg B=2 --target dev --platform linux/amd64 --no-cache /ctx"
+	if got := strings.Join(BuildArgs("img:abc", "/ctx", opts), " "); got != want {
+		t.Errorf("BuildArgs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+)
+
+// Pull policies accepted by EnsureImage.
+const (
+	PullAlways  = "always"  // pull before every run
+	PullMissing = "missing" // pull only if the image is not present locally
+	PullNever   = "never"   // never pull
+)
+
+// EnsureImage makes imageName available locally according to policy and
+// reports whether it was pulled.
+func EnsureImage(ctx context.Context, c Client, imageName, policy string) (bool, error) {
+	if policy != PullAlways {
+		exists, err := c.ImageExists(ctx, imageName)
+		if err != nil {
+			return false, fmt.Errorf("checking for image %s: %w", imageName, err)
+		}
+		if exists {
+			return false, nil
+		}
+		if policy == PullNever {
+			return false, fmt.Errorf("image %s is not present locally and docker.pull is %q", imageName, PullNever)
+		}
+	}
+	if err := c.Pull(ctx, imageName); err != nil {
+		return false, fmt.Errorf("pulling image %s: %w", imageName, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// pullClient implements the parts of Client that EnsureImage uses.
+type pullClient struct {
+	Client
+	exists bool
+	pulled bool
+}
+
+func (c *pullClient) ImageExists(context.Context, string) (bool, error) { return c.exists, nil }
+
+func (c *pullClient) Pull(context.Context, string) error {
+	c.pulled = true
+	return nil
+}
+
+func TestEnsureImage(t *testing.T) {
+	tests := []struct {
+		policy  string
+		exists  bool
+		want    bool
+		wantErr string
+	}{
+		{PullAlways, true, true, ""},
+		{PullMissing, true, false, ""},
+		{PullMissing, false, true, ""},
+		{PullNever, true, false, ""},
+		{PullNever, false, false, "not present locally"},
+	}
+	for _, tt := range tests {
+		c := &pullClient{exists: tt.exists}
+		got, err := EnsureImage(context.Background(), c, "acme/claude:1.4", tt.policy)
+		if got != tt.want || c.pulled != tt.want {
+			t.Errorf("%s, exists %v: pulled = %v (client %v), want %v", tt.policy, tt.exists, got, c.pulled, tt.want)
+		}
+		if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s, exists %v: error = %v, want %q", tt.policy, tt.exists, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Container runtimes accepted by NewClient.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+	RuntimeAuto   = "auto" // docker if available, otherwise podman
+)
+
+// RuntimeInfo describes the container engine a Client talks to. It is only
+// meaningful after a successful CheckAvailable.
+type RuntimeInfo struct {
+	Name     string // RuntimeDocker or RuntimePodman
+	Rootless bool   // engine runs without root privileges
+}
+
+// UserNS returns the user namespace mode containers should be started with.
+// Rootless podman maps container root to a subordinate UID, so files written
+// to bind mounts would end up owned by that UID on the host; "keep-id" maps
+// the host user to the same UID inside the container instead.
+func (r RuntimeInfo) UserNS() string {
+	if r.Name == RuntimePodman && r.Rootless {
+		return "keep-id"
+	}
+	return ""
+}
+
+// Command returns the CLI binary for the runtime, defaulting to "docker".
+func (r RuntimeInfo) Command() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return RuntimeDocker
+}
+
+// runtimeCandidates returns the runtimes to probe, in order, for runtime.
+func runtimeCandidates(runtime string) []string {
+	switch runtime {
+	case RuntimeAuto:
+		return []string{RuntimeDocker, RuntimePodman}
+	case RuntimePodman:
+		return []string{RuntimePodman}
+	default:
+		return []string{RuntimeDocker}
+	}
+}
+
+// PodmanHost returns the address of the podman API socket: the per-user
+// socket for rootless podman, or the system socket when running as root.
+func PodmanHost() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Geteuid() != 0 {
+		return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+	}
+	return "unix:///run/podman/podman.sock"
+}
@@ -0,0 +1,22 @@
+package docker
+
+import (
+	"syscall"
+	"time"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// waitReadable waits up to timeout for fd to have input.
+func waitReadable(fd int, timeout time.Duration) (bool, error) {
+	var set syscall.FdSet
+	fdSet(&set, fd)
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	if err := syscall.Select(fd+1, &set, nil, nil, &tv); err != nil {
+		return false, err
+	}
+	return fdIsSet(&set, fd), nil
+}
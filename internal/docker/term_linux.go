@@ -0,0 +1,22 @@
+package docker
+
+import (
+	"syscall"
+	"time"
+)
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
+
+// waitReadable waits up to timeout for fd to have input.
+func waitReadable(fd int, timeout time.Duration) (bool, error) {
+	var set syscall.FdSet
+	fdSet(&set, fd)
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	if _, err := syscall.Select(fd+1, &set, nil, nil, &tv); err != nil {
+		return false, err
+	}
+	return fdIsSet(&set, fd), nil
+}
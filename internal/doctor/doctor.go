@@ -0,0 +1,294 @@
+// Package doctor checks that the host has what a profile needs, for
+// `aw doctor`.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/worktree"
+)
+
+// Status is the outcome of one check.
+type Status int
+
+const (
+	Pass Status = iota
+	Warn        // aw works, but a feature (e.g. a zellij pane) will not
+	Fail        // the profile cannot run
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "ok"
+	case Warn:
+		return "warn"
+	default:
+		return "FAIL"
+	}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string // what was found, or why it matters
+	Hint   string // how to fix a warning or failure
+}
+
+// Disk space thresholds for building the image.
+const (
+	diskWarnBytes = 5 << 30
+	diskFailBytes = 2 << 30
+)
+
+// paneTool is a host command used by a zellij layout pane.
+type paneTool struct {
+	names []string // alternatives; any one is enough
+	pane  string
+}
+
+var paneTools = []paneTool{
+	{[]string{"fzf"}, "git-diff-picker"},
+	{[]string{"delta"}, "git-diff-picker"},
+	{[]string{"lsof"}, "git-diff-picker"},
+	{[]string{"curl"}, "git-diff-picker"},
+	{[]string{"gh"}, "pr-status"},
+	{[]string{"jq"}, "pr-status"},
+	{[]string{"fswatch", "entr"}, "plans-watcher"},
+}
+
+// Variables for tests.
+var (
+	newDockerClient = docker.NewClient
+	homeDir         = os.UserHomeDir
+	dictPath        = worktree.DictPath
+	runCommand      = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, name, args...).CombinedOutput()
+	}
+	diskFree = func(path string) (uint64, error) {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return 0, err
+		}
+		return st.Bavail * uint64(st.Bsize), nil
+	}
+)
+
+// Check runs the checks that apply to p, in the order aw would need them.
+func Check(ctx context.Context, p profile.Profile) []Result {
+	var results []Result
+	add := func(r Result) { results = append(results, r) }
+
+	add(checkGit(ctx, p))
+	if p.Worktree != nil {
+		add(checkDictionary())
+	}
+
+	if p.Environment == profile.EnvironmentDocker {
+		add(checkRuntime(ctx, p))
+		add(checkDisk(p))
+	} else if p.Launch == profile.LaunchClaude || p.Launch == profile.LaunchZellij {
+		add(checkTool("claude", Fail, "runs Claude Code on the host"))
+	}
+	if p.Environment == profile.EnvironmentDocker || p.Launch != profile.LaunchShell {
+		add(checkClaudeDir())
+	}
+
+	if p.Launch == profile.LaunchZellij {
+		add(checkTool("zellij", Fail, "runs the session layout"))
+		for _, t := range paneTools {
+			add(checkPaneTool(t))
+		}
+		add(checkTool("glow", Warn, "renders Markdown in the plans-watcher pane; falls back to cat"))
+		if _, err := lookPath("gh"); err == nil {
+			add(checkGhAuth(ctx))
+		}
+	}
+	return results
+}
+
+func checkGit(ctx context.Context, p profile.Profile) Result {
+	r := Result{Name: "git"}
+	if _, err := lookPath("git"); err != nil {
+		r.Status, r.Detail, r.Hint = Warn, "not found; needed to find the repository root", installHint("git")
+		if p.Worktree != nil {
+			r.Status, r.Detail = Fail, "not found; needed to create worktrees"
+		}
+		return r
+	}
+	out, _ := runCommand(ctx, "git", "--version")
+	r.Detail = strings.TrimSpace(string(out))
+	return r
+}
+
+func checkDictionary() Result {
+	r := Result{Name: "dictionary", Detail: dictPath}
+	if _, err := os.Stat(dictPath); err != nil {
+		r.Status = Warn
+		r.Detail = dictPath + " not found; branch names use the built-in word list"
+		r.Hint = installHint("words")
+	}
+	return r
+}
+
+func checkRuntime(ctx context.Context, p profile.Profile) Result {
+	rt := p.EffectiveRuntime()
+	r := Result{Name: string(rt)}
+	if rt == profile.RuntimeAuto {
+		r.Name = "container runtime"
+	}
+	client, err := newDockerClient(string(p.Docker.EffectiveClient()), string(rt))
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	if err := client.CheckAvailable(); err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		r.Hint = runtimeHint(rt, err)
+		return r
+	}
+	info := client.RuntimeInfo()
+	r.Detail = info.Command() + " is reachable"
+	if info.Rootless {
+		r.Detail += " (rootless)"
+	}
+	return r
+}
+
+// runtimeHint suggests installing the runtime or starting its daemon,
+// depending on what CheckAvailable reported.
+func runtimeHint(rt profile.Runtime, err error) string {
+	bin := "docker"
+	if rt == profile.RuntimePodman {
+		bin = "podman"
+	}
+	if strings.Contains(err.Error(), "not installed") || strings.Contains(err.Error(), "no container runtime") {
+		return installHint(bin)
+	}
+	switch {
+	case bin == "podman" && goos == "darwin":
+		return "podman machine start"
+	case bin == "podman":
+		return "systemctl --user start podman.socket"
+	case goos == "darwin":
+		return "start Docker Desktop"
+	default:
+		return "sudo systemctl start docker"
+	}
+}
+
+// checkDisk reports free space where images are stored: the engine's data
+// directory when it is local, otherwise the home directory.
+func checkDisk(p profile.Profile) Result {
+	r := Result{Name: "disk space"}
+	home, _ := homeDir()
+	candidates := []string{"/var/lib/docker", home}
+	if p.EffectiveRuntime() == profile.RuntimePodman {
+		candidates = []string{filepath.Join(home, ".local", "share", "containers"), "/var/lib/containers", home}
+	}
+
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		free, err := diskFree(path)
+		if err != nil {
+			continue
+		}
+		r.Detail = fmt.Sprintf("%.1f GiB free on %s", float64(free)/(1<<30), path)
+		switch {
+		case free < diskFailBytes:
+			r.Status = Fail
+		case free < diskWarnBytes:
+			r.Status = Warn
+		}
+		if r.Status != Pass {
+			r.Detail += "; building the image needs a few GiB"
+			r.Hint = "free up space, e.g. with `docker system prune`"
+			if p.EffectiveRuntime() == profile.RuntimePodman {
+				r.Hint = "free up space, e.g. with `podman system prune`"
+			}
+		}
+		return r
+	}
+	r.Status, r.Detail = Warn, "could not determine free space"
+	return r
+}
+
+func checkClaudeDir() Result {
+	r := Result{Name: "~/.claude"}
+	home, err := homeDir()
+	if err != nil {
+		r.Status, r.Detail = Warn, err.Error()
+		return r
+	}
+	dir := filepath.Join(home, ".claude")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		r.Status = Warn
+		r.Detail = dir + " not found; Claude Code settings and login are not shared"
+		r.Hint = "run `claude` on the host once and log in"
+		return r
+	}
+	r.Detail = dir
+	return r
+}
+
+func checkTool(name string, missing Status, purpose string) Result {
+	r := Result{Name: name}
+	path, err := lookPath(name)
+	if err != nil {
+		r.Status, r.Detail, r.Hint = missing, "not found; "+purpose, installHint(name)
+		return r
+	}
+	r.Detail = path
+	return r
+}
+
+func checkPaneTool(t paneTool) Result {
+	r := Result{Name: strings.Join(t.names, "/")}
+	for _, name := range t.names {
+		if path, err := lookPath(name); err == nil {
+			r.Detail = path
+			return r
+		}
+	}
+	r.Status = Warn
+	r.Detail = fmt.Sprintf("not found; the %s pane will not work", t.pane)
+	r.Hint = installHint(t.names[0])
+	if len(t.names) > 1 && goos != "darwin" {
+		r.Hint = installHint(t.names[1])
+	}
+	return r
+}
+
+func checkGhAuth(ctx context.Context) Result {
+	r := Result{Name: "gh auth"}
+	if out, err := runCommand(ctx, "gh", "auth", "status"); err != nil {
+		r.Status = Warn
+		r.Detail = "not logged in; the pr-status pane cannot fetch PRs"
+		if msg := firstLine(string(out)); msg != "" {
+			r.Detail += " (" + msg + ")"
+		}
+		r.Hint = "gh auth login"
+		return r
+	}
+	r.Detail = "logged in"
+	return r
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
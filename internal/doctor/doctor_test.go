@@ -0,0 +1,202 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+// fakeClient implements the parts of docker.Client that doctor uses.
+type fakeClient struct {
+	docker.Client
+	err  error
+	info docker.RuntimeInfo
+}
+
+func (c *fakeClient) CheckAvailable() error           { return c.err }
+func (c *fakeClient) RuntimeInfo() docker.RuntimeInfo { return c.info }
+
+// fakeSystem replaces the package's system hooks for one test. Commands in
+// tools are on PATH; everything else is missing.
+type fakeSystem struct {
+	goos     string
+	tools    []string
+	client   *fakeClient
+	ghAuthOK bool
+	free     uint64
+	home     string
+	dict     bool
+}
+
+func (f fakeSystem) install(t *testing.T) {
+	t.Helper()
+	origGOOS, origLook, origClient, origHome, origDict, origRun, origFree := goos, lookPath, newDockerClient, homeDir, dictPath, runCommand, diskFree
+	t.Cleanup(func() {
+		goos, lookPath, newDockerClient, homeDir, dictPath, runCommand, diskFree = origGOOS, origLook, origClient, origHome, origDict, origRun, origFree
+	})
+
+	goos = f.goos
+	lookPath = func(name string) (string, error) {
+		for _, tool := range f.tools {
+			if tool == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	newDockerClient = func(kind, runtime string) (docker.Client, error) { return f.client, nil }
+	homeDir = func() (string, error) { return f.home, nil }
+	dictPath = filepath.Join(t.TempDir(), "words")
+	if f.dict {
+		if err := os.WriteFile(dictPath, []byte("a\nb\nc\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runCommand = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		switch {
+		case name == "git":
+			return []byte("git version 2.45.0\n"), nil
+		case name == "gh" && f.ghAuthOK:
+			return []byte("Logged in to github.com\n"), nil
+		case name == "gh":
+			return []byte("You are not logged into any GitHub hosts.\n"), errors.New("exit status 1")
+		}
+		return nil, errors.New("unexpected command " + name)
+	}
+	diskFree = func(string) (uint64, error) { return f.free, nil }
+}
+
+func byName(results []Result) map[string]Result {
+	m := make(map[string]Result)
+	for _, r := range results {
+		m[r.Name] = r
+	}
+	return m
+}
+
+func TestCheck_ZellijAllGood(t *testing.T) {
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(home, ".claude"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fakeSystem{
+		goos:     "linux",
+		tools:    []string{"git", "zellij", "fzf", "delta", "lsof", "curl", "gh", "jq", "entr", "glow"},
+		client:   &fakeClient{info: docker.RuntimeInfo{Name: "podman", Rootless: true}},
+		ghAuthOK: true,
+		free:     50 << 30,
+		home:     home,
+		dict:     true,
+	}.install(t)
+
+	p := profile.Profile{Worktree: &profile.WorktreeConfig{}, Environment: profile.EnvironmentDocker, Launch: profile.LaunchZellij, Runtime: profile.RuntimePodman}
+	results := Check(context.Background(), p)
+	for _, r := range results {
+		if r.Status != Pass {
+			t.Errorf("%s = %s (%s), want ok", r.Name, r.Status, r.Detail)
+		}
+	}
+	got := byName(results)
+	for _, name := range []string{"git", "dictionary", "podman", "disk space", "~/.claude", "zellij", "fswatch/entr", "glow", "gh auth"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("missing check %q", name)
+		}
+	}
+	if d := got["podman"].Detail; d != "podman is reachable (rootless)" {
+		t.Errorf("podman detail = %q", d)
+	}
+}
+
+func TestCheck_Failures(t *testing.T) {
+	fakeSystem{
+		goos:   "linux",
+		tools:  []string{"git", "apt-get", "gh"},
+		client: &fakeClient{err: errors.New("docker daemon is not running")},
+		free:   1 << 30,
+		home:   t.TempDir(),
+	}.install(t)
+
+	p := profile.Profile{Worktree: &profile.WorktreeConfig{}, Environment: profile.EnvironmentDocker, Launch: profile.LaunchZellij}
+	got := byName(Check(context.Background(), p))
+
+	tests := []struct {
+		name   string
+		status Status
+		hint   string
+	}{
+		{"dictionary", Warn, "sudo apt-get install -y wamerican"},
+		{"docker", Fail, "sudo systemctl start docker"},
+		{"disk space", Fail, "docker system prune"},
+		{"~/.claude", Warn, "claude"},
+		{"zellij", Fail, "cargo install --locked zellij"},
+		{"fzf", Warn, "sudo apt-get install -y fzf"},
+		{"delta", Warn, "sudo apt-get install -y git-delta"},
+		{"fswatch/entr", Warn, "sudo apt-get install -y entr"},
+		{"glow", Warn, "go install github.com/charmbracelet/glow@latest"},
+		{"gh auth", Warn, "gh auth login"},
+	}
+	for _, tt := range tests {
+		r, ok := got[tt.name]
+		if !ok {
+			t.Errorf("missing check %q", tt.name)
+			continue
+		}
+		if r.Status != tt.status || !strings.Contains(r.Hint, tt.hint) {
+			t.Errorf("%s = %s, hint %q; want %s, hint containing %q", tt.name, r.Status, r.Hint, tt.status, tt.hint)
+		}
+	}
+	if d := got["gh auth"].Detail; !strings.Contains(d, "not logged into any GitHub hosts") {
+		t.Errorf("gh auth detail = %q, want gh's message", d)
+	}
+}
+
+func TestCheck_HostShellSkipsDockerAndZellij(t *testing.T) {
+	fakeSystem{goos: "linux", tools: []string{"git"}, home: t.TempDir()}.install(t)
+
+	results := Check(context.Background(), profile.Profile{Environment: profile.EnvironmentHost, Launch: profile.LaunchShell})
+	if len(results) != 1 || results[0].Name != "git" || results[0].Status != Pass {
+		t.Errorf("results = %+v, want only a passing git check", results)
+	}
+}
+
+func TestCheck_HostClaudeNeedsCLI(t *testing.T) {
+	fakeSystem{goos: "darwin", tools: []string{"brew"}, home: t.TempDir()}.install(t)
+
+	got := byName(Check(context.Background(), profile.Profile{Environment: profile.EnvironmentHost, Launch: profile.LaunchClaude}))
+	if r := got["claude"]; r.Status != Fail || !strings.Contains(r.Hint, "npm install -g") {
+		t.Errorf("claude = %+v, want a failure with an npm hint", r)
+	}
+	if r := got["git"]; r.Status != Warn || r.Hint != "brew install git" {
+		t.Errorf("git without worktree = %+v, want a warning with a brew hint", r)
+	}
+}
+
+func TestInstallHint(t *testing.T) {
+	tests := []struct {
+		goos  string
+		tools []string
+		tool  string
+		want  string
+	}{
+		{"darwin", []string{"brew"}, "delta", "brew install git-delta"},
+		{"darwin", []string{"brew"}, "lsof", "lsof ships with macOS; check your PATH"},
+		{"darwin", nil, "docker", "install Docker Desktop"},
+		{"linux", []string{"brew", "dnf"}, "delta", "sudo dnf install -y git-delta"},
+		{"linux", []string{"pacman"}, "gh", "sudo pacman -S github-cli"},
+		{"linux", []string{"apk"}, "docker", "sudo apk add docker"},
+		{"linux", []string{"dnf"}, "glow", "go install github.com/charmbracelet/glow@latest"},
+		{"linux", nil, "fzf", "see https://github.com/junegunn/fzf#installation"},
+	}
+	for _, tt := range tests {
+		fakeSystem{goos: tt.goos, tools: tt.tools}.install(t)
+		if got := installHint(tt.tool); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("installHint(%q) on %s with %v = %q, want prefix %q", tt.tool, tt.goos, tt.tools, got, tt.want)
+		}
+	}
+}
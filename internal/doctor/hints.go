@@ -0,0 +1,111 @@
+package doctor
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// installer is a system package manager and how to install a package with it.
+type installer struct {
+	name    string // key into packageNames, e.g. "apt"
+	bin     string // command whose presence identifies it
+	command string // format string taking the package name
+}
+
+// installers are tried in order; the first one on PATH is used for hints.
+var installers = []installer{
+	{"brew", "brew", "brew install %s"},
+	{"apt", "apt-get", "sudo apt-get install -y %s"},
+	{"dnf", "dnf", "sudo dnf install -y %s"},
+	{"pacman", "pacman", "sudo pacman -S %s"},
+	{"apk", "apk", "sudo apk add %s"},
+	{"zypper", "zypper", "sudo zypper install %s"},
+}
+
+// packageNames maps a tool to its package name per installer. A missing
+// installer entry means the tool is not packaged there and fallbackHints
+// applies; "" means it ships with the OS.
+var packageNames = map[string]map[string]string{
+	"git":     {"brew": "git", "apt": "git", "dnf": "git", "pacman": "git", "apk": "git", "zypper": "git"},
+	"zellij":  {"brew": "zellij", "pacman": "zellij", "apk": "zellij", "zypper": "zellij"},
+	"claude":  {},
+	"fzf":     {"brew": "fzf", "apt": "fzf", "dnf": "fzf", "pacman": "fzf", "apk": "fzf", "zypper": "fzf"},
+	"delta":   {"brew": "git-delta", "apt": "git-delta", "dnf": "git-delta", "pacman": "git-delta", "apk": "delta", "zypper": "git-delta"},
+	"lsof":    {"brew": "", "apt": "lsof", "dnf": "lsof", "pacman": "lsof", "apk": "lsof", "zypper": "lsof"},
+	"curl":    {"brew": "curl", "apt": "curl", "dnf": "curl", "pacman": "curl", "apk": "curl", "zypper": "curl"},
+	"gh":      {"brew": "gh", "apt": "gh", "dnf": "gh", "pacman": "github-cli", "apk": "github-cli", "zypper": "gh"},
+	"jq":      {"brew": "jq", "apt": "jq", "dnf": "jq", "pacman": "jq", "apk": "jq", "zypper": "jq"},
+	"fswatch": {"brew": "fswatch", "apt": "fswatch", "pacman": "fswatch"},
+	"entr":    {"brew": "entr", "apt": "entr", "dnf": "entr", "pacman": "entr", "apk": "entr", "zypper": "entr"},
+	"glow":    {"brew": "glow", "pacman": "glow", "apk": "glow"},
+	"docker":  {"apt": "docker.io", "dnf": "moby-engine", "pacman": "docker", "apk": "docker", "zypper": "docker"},
+	"podman":  {"brew": "podman", "apt": "podman", "dnf": "podman", "pacman": "podman", "apk": "podman", "zypper": "podman"},
+	"words":   {"apt": "wamerican", "dnf": "words", "pacman": "words", "zypper": "words"},
+}
+
+// fallbackHints are used when no known installer packages the tool.
+var fallbackHints = map[string]string{
+	"git":     "see https://git-scm.com/downloads",
+	"zellij":  "cargo install --locked zellij, or see https://zellij.dev/documentation/installation",
+	"claude":  "npm install -g @anthropic-ai/claude-code",
+	"fzf":     "see https://github.com/junegunn/fzf#installation",
+	"delta":   "see https://dandavison.github.io/delta/installation.html",
+	"gh":      "see https://github.com/cli/cli#installation",
+	"jq":      "see https://jqlang.github.io/jq/download/",
+	"fswatch": "install entr instead, or see https://github.com/emcrisostomo/fswatch",
+	"entr":    "see https://eradman.com/entrproject/",
+	"glow":    "go install github.com/charmbracelet/glow@latest",
+	"docker":  "see https://docs.docker.com/engine/install/",
+	"podman":  "see https://podman.io/docs/installation",
+	"words":   "install a words list for your OS (e.g. wamerican, words)",
+}
+
+// detectInstaller returns the package manager used for install hints. On
+// macOS only Homebrew is considered; Docker Desktop is suggested there too.
+func detectInstaller() (installer, bool) {
+	for _, in := range installers {
+		if goos == "darwin" && in.name != "brew" {
+			continue
+		}
+		if goos != "darwin" && in.name == "brew" {
+			continue
+		}
+		if _, err := lookPath(in.bin); err == nil {
+			return in, true
+		}
+	}
+	return installer{}, false
+}
+
+// installHint returns how to install tool on this machine.
+func installHint(tool string) string {
+	if tool == "docker" && goos == "darwin" {
+		return "install Docker Desktop (https://docs.docker.com/desktop/install/mac-install/) or `brew install --cask docker`"
+	}
+	if in, ok := detectInstaller(); ok {
+		if pkg, ok := packageNames[tool][in.name]; ok {
+			if pkg == "" {
+				return fmt.Sprintf("%s ships with %s; check your PATH", tool, osName())
+			}
+			return fmt.Sprintf(in.command, pkg)
+		}
+	}
+	if h, ok := fallbackHints[tool]; ok {
+		return h
+	}
+	return "install " + tool
+}
+
+func osName() string {
+	if goos == "darwin" {
+		return "macOS"
+	}
+	return goos
+}
+
+// goos and lookPath are variables so tests can simulate other systems.
+var (
+	goos     = runtime.GOOS
+	lookPath = exec.LookPath
+)
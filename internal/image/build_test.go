@@ -40,6 +40,9 @@ func TestEmbeddedEntrypointContent(t *testing.T) {
 	if !strings.Contains(content, "HOST_CLAUDE_HOME") {
 		t.Error("entrypoint.sh should reference HOST_CLAUDE_HOME")
 	}
+	if !strings.Contains(content, "usermod -o -u \"$AW_HOST_UID\" claude") {
+		t.Error("entrypoint.sh should remap the claude user for keep-id user namespaces")
+	}
 }
 
 func TestDefaultDockerfile(t *testing.T) {
@@ -53,7 +56,7 @@ func TestDefaultDockerfile(t *testing.T) {
 }
 
 func TestPrepareBuildContext(t *testing.T) {
-	dir, cleanup, err := PrepareBuildContext("")
+	dir, cleanup, err := PrepareBuildContext("", "", Features{})
 	if err != nil {
 		t.Fatalf("PrepareBuildContext() error: %v", err)
 	}
@@ -103,7 +106,7 @@ func TestPrepareBuildContext_CustomDockerfile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dir, cleanup, err := PrepareBuildContext(customPath)
+	dir, cleanup, err := PrepareBuildContext(customPath, "", Features{})
 	if err != nil {
 		t.Fatalf("PrepareBuildContext() error: %v", err)
 	}
@@ -128,8 +131,57 @@ func TestPrepareBuildContext_CustomDockerfile(t *testing.T) {
 	}
 }
 
+func TestPrepareBuildContext_ContextDir(t *testing.T) {
+	contextDir := t.TempDir()
+	customPath := filepath.Join(contextDir, "Dockerfile")
+	files := map[string]string{
+		"Dockerfile":    "FROM alpine:latest\nCOPY scripts/ /scripts/\n",
+		"scripts/a.sh":  "echo a\n",
+		"build.log":     "",
+		".dockerignore": "*.log\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(contextDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, cleanup, err := PrepareBuildContext(customPath, contextDir, Features{})
+	if err != nil {
+		t.Fatalf("PrepareBuildContext() error: %v", err)
+	}
+	defer cleanup()
+
+	if got, _ := os.ReadFile(filepath.Join(dir, "scripts", "a.sh")); string(got) != "echo a\n" {
+		t.Errorf("scripts/a.sh = %q, want it copied from the context", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build.log")); err == nil {
+		t.Error("build.log was copied despite .dockerignore")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "entrypoint.sh")); string(got) != string(entrypointSh) {
+		t.Error("entrypoint.sh should be the embedded default when the context has none")
+	}
+
+	// An entrypoint.sh in the context replaces the embedded one.
+	if err := os.WriteFile(filepath.Join(contextDir, "entrypoint.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir2, cleanup2, err := PrepareBuildContext(customPath, contextDir, Features{})
+	if err != nil {
+		t.Fatalf("PrepareBuildContext() error: %v", err)
+	}
+	defer cleanup2()
+	if got, _ := os.ReadFile(filepath.Join(dir2, "entrypoint.sh")); string(got) != "#!/bin/sh\n" {
+		t.Errorf("entrypoint.sh = %q, want the context's own", got)
+	}
+}
+
 func TestPrepareBuildContext_CustomDockerfileNotFound(t *testing.T) {
-	_, _, err := PrepareBuildContext("/nonexistent/Dockerfile")
+	_, _, err := PrepareBuildContext("/nonexistent/Dockerfile", "", Features{})
 	if err == nil {
 		t.Fatal("expected error for nonexistent custom Dockerfile")
 	}
@@ -139,7 +191,7 @@ func TestPrepareBuildContext_CustomDockerfileNotFound(t *testing.T) {
 }
 
 func TestPrepareBuildContextCleanup(t *testing.T) {
-	dir, cleanup, err := PrepareBuildContext("")
+	dir, cleanup, err := PrepareBuildContext("", "", Features{})
 	if err != nil {
 		t.Fatalf("PrepareBuildContext() error: %v", err)
 	}
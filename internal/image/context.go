@@ -0,0 +1,201 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// BuildContext is the set of files sent to an image build: the Dockerfile,
+// entrypoint.sh and, with a context directory, the files of that directory
+// its .dockerignore does not exclude. Listing it reads no file contents:
+// Hash reads the files in place, and only Prepare copies them.
+type BuildContext struct {
+	entries []contextEntry // in filepath.WalkDir order
+}
+
+// contextEntry is a file, directory or symlink of a build context.
+type contextEntry struct {
+	rel  string      // slash-separated path in the context
+	dir  bool        // a directory, sent even if empty
+	link string      // the target of a symlink
+	src  string      // the file on disk holding the content
+	data []byte      // the content of a generated file, if src is empty
+	size int64       // of the content
+	perm fs.FileMode // permission bits of a file
+}
+
+// diskEntry describes the file at path, if it is a regular file or a
+// symlink; sockets, FIFOs and devices cannot be sent.
+func diskEntry(path, rel string, d fs.DirEntry) (contextEntry, bool, error) {
+	switch {
+	case d.Type()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return contextEntry{}, false, err
+		}
+		return contextEntry{rel: rel, link: target}, true, nil
+	case d.Type().IsRegular():
+		info, err := d.Info()
+		if err != nil {
+			return contextEntry{}, false, err
+		}
+		return contextEntry{rel: rel, src: path, size: info.Size(), perm: info.Mode().Perm()}, true, nil
+	}
+	return contextEntry{}, false, nil
+}
+
+// NewBuildContext lists the build context of an image.
+// If customDockerfilePath is non-empty, the Dockerfile is read from that path
+// instead of using the embedded default. features are appended to it as
+// extra layers.
+// If contextDir is non-empty, the files of that directory not excluded by
+// its .dockerignore are part of the context, so the Dockerfile can COPY
+// them. Its .git and the directories in skip, such as the worktrees
+// directory, are always left out. An entrypoint.sh among them is kept
+// instead of the embedded one, which the context holds otherwise so a custom
+// Dockerfile can COPY it too.
+func NewBuildContext(customDockerfilePath, contextDir string, skip []string, features Features) (*BuildContext, error) {
+	dockerfileContent := dockerfile
+	if customDockerfilePath != "" {
+		var err error
+		dockerfileContent, err = os.ReadFile(customDockerfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading custom Dockerfile %q: %w", customDockerfilePath, err)
+		}
+	}
+	dockerfileContent = FeaturesDockerfile(dockerfileContent, features)
+
+	c := &BuildContext{}
+	hasEntrypoint := false
+	if contextDir != "" {
+		cleaned := make([]string, len(skip))
+		for i, dir := range skip {
+			cleaned[i] = filepath.Clean(dir)
+		}
+		entries, err := listContext(filepath.Clean(contextDir), cleaned)
+		if err != nil {
+			return nil, fmt.Errorf("listing build context %q: %w", contextDir, err)
+		}
+		for _, e := range entries {
+			switch {
+			case e.rel == "Dockerfile" || strings.HasPrefix(e.rel, "Dockerfile/"):
+				continue // replaced by the Dockerfile to build
+			case e.rel == "entrypoint.sh":
+				hasEntrypoint = true
+			}
+			c.entries = append(c.entries, e)
+		}
+	}
+	c.entries = append(c.entries, contextEntry{rel: "Dockerfile", data: dockerfileContent, size: int64(len(dockerfileContent)), perm: 0644})
+	if !hasEntrypoint {
+		c.entries = append(c.entries, contextEntry{rel: "entrypoint.sh", data: entrypointSh, size: int64(len(entrypointSh)), perm: 0755})
+	}
+	c.sort()
+	return c, nil
+}
+
+// sort puts the entries in filepath.WalkDir order, which compares paths
+// element by element: "a/b" comes before "a.txt".
+func (c *BuildContext) sort() {
+	slices.SortFunc(c.entries, func(a, b contextEntry) int {
+		return slices.Compare(strings.Split(a.rel, "/"), strings.Split(b.rel, "/"))
+	})
+}
+
+// Hash returns a hex SHA-256 over the context: the path, executable bit and
+// content of every file, and the target of every symlink. Images are tagged
+// with it, so that a change to the Dockerfile, entrypoint.sh or any other
+// file sent to the build yields a new tag, while an unchanged context finds
+// the image it built before. Files are read where they are.
+func (c *BuildContext) Hash() (string, error) {
+	h := sha256.New()
+	for _, e := range c.entries {
+		switch {
+		case e.dir:
+		case e.src == "" && e.data == nil:
+			fmt.Fprintf(h, "link %s %s\n", e.rel, e.link)
+		default:
+			fmt.Fprintf(h, "file %s %t %d\n", e.rel, e.perm&0111 != 0, e.size)
+			if e.src == "" {
+				h.Write(e.data)
+				continue
+			}
+			f, err := os.Open(e.src)
+			if err != nil {
+				return "", fmt.Errorf("hashing build context: %w", err)
+			}
+			_, err = io.Copy(h, f)
+			_ = f.Close()
+			if err != nil {
+				return "", fmt.Errorf("hashing build context: %w", err)
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Prepare copies the context into a temporary directory to build from.
+// The caller must call the returned cleanup function when done.
+func (c *BuildContext) Prepare() (dir string, cleanup func(), err error) {
+	tmpDir, err := os.MkdirTemp("", "aw-build-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	cleanupFn := func() { _ = os.RemoveAll(tmpDir) }
+	if err := c.writeTo(tmpDir); err != nil {
+		cleanupFn()
+		return "", nil, fmt.Errorf("writing build context: %w", err)
+	}
+	return tmpDir, cleanupFn, nil
+}
+
+func (c *BuildContext) writeTo(dst string) error {
+	for _, e := range c.entries {
+		to := filepath.Join(dst, filepath.FromSlash(e.rel))
+		if e.dir {
+			if err := os.MkdirAll(to, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case e.src != "":
+			err = copyFile(e.src, to, e.perm)
+		case e.data != nil:
+			err = os.WriteFile(to, e.data, e.perm)
+		default:
+			err = os.Symlink(e.link, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
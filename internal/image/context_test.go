@@ -0,0 +1,110 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildContextHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, perm os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		c, err := NewBuildContext(filepath.Join(dir, "Dockerfile"), dir, nil, Features{})
+		if err != nil {
+			t.Fatalf("NewBuildContext() error: %v", err)
+		}
+		h, err := c.Hash()
+		if err != nil {
+			t.Fatalf("Hash() error: %v", err)
+		}
+		return h
+	}
+	write("Dockerfile", "FROM scratch\n", 0644)
+	write("entrypoint.sh", "#!/bin/sh\n", 0755)
+	write(".dockerignore", "*.log\n", 0644)
+
+	first := hash()
+	if len(first) != 64 {
+		t.Errorf("Hash() = %q, want 64 hex digits", first)
+	}
+	if again := hash(); again != first {
+		t.Errorf("Hash() = %q, then %q for the same context", first, again)
+	}
+
+	// Excluded files are not part of the context.
+	write("build.log", "building\n", 0644)
+	if h := hash(); h != first {
+		t.Errorf("after adding an ignored file, Hash() = %s, want %s", h, first)
+	}
+
+	seen := map[string]string{first: "initial"}
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"Dockerfile content", func() { write("Dockerfile", "FROM alpine\n", 0644) }},
+		{"entrypoint.sh content", func() { write("entrypoint.sh", "#!/bin/sh\nexec \"$@\"\n", 0755) }},
+		{"exec bit", func() { _ = os.Chmod(filepath.Join(dir, "entrypoint.sh"), 0644) }},
+		{"new file", func() { write("extra.txt", "", 0644) }},
+		{"symlink", func() { _ = os.Symlink("extra.txt", filepath.Join(dir, "link")) }},
+	}
+	for _, c := range changes {
+		c.change()
+		h := hash()
+		if prev, ok := seen[h]; ok {
+			t.Errorf("after %s, Hash() = %s, same as after %s", c.name, h, prev)
+		}
+		seen[h] = c.name
+	}
+}
+
+func TestBuildContextHash_SameAsPrepared(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":   "a\n",
+		"a/b.txt": "b\n",
+		"a-c.txt": "c\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := NewBuildContext("", dir, nil, Features{})
+	if err != nil {
+		t.Fatalf("NewBuildContext() error: %v", err)
+	}
+	want, err := c.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error: %v", err)
+	}
+
+	// The prepared directory, listed again, has the same hash: the listing
+	// is in the order a walk of it gives.
+	prepared, cleanup, err := c.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare() error: %v", err)
+	}
+	defer cleanup()
+	entries, err := listContext(prepared, nil)
+	if err != nil {
+		t.Fatalf("listContext() error: %v", err)
+	}
+	got, err := (&BuildContext{entries: entries}).Hash()
+	if err != nil {
+		t.Fatalf("Hash() error: %v", err)
+	}
+	if got != want {
+		t.Errorf("Hash() of the prepared context = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package image
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ignorePattern is a line of a .dockerignore file.
+type ignorePattern struct {
+	re     *regexp.Regexp
+	negate bool // the line started with "!": matches are sent after all
+}
+
+// dockerignore excludes files from a build context the way the docker CLI
+// does: patterns are matched against slash-separated paths relative to the
+// context root, "*" and "?" stop at "/", "**" matches any number of
+// directories, a pattern matching a directory excludes everything in it,
+// and the last matching line wins.
+type dockerignore []ignorePattern
+
+// readDockerignore parses the .dockerignore file at the root of dir. A
+// missing file excludes nothing.
+func readDockerignore(dir string) (dockerignore, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return parseDockerignore(f)
+}
+
+func parseDockerignore(r io.Reader) (dockerignore, error) {
+	var patterns dockerignore
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := ignorePattern{}
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			p.negate, line = true, strings.TrimSpace(rest)
+		}
+		line = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(line)), "/")
+		re, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf(".dockerignore: %q: %w", line, err)
+		}
+		p.re = re
+		patterns = append(patterns, p)
+	}
+	return patterns, scanner.Err()
+}
+
+// compileIgnorePattern turns a .dockerignore pattern into an anchored
+// regular expression.
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?") // "**/" also matches no directory at all
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated [")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// excludes reports whether the file or directory at rel, a slash-separated
+// path relative to the context root, is left out of the context.
+func (d dockerignore) excludes(rel string) bool {
+	excluded := false
+	for _, p := range d {
+		if p.matches(rel) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// matches reports whether p matches rel or one of its parent directories.
+func (p ignorePattern) matches(rel string) bool {
+	for path := rel; ; {
+		if p.re.MatchString(path) {
+			return true
+		}
+		i := strings.LastIndexByte(path, '/')
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+// hasExceptions reports whether a "!" line may bring back files inside an
+// excluded directory, in which case the directory must still be walked.
+func (d dockerignore) hasExceptions() bool {
+	for _, p := range d {
+		if p.negate {
+			return true
+		}
+	}
+	return false
+}
+
+// listContext lists the files and directories of src that its .dockerignore
+// does not exclude, without reading them. The .dockerignore itself is left
+// out, as it has been applied already. So are src's .git and the directories
+// in skip, which change with every commit or worktree and would otherwise
+// give each build context a new hash.
+func listContext(src string, skip []string) ([]contextEntry, error) {
+	ignore, err := readDockerignore(src)
+	if err != nil {
+		return nil, err
+	}
+	walkAll := ignore.hasExceptions()
+	var entries []contextEntry
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		slashRel := filepath.ToSlash(rel)
+		if slashRel == ".dockerignore" {
+			return nil
+		}
+		if slashRel == ".git" || slices.Contains(skip, filepath.Clean(path)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		excluded := ignore.excludes(slashRel)
+		if d.IsDir() {
+			switch {
+			case !excluded:
+				entries = append(entries, contextEntry{rel: slashRel, dir: true})
+			case !walkAll:
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if excluded {
+			return nil
+		}
+		e, ok, err := diskEntry(path, slashRel, d)
+		if ok {
+			entries = append(entries, e)
+		}
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,88 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDockerignore_Excludes(t *testing.T) {
+	ignore, err := parseDockerignore(strings.NewReader(`
+# build output
+/dist
+*.log
+**/node_modules
+docs/*.md
+!docs/README.md
+secret?.txt
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"dist", true},
+		{"dist/app.js", true},
+		{"src/dist", false},
+		{"error.log", true},
+		{"logs/error.log", false},
+		{"node_modules/x/index.js", true},
+		{"web/node_modules/x/index.js", true},
+		{"docs/guide.md", true},
+		{"docs/README.md", false},
+		{"docs/api/guide.md", false},
+		{"secret1.txt", true},
+		{"secret12.txt", false},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := ignore.excludes(tt.path); got != tt.want {
+			t.Errorf("excludes(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestListContext(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		".dockerignore":          "node_modules\n**/*.secret\n",
+		"go.mod":                 "module x\n",
+		"scripts/setup.sh":       "#!/bin/sh\n",
+		"node_modules/a.js":      "",
+		"certs/dev.secret":       "",
+		"certs/ca.pem":           "",
+		"scripts/empty/.gitkeep": "",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := listContext(src, nil)
+	if err != nil {
+		t.Fatalf("listContext() error: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		if e.dir {
+			continue
+		}
+		got = append(got, e.rel)
+		if e.rel == "scripts/setup.sh" && e.perm&0111 == 0 {
+			t.Error("scripts/setup.sh lost its exec bit")
+		}
+	}
+	sort.Strings(got)
+	want := "certs/ca.pem,go.mod,scripts/empty/.gitkeep,scripts/setup.sh"
+	if strings.Join(got, ",") != want {
+		t.Errorf("listed %v, want %s", got, want)
+	}
+}
@@ -1,6 +1,23 @@
 #!/bin/bash
 set -e
 
+# Rootless podman (--userns=keep-id) maps the host user to the same UID/GID
+# inside the container. Move the claude user onto that UID/GID so files in
+# bind mounts stay owned by the host user, then let the chowns below fix up
+# everything the old UID owned.
+if [ -n "${AW_HOST_UID:-}" ] && [ "$AW_HOST_UID" != "0" ]; then
+  if [ "$(id -g claude)" != "$AW_HOST_GID" ]; then
+    groupmod -o -g "$AW_HOST_GID" claude
+  fi
+  if [ "$(id -u claude)" != "$AW_HOST_UID" ]; then
+    usermod -o -u "$AW_HOST_UID" claude
+  fi
+  # /Users (for macOS host paths) only exists in the embedded image.
+  if [ -d /Users ]; then
+    chown claude:claude /Users
+  fi
+fi
+
 # Create symlink to host-side claude home path (runs as root)
 # installed_plugins.json etc. reference host absolute paths
 if [ -n "$HOST_CLAUDE_HOME" ] && [ "$HOST_CLAUDE_HOME" != "/home/claude/.claude" ]; then
@@ -0,0 +1,113 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Features are toolchains layered onto the embedded Dockerfile. The values
+// are pasted into shell commands, so they must have been validated, as
+// profile.Validate does for docker.features.
+type Features struct {
+	Go     string   // "1.24" for the latest 1.24.x, or an exact release such as "1.24.2"
+	Rust   string   // rustup toolchain: "stable", "beta", "nightly" or a release
+	Python string   // e.g. "3.12"
+	Apt    []string // Debian packages
+}
+
+// IsZero reports whether f adds nothing.
+func (f Features) IsZero() bool {
+	return f.Go == "" && f.Rust == "" && f.Python == "" && len(f.Apt) == 0
+}
+
+// String lists the features, e.g. "go 1.24, apt ripgrep jq", for messages.
+func (f Features) String() string {
+	var parts []string
+	for _, p := range []struct{ name, value string }{
+		{"go", f.Go},
+		{"rust", f.Rust},
+		{"python", f.Python},
+		{"apt", strings.Join(f.Apt, " ")},
+	} {
+		if p.value != "" {
+			parts = append(parts, p.name+" "+p.value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+var goMinorRe = regexp.MustCompile(`^1\.[0-9]+$`)
+
+// FeaturesDockerfile returns dockerfile with a layer appended for each of
+// the features. The layers run as root, as the embedded Dockerfile never
+// switches users, and come after all of its instructions, so that its
+// layers stay cached and are shared with images without features.
+func FeaturesDockerfile(dockerfile []byte, f Features) []byte {
+	if f.IsZero() {
+		return dockerfile
+	}
+	var b strings.Builder
+	b.Write(dockerfile)
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n# docker.features, generated by aw\n")
+
+	if len(f.Apt) > 0 {
+		fmt.Fprintf(&b, `
+RUN apt-get update && \
+    apt-get install -y --no-install-recommends %s && \
+    rm -rf /var/lib/apt/lists/*
+`, strings.Join(f.Apt, " "))
+	}
+
+	if f.Go != "" {
+		// go.dev/dl names releases go1.24.0, go1.24.1, ... (go1.20, go1.20.1,
+		// ... before 1.21), so a minor version is resolved to its latest
+		// release when the image is built.
+		version := fmt.Sprintf(`GO_VERSION="go%s"`, f.Go)
+		if goMinorRe.MatchString(f.Go) {
+			pattern := `"go` + strings.ReplaceAll(f.Go, ".", `\.`) + `\(\.[0-9][0-9]*\)\?"`
+			version = fmt.Sprintf(`GO_VERSION="$(curl -fsSL 'https://go.dev/dl/?mode=json&include=all' | \
+      grep -o '%s' | tr -d '"' | sort -V | tail -n 1)" && \
+    : "${GO_VERSION:?no Go release matches %s}"`, pattern, f.Go)
+		}
+		fmt.Fprintf(&b, `
+RUN %s && \
+    curl -fsSL "https://go.dev/dl/${GO_VERSION}.linux-$(dpkg --print-architecture).tar.gz" -o /tmp/go.tar.gz && \
+    rm -rf /usr/local/go && \
+    tar -C /usr/local -xzf /tmp/go.tar.gz && \
+    rm /tmp/go.tar.gz
+`, version)
+	}
+
+	if f.Rust != "" {
+		fmt.Fprintf(&b, `
+ENV RUSTUP_HOME=/usr/local/rustup CARGO_HOME=/usr/local/cargo PATH="/usr/local/cargo/bin:${PATH}"
+RUN apt-get update && \
+    apt-get install -y --no-install-recommends gcc libc6-dev && \
+    rm -rf /var/lib/apt/lists/* && \
+    curl -fsSL https://sh.rustup.rs | sh -s -- -y --no-modify-path --profile minimal \
+      --component clippy,rustfmt --default-toolchain %s && \
+    chmod -R a+w "$RUSTUP_HOME" "$CARGO_HOME"
+`, f.Rust)
+	}
+
+	if f.Python != "" {
+		minor := f.Python
+		if parts := strings.Split(f.Python, "."); len(parts) > 2 {
+			minor = parts[0] + "." + parts[1]
+		}
+		fmt.Fprintf(&b, `
+ENV UV_PYTHON_INSTALL_DIR=/opt/python
+RUN curl -fsSL https://astral.sh/uv/install.sh | env UV_INSTALL_DIR=/usr/local/bin UV_NO_MODIFY_PATH=1 sh && \
+    uv python install %[1]s && \
+    PYTHON="$(uv python find --python-preference only-managed %[1]s)" && \
+    ln -sf "$PYTHON" /usr/local/bin/python%[2]s && \
+    ln -sf "$PYTHON" /usr/local/bin/python3 && \
+    ln -sf "$PYTHON" /usr/local/bin/python
+`, f.Python, minor)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,66 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeaturesDockerfile(t *testing.T) {
+	if got := FeaturesDockerfile(dockerfile, Features{}); string(got) != string(dockerfile) {
+		t.Error("FeaturesDockerfile() without features changed the Dockerfile")
+	}
+
+	got := string(FeaturesDockerfile(dockerfile, Features{Go: "1.24", Rust: "stable", Python: "3.12.7", Apt: []string{"ripgrep", "jq"}}))
+	if !strings.HasPrefix(got, string(dockerfile)) {
+		t.Error("features must be layered after the embedded Dockerfile")
+	}
+	for _, want := range []string{
+		"apt-get install -y --no-install-recommends ripgrep jq",
+		`grep -o '"go1\.24\(\.[0-9][0-9]*\)\?"'`,
+		"--default-toolchain stable",
+		"uv python install 3.12.7",
+		"/usr/local/bin/python3.12",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated Dockerfile has no %q:\n%s", want, got)
+		}
+	}
+	// Layers are in a fixed order, so that the same features give the same
+	// Dockerfile and image tag.
+	if strings.Index(got, "apt-get install -y --no-install-recommends ripgrep") > strings.Index(got, "GO_VERSION") ||
+		strings.Index(got, "GO_VERSION") > strings.Index(got, "rustup") ||
+		strings.Index(got, "rustup") > strings.Index(got, "uv python") {
+		t.Errorf("layers out of order:\n%s", got)
+	}
+
+	// An exact Go release is not looked up.
+	got = string(FeaturesDockerfile(dockerfile, Features{Go: "1.24.2"}))
+	if !strings.Contains(got, `GO_VERSION="go1.24.2"`) || strings.Contains(got, "mode=json") {
+		t.Errorf("generated Dockerfile for go 1.24.2:\n%s", got)
+	}
+}
+
+func TestFeaturesString(t *testing.T) {
+	f := Features{Go: "1.24", Apt: []string{"ripgrep", "jq"}}
+	if got := f.String(); got != "go 1.24, apt ripgrep jq" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestPrepareBuildContext_Features(t *testing.T) {
+	dir, cleanup, err := PrepareBuildContext("", "", Features{Rust: "stable"})
+	if err != nil {
+		t.Fatalf("PrepareBuildContext() error: %v", err)
+	}
+	defer cleanup()
+
+	got, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "--default-toolchain stable") {
+		t.Errorf("Dockerfile has no rust layer:\n%s", got)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"syscall"
 
-	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
 )
@@ -33,38 +32,36 @@ func (l *ClaudeLauncher) launchHostClaude(ec *pipeline.ExecutionContext) error {
 		return fmt.Errorf("claude is not installed. Install Claude Code: https://claude.ai/install.sh")
 	}
 
-	fmt.Fprintf(os.Stderr, "Launching Claude in %s\n", ec.WorkDir)
-
 	args := []string{"claude"}
+	startSession(ec, fmt.Sprintf("Launching Claude in %s", ec.WorkDir), args)
+
 	// Use syscall.Exec to replace the current process
-	env := os.Environ()
+	env := append(os.Environ(), ec.TaskEnv()...)
 	return syscall.Exec(claudePath, args, env)
 }
 
 func (l *ClaudeLauncher) launchDockerClaude(ctx context.Context, ec *pipeline.ExecutionContext) error {
-	client := docker.NewShellClient()
-
-	command := []string{"claude", "--dangerously-skip-permissions"}
+	client := dockerClient(ec)
 
-	envVars := make(map[string]string, len(ec.EnvVars)+2)
-	for k, v := range ec.EnvVars {
-		envVars[k] = v
-	}
-	// Hardcoded vars always win — users cannot override these
-	envVars["HOST_CLAUDE_HOME"] = claudeHomePath(ec.HomeDir)
-	envVars["HOST_WORKSPACE"] = ec.WorkDir
+	startSession(ec, "", dockerRunArgv(ec, dockerClaudeCommand))
+	return client.Run(ctx, dockerRunConfig(ec, dockerClaudeCommand))
+}
 
-	runConfig := docker.RunConfig{
-		ImageName: ec.DockerImage,
-		Mounts:    ec.DockerMounts,
-		EnvVars:   envVars,
-		WorkDir:   ec.WorkDir,
-		Command:   command,
+// Plan reports the command Launch would run.
+func (l *ClaudeLauncher) Plan(_ context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	switch ec.Profile.Environment {
+	case profile.EnvironmentHost:
+		return []pipeline.Action{{Description: "run Claude in " + ec.WorkDir, Command: []string{"claude"}}}, nil
+	case profile.EnvironmentDocker:
+		return []pipeline.Action{{Description: "run Claude in container " + ec.ContainerName, Command: dockerRunArgv(ec, dockerClaudeCommand)}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported environment: %q", ec.Profile.Environment)
 	}
-
-	return client.Run(ctx, runConfig)
 }
 
+// dockerClaudeCommand starts Claude Code inside the container.
+var dockerClaudeCommand = []string{"claude", "--dangerously-skip-permissions"}
+
 func claudeHomePath(homeDir string) string {
 	if v := os.Getenv("CLAUDE_HOME"); v != "" {
 		return v
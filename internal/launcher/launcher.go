@@ -2,7 +2,11 @@ package launcher
 
 import (
 	"context"
+	"os"
+	"strconv"
+	"strings"
 
+	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 )
 
@@ -10,3 +14,67 @@ import (
 type Launcher interface {
 	Launch(ctx context.Context, ec *pipeline.ExecutionContext) error
 }
+
+// dockerClient returns the client chosen by DockerStage, falling back to the
+// docker CLI.
+func dockerClient(ec *pipeline.ExecutionContext) docker.Client {
+	if ec.DockerClient != nil {
+		return ec.DockerClient
+	}
+	return docker.NewShellClient()
+}
+
+// startSession commits the run and reports the command a launcher is about
+// to run. Launchers call it right before running or exec'ing the session:
+// from then on, a failure is the session's (e.g. its exit status or `aw
+// stop`), and the worktree holding the user's work must not be rolled back.
+func startSession(ec *pipeline.ExecutionContext, message string, command []string) {
+	ec.Commit()
+	ec.Emit(pipeline.Event{
+		Kind:    pipeline.Launching,
+		Message: message,
+		Fields:  map[string]string{"command": ShellJoin(command)},
+	})
+}
+
+// dockerRunArgv returns the full command line that runs command in a
+// container, as the CLI client would execute it.
+func dockerRunArgv(ec *pipeline.ExecutionContext, command []string) []string {
+	return append([]string{ec.DockerRuntime.Command()}, docker.BuildRunArgs(dockerRunConfig(ec, command))...)
+}
+
+// dockerRunConfig builds the RunConfig shared by every docker launcher.
+func dockerRunConfig(ec *pipeline.ExecutionContext, command []string) docker.RunConfig {
+	envVars := make(map[string]string, len(ec.EnvVars)+4)
+	for k, v := range ec.EnvVars {
+		envVars[k] = v
+	}
+	// Hardcoded vars always win — users cannot override these
+	envVars["HOST_CLAUDE_HOME"] = claudeHomePath(ec.HomeDir)
+	envVars["HOST_WORKSPACE"] = ec.WorkDir
+	for _, kv := range ec.TaskEnv() {
+		k, v, _ := strings.Cut(kv, "=")
+		envVars[k] = v
+	}
+
+	runConfig := docker.RunConfig{
+		ImageName: ec.DockerImage,
+		Name:      ec.ContainerName,
+		Mounts:    ec.DockerMounts,
+		EnvVars:   envVars,
+		WorkDir:   ec.WorkDir,
+		Command:   command,
+	}
+
+	// With a keep-id user namespace the container would otherwise start as
+	// the host user; entrypoint.sh needs root to remap the claude user onto
+	// the host UID/GID before dropping privileges.
+	if userns := ec.DockerRuntime.UserNS(); userns != "" {
+		runConfig.UserNS = userns
+		runConfig.User = "0:0"
+		envVars["AW_HOST_UID"] = strconv.Itoa(os.Getuid())
+		envVars["AW_HOST_GID"] = strconv.Itoa(os.Getgid())
+	}
+
+	return runConfig
+}
@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"syscall"
 
-	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
 )
@@ -27,41 +26,41 @@ func (l *ShellLauncher) Launch(ctx context.Context, ec *pipeline.ExecutionContex
 }
 
 func (l *ShellLauncher) launchHostShell(ec *pipeline.ExecutionContext) error {
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/sh"
-	}
-
+	shell := hostShell()
 	shellPath, err := exec.LookPath(shell)
 	if err != nil {
 		return fmt.Errorf("shell not found: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Opening shell in %s\n", ec.WorkDir)
+	startSession(ec, fmt.Sprintf("Opening shell in %s", ec.WorkDir), []string{shell})
 
 	// Use syscall.Exec to replace the current process
-	env := os.Environ()
+	env := append(os.Environ(), ec.TaskEnv()...)
 	return syscall.Exec(shellPath, []string{shell}, env)
 }
 
 func (l *ShellLauncher) launchDockerShell(ctx context.Context, ec *pipeline.ExecutionContext) error {
-	client := docker.NewShellClient()
+	client := dockerClient(ec)
 
-	envVars := make(map[string]string, len(ec.EnvVars)+2)
-	for k, v := range ec.EnvVars {
-		envVars[k] = v
-	}
-	// Hardcoded vars always win — users cannot override these
-	envVars["HOST_CLAUDE_HOME"] = claudeHomePath(ec.HomeDir)
-	envVars["HOST_WORKSPACE"] = ec.WorkDir
+	startSession(ec, "", dockerRunArgv(ec, []string{"/bin/bash"}))
+	return client.Run(ctx, dockerRunConfig(ec, []string{"/bin/bash"}))
+}
 
-	runConfig := docker.RunConfig{
-		ImageName: ec.DockerImage,
-		Mounts:    ec.DockerMounts,
-		EnvVars:   envVars,
-		WorkDir:   ec.WorkDir,
-		Command:   []string{"/bin/bash"},
+// Plan reports the command Launch would run.
+func (l *ShellLauncher) Plan(_ context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	switch ec.Profile.Environment {
+	case profile.EnvironmentHost:
+		return []pipeline.Action{{Description: "open a shell in " + ec.WorkDir, Command: []string{hostShell()}}}, nil
+	case profile.EnvironmentDocker:
+		return []pipeline.Action{{Description: "open a shell in container " + ec.ContainerName, Command: dockerRunArgv(ec, []string{"/bin/bash"})}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported environment: %q", ec.Profile.Environment)
 	}
+}
 
-	return client.Run(ctx, runConfig)
+func hostShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
 }
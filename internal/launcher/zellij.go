@@ -10,7 +10,6 @@ import (
 	"strings"
 	"text/template"
 
-	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
 )
@@ -24,7 +23,7 @@ type layoutData struct {
 // ZellijLauncher launches a zellij session with multiple panes.
 type ZellijLauncher struct{}
 
-func (l *ZellijLauncher) Launch(_ context.Context, ec *pipeline.ExecutionContext) error {
+func (l *ZellijLauncher) Launch(ctx context.Context, ec *pipeline.ExecutionContext) error {
 	if _, err := exec.LookPath("zellij"); err != nil {
 		return fmt.Errorf("zellij is not installed (brew install zellij)")
 	}
@@ -37,13 +36,20 @@ func (l *ZellijLauncher) Launch(_ context.Context, ec *pipeline.ExecutionContext
 	defer cleanup()
 
 	// Launch zellij
-	sessionName := ec.WorktreeBranch
-	if sessionName == "" {
-		sessionName = ec.ProfileName
-	}
+	sessionName := ZellijSessionName(ec)
+
+	startSession(ec, fmt.Sprintf("Launching zellij session: %s", sessionName),
+		[]string{"zellij", "--new-session-with-layout", filepath.Join(tmpDir, "layout.kdl"), "-s", sessionName})
+	return l.launchZellij(ctx, ec.WorkDir, tmpDir, sessionName, ec.WorktreeBase, ec.TaskEnv())
+}
 
-	fmt.Fprintf(os.Stderr, "Launching zellij session: %s\n", sessionName)
-	return l.launchZellij(ec.WorkDir, tmpDir, sessionName, ec.WorktreeBase)
+// ZellijSessionName returns the zellij session name used for ec: the
+// worktree branch if one was created, otherwise the profile name.
+func ZellijSessionName(ec *pipeline.ExecutionContext) string {
+	if ec.WorktreeBranch != "" {
+		return ec.WorktreeBranch
+	}
+	return ec.ProfileName
 }
 
 func (l *ZellijLauncher) prepareFiles(ec *pipeline.ExecutionContext) (string, func(), error) {
@@ -102,38 +108,40 @@ func (l *ZellijLauncher) prepareFiles(ec *pipeline.ExecutionContext) (string, fu
 }
 
 func (l *ZellijLauncher) buildClaudeCommand(ec *pipeline.ExecutionContext) string {
+	return ShellJoin(l.claudeArgv(ec))
+}
+
+func (l *ZellijLauncher) claudeArgv(ec *pipeline.ExecutionContext) []string {
 	switch ec.Profile.Environment {
 	case profile.EnvironmentDocker:
 		// Build docker run command directly using the image already built
 		// by the DockerStage, so we don't re-run the pipeline with a
 		// different profile that would lose custom Dockerfile settings.
-		envVars := make(map[string]string, len(ec.EnvVars)+2)
-		for k, v := range ec.EnvVars {
-			envVars[k] = v
-		}
-		envVars["HOST_CLAUDE_HOME"] = claudeHomePath(ec.HomeDir)
-		envVars["HOST_WORKSPACE"] = ec.WorkDir
-
-		runConfig := docker.RunConfig{
-			ImageName: ec.DockerImage,
-			Mounts:    ec.DockerMounts,
-			EnvVars:   envVars,
-			WorkDir:   ec.WorkDir,
-			Command:   []string{"bash", "-c", "claude --dangerously-skip-permissions; exec bash -i"},
-		}
-		args := docker.BuildRunArgs(runConfig)
-		return "docker " + shellJoin(args)
+		return dockerRunArgv(ec, []string{"bash", "-c", "claude --dangerously-skip-permissions; exec bash -i"})
 	default:
 		// Host mode: just run claude directly
-		return "claude"
+		return []string{"claude"}
 	}
 }
 
-// shellJoin quotes arguments for safe shell embedding.
-func shellJoin(args []string) string {
+// Plan reports the zellij session Launch would start and the command its
+// Claude pane would run.
+func (l *ZellijLauncher) Plan(_ context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	sessionName := ZellijSessionName(ec)
+	return []pipeline.Action{
+		{Description: "run Claude in the zellij layout's main pane", Command: l.claudeArgv(ec)},
+		{
+			Description: fmt.Sprintf("start zellij session %s in %s", sessionName, ec.WorkDir),
+			Command:     []string{"zellij", "--new-session-with-layout", "<layout.kdl>", "-s", sessionName},
+		},
+	}, nil
+}
+
+// ShellJoin quotes arguments for safe shell embedding.
+func ShellJoin(args []string) string {
 	quoted := make([]string, len(args))
 	for i, a := range args {
-		if strings.ContainsAny(a, " \t\n\"'\\$`!#&|;(){}") {
+		if strings.ContainsAny(a, " \t\n\"'\\$`!#&|;(){}<>") {
 			quoted[i] = "'" + strings.ReplaceAll(a, "'", "'\"'\"'") + "'"
 		} else {
 			quoted[i] = a
@@ -142,9 +150,9 @@ func shellJoin(args []string) string {
 	return strings.Join(quoted, " ")
 }
 
-func (l *ZellijLauncher) launchZellij(workDir, tmpDir, sessionName, baseRef string) error {
+func (l *ZellijLauncher) launchZellij(ctx context.Context, workDir, tmpDir, sessionName, baseRef string, taskEnv []string) error {
 	layoutPath := filepath.Join(tmpDir, "layout.kdl")
-	cmd := exec.Command("zellij",
+	cmd := exec.CommandContext(ctx, "zellij",
 		"--new-session-with-layout", layoutPath,
 		"-s", sessionName)
 	cmd.Dir = workDir
@@ -155,5 +163,43 @@ func (l *ZellijLauncher) launchZellij(workDir, tmpDir, sessionName, baseRef stri
 	if baseRef != "" {
 		cmd.Env = append(cmd.Env, "AW_BASE_REF="+baseRef)
 	}
+	cmd.Env = append(cmd.Env, taskEnv...)
+	return cmd.Run()
+}
+
+// ListZellijSessions returns the names of the zellij sessions known to the
+// zellij server.
+func ListZellijSessions() ([]string, error) {
+	out, err := exec.Command("zellij", "list-sessions", "--short", "--no-formatting").Output()
+	if err != nil {
+		// zellij exits non-zero when there are no sessions at all
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// AttachZellijSession attaches the current terminal to an existing session.
+func AttachZellijSession(sessionName string) error {
+	cmd := exec.Command("zellij", "attach", sessionName)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// KillZellijSession terminates a zellij session.
+func KillZellijSession(sessionName string) error {
+	cmd := exec.Command("zellij", "kill-session", sessionName)
+	cmd.Stdout = nil
+	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
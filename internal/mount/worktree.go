@@ -42,9 +42,17 @@ func DetectWorktree(workDir string) (string, error) {
 		gitdir = filepath.Join(workDir, gitdir)
 	}
 
-	// gitdir points to .git/worktrees/<name>
-	// Go up 2 levels to get the main .git directory
+	// gitdir points to .git/worktrees/<name>, which holds the worktree's
+	// HEAD, index, sparse-checkout settings and submodule repositories, so
+	// mounting the main .git directory covers them. Its commondir file
+	// names the main .git directory; without one, go up 2 levels.
 	mainGitDir := filepath.Clean(filepath.Join(gitdir, "..", ".."))
+	if common, err := os.ReadFile(filepath.Join(gitdir, "commondir")); err == nil {
+		mainGitDir = strings.TrimSpace(string(common))
+		if !filepath.IsAbs(mainGitDir) {
+			mainGitDir = filepath.Join(gitdir, mainGitDir)
+		}
+	}
 
 	// Resolve to absolute path
 	mainGitDir, err = filepath.Abs(mainGitDir)
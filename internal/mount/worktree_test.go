@@ -70,6 +70,33 @@ func TestDetectWorktree_WorktreeGitFile(t *testing.T) {
 	}
 }
 
+func TestDetectWorktree_Commondir(t *testing.T) {
+	// git records the main .git directory in the worktree's commondir file,
+	// relative to the worktree's git directory. Here the main repository is
+	// bare, so its git directory is not called .git.
+	mainGitDir := filepath.Join(t.TempDir(), "repo.git")
+	gitdirPath := filepath.Join(mainGitDir, "worktrees", "my-worktree")
+	if err := os.MkdirAll(gitdirPath, 0755); err != nil {
+		t.Fatalf("creating worktree dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitdirPath, "commondir"), []byte("../..\n"), 0644); err != nil {
+		t.Fatalf("writing commondir: %v", err)
+	}
+
+	worktreeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktreeDir, ".git"), []byte("gitdir: "+gitdirPath+"\n"), 0644); err != nil {
+		t.Fatalf("writing .git file: %v", err)
+	}
+
+	result, err := DetectWorktree(worktreeDir)
+	if err != nil {
+		t.Fatalf("DetectWorktree() error: %v", err)
+	}
+	if result != mainGitDir {
+		t.Errorf("DetectWorktree() = %q, want %q", result, mainGitDir)
+	}
+}
+
 func TestDetectWorktree_RelativeGitdir(t *testing.T) {
 	// Create structure where .git file uses a relative path
 	baseDir := t.TempDir()
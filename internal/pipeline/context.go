@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"strconv"
+
 	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/profile"
 )
@@ -8,23 +10,64 @@ import (
 // ExecutionContext carries mutable state through pipeline stages.
 type ExecutionContext struct {
 	// Input (set before pipeline runs)
-	Profile     profile.Profile
-	ProfileName string
-	HomeDir     string
-	OrigWorkDir string // directory where `aw` was invoked
+	Profile       profile.Profile
+	ProfileName   string
+	HomeDir       string
+	OrigWorkDir   string // directory where `aw` was invoked
+	ReuseWorktree string // existing worktree to resume (name, branch or path); empty to follow worktree.reuse
+	NewBranch     string // branch name for a new worktree; empty to expand worktree.branch
+	PRNumber      int    // pull request to check out into the new worktree; 0 for none
+	IssueNumber   int    // issue the run is for; 0 for none
+	Offline       bool   // never fetch; start new worktrees from local refs
 
 	// Set by WorktreeStage (if applicable)
 	WorkDir        string // effective working directory (may be worktree path)
 	WorktreePath   string // empty if no worktree was created
 	WorktreeBranch string // branch name of the created worktree
 	WorktreeBase   string // base ref the worktree was created from (e.g. "origin/main")
+	WorktreeReused bool   // the worktree existed before this run; rollback leaves it alone
 	RepoRoot       string // git repository root path
+	PromptFile     string // file in the worktree describing IssueNumber; empty without an issue
 
 	// Set by DockerStage (if applicable)
-	DockerImage  string
-	DockerMounts []docker.Mount
-	DockerVolume string
+	DockerImage   string
+	DockerMounts  []docker.Mount
+	DockerVolume  string
+	DockerClient  docker.Client      // client used by DockerStage; launchers reuse it
+	DockerRuntime docker.RuntimeInfo // container engine behind DockerClient
 
 	// Set by EnvStage (if applicable)
 	EnvVars map[string]string // custom env vars to pass into Docker container
+
+	// Set by SessionStage
+	SessionID     string // registry ID of this run
+	ContainerName string // name given to the launched container (docker environment only)
+
+	// Observer receives the events stages emit. If nil, events are printed
+	// for humans on stderr.
+	Observer Observer
+
+	stage     string // name of the running stage, for Emit
+	committed bool   // see Commit
+}
+
+// Commit marks the point where the launched session takes over. A failure
+// after it is the session's (e.g. its exit status), not the setup's, so
+// Execute no longer rolls back: the worktree holds the user's work.
+func (ec *ExecutionContext) Commit() {
+	ec.committed = true
+}
+
+// TaskEnv returns AW_PR_NUMBER and AW_ISSUE_NUMBER, as KEY=value pairs, for
+// the pull request or issue the run is for. Hooks, zellij panes and the
+// launched process see them.
+func (ec *ExecutionContext) TaskEnv() []string {
+	var env []string
+	if ec.PRNumber > 0 {
+		env = append(env, "AW_PR_NUMBER="+strconv.Itoa(ec.PRNumber))
+	}
+	if ec.IssueNumber > 0 {
+		env = append(env, "AW_ISSUE_NUMBER="+strconv.Itoa(ec.IssueNumber))
+	}
+	return env
 }
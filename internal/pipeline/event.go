@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EventKind identifies what an Event reports.
+type EventKind string
+
+// Events emitted by Pipeline.Execute for every stage.
+const (
+	StageStarted    EventKind = "stage_started"
+	StageFinished   EventKind = "stage_finished"    // Duration is set
+	StageFailed     EventKind = "stage_failed"      // Duration and Err are set
+	StageRolledBack EventKind = "stage_rolled_back" // Duration is set; Err if the rollback failed
+)
+
+// Events emitted by stages.
+const (
+	Info    EventKind = "info"    // progress message
+	Warning EventKind = "warning" // something went wrong but the run continues
+
+	BaseFetched     EventKind = "base_fetched"     // fields: ref
+	WorktreeCreated EventKind = "worktree_created" // fields: path, branch, base
+	WorktreeReused  EventKind = "worktree_reused"  // fields: path, branch, base
+	FilesCopied     EventKind = "files_copied"     // fields: pattern, count; Duration is set
+	HookFinished    EventKind = "hook_finished"    // fields: hook; Duration is set
+	ImageBuilt      EventKind = "image_built"      // fields: image; Duration is set
+	ImageCached     EventKind = "image_cached"     // fields: image; the image existed, so it was not built or pulled
+	ImagePulled     EventKind = "image_pulled"     // fields: image; Duration is set
+	VolumeCreated   EventKind = "volume_created"   // fields: volume
+	EnvLoaded       EventKind = "env_loaded"       // fields: count
+	SessionRecorded EventKind = "session_recorded" // fields: id, container (docker only)
+	Launching       EventKind = "launching"        // fields: command
+)
+
+// Event is something that happened while the pipeline ran.
+type Event struct {
+	Time     time.Time
+	Kind     EventKind
+	Stage    string            // stage that emitted the event; empty outside a stage
+	Message  string            // human-readable summary; may be empty
+	Duration time.Duration     // how long the step took, when it has a length
+	Err      error             // the failure, for StageFailed
+	Fields   map[string]string // kind-specific details
+}
+
+// Observer receives pipeline events. Observers are called synchronously
+// from the stage emitting the event and must not block.
+type Observer interface {
+	Observe(e Event)
+}
+
+// Observers sends every event to each observer in turn.
+type Observers []Observer
+
+func (o Observers) Observe(e Event) {
+	for _, obs := range o {
+		obs.Observe(e)
+	}
+}
+
+// Emit sends e to the context's observer, stamping it with the time and the
+// running stage. Without an observer, events are printed for humans on
+// stderr.
+func (ec *ExecutionContext) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Stage == "" {
+		e.Stage = ec.stage
+	}
+	obs := ec.Observer
+	if obs == nil {
+		obs = NewHumanSink(os.Stderr)
+	}
+	obs.Observe(e)
+}
+
+// Logf emits an Info event.
+func (ec *ExecutionContext) Logf(format string, args ...any) {
+	ec.Emit(Event{Kind: Info, Message: fmt.Sprintf(format, args...)})
+}
+
+// Warnf emits a Warning event.
+func (ec *ExecutionContext) Warnf(format string, args ...any) {
+	ec.Emit(Event{Kind: Warning, Message: fmt.Sprintf(format, args...)})
+}
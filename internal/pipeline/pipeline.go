@@ -3,7 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
-	"os"
+	"time"
 )
 
 // Stage is a single step in the execution pipeline.
@@ -14,9 +14,20 @@ type Stage interface {
 	Run(ctx context.Context, ec *ExecutionContext) error
 }
 
+// Rollbacker is implemented by stages that can undo what Run did, e.g.
+// remove a worktree they created. Rollback must cope with a Run that stopped
+// part way through.
+type Rollbacker interface {
+	Rollback(ctx context.Context, ec *ExecutionContext) error
+}
+
 // Pipeline executes a sequence of stages.
 type Pipeline struct {
 	stages []Stage
+
+	// KeepOnFailure leaves whatever a failed run created in place instead of
+	// rolling it back, for debugging.
+	KeepOnFailure bool
 }
 
 // New creates a pipeline from the given stages.
@@ -24,17 +35,53 @@ func New(stages ...Stage) *Pipeline {
 	return &Pipeline{stages: stages}
 }
 
-// Execute runs all stages in sequence.
+// Execute runs all stages in sequence, emitting StageStarted and
+// StageFinished or StageFailed events around each.
+//
+// If a stage fails before the run is committed (see
+// ExecutionContext.Commit), the failed stage and every stage before it are
+// rolled back, latest first. Rollback errors are reported as events; the
+// stage's error is returned either way.
 func (p *Pipeline) Execute(ctx context.Context, ec *ExecutionContext) error {
-	for _, s := range p.stages {
-		fmt.Fprintf(os.Stderr, "[%s]\n", s.Name())
+	defer func() { ec.stage = "" }()
+	for i, s := range p.stages {
+		ec.stage = s.Name()
+		ec.Emit(Event{Kind: StageStarted})
+		start := time.Now()
 		if err := s.Run(ctx, ec); err != nil {
+			ec.Emit(Event{Kind: StageFailed, Duration: time.Since(start), Err: err})
+			p.rollback(ctx, ec, p.stages[:i+1])
 			return fmt.Errorf("%s: %w", s.Name(), err)
 		}
+		ec.Emit(Event{Kind: StageFinished, Duration: time.Since(start)})
 	}
 	return nil
 }
 
+// rollback undoes stages in reverse order. It runs even if ctx was
+// cancelled: an interrupt is one of the failures it cleans up after.
+func (p *Pipeline) rollback(ctx context.Context, ec *ExecutionContext, stages []Stage) {
+	if ec.committed {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if p.KeepOnFailure {
+		ec.stage = ""
+		ec.Logf("Keeping what the failed run created; nothing was rolled back")
+		return
+	}
+	for i := len(stages) - 1; i >= 0; i-- {
+		r, ok := stages[i].(Rollbacker)
+		if !ok {
+			continue
+		}
+		ec.stage = stages[i].Name()
+		start := time.Now()
+		err := r.Rollback(ctx, ec)
+		ec.Emit(Event{Kind: StageRolledBack, Duration: time.Since(start), Err: err})
+	}
+}
+
 // Stages returns the list of stages (for testing).
 func (p *Pipeline) Stages() []Stage {
 	return p.stages
@@ -93,3 +93,119 @@ func TestPipeline_Stages(t *testing.T) {
 		t.Errorf("Stages() returned wrong stages")
 	}
 }
+
+// recorder is an Observer that keeps every event.
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Observe(e Event) { r.events = append(r.events, e) }
+
+func (r *recorder) kinds() []string {
+	var kinds []string
+	for _, e := range r.events {
+		kinds = append(kinds, e.Stage+":"+string(e.Kind))
+	}
+	return kinds
+}
+
+func TestPipeline_Execute_EmitsStageEvents(t *testing.T) {
+	rec := &recorder{}
+	ec := &ExecutionContext{Observer: rec}
+	s1 := &mockStage{name: "stage-1"}
+	s2 := &mockStage{name: "stage-2", err: fmt.Errorf("boom")}
+
+	_ = New(s1, s2).Execute(context.Background(), ec)
+
+	want := "stage-1:stage_started stage-1:stage_finished stage-2:stage_started stage-2:stage_failed"
+	if got := strings.Join(rec.kinds(), " "); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	failed := rec.events[3]
+	if failed.Err == nil || failed.Err.Error() != "boom" {
+		t.Errorf("StageFailed.Err = %v, want boom", failed.Err)
+	}
+	for _, e := range rec.events {
+		if e.Time.IsZero() {
+			t.Errorf("%s event has no time", e.Kind)
+		}
+	}
+
+	ec.Logf("after %d", 2)
+	if last := rec.events[len(rec.events)-1]; last.Stage != "" || last.Message != "after 2" {
+		t.Errorf("event outside a stage = %+v, want no stage", last)
+	}
+}
+
+// rollbackStage records the order stages are rolled back in.
+type rollbackStage struct {
+	mockStage
+	log    *[]string
+	err    error
+	commit bool // commit the run before failing
+}
+
+func (s *rollbackStage) Run(ctx context.Context, ec *ExecutionContext) error {
+	if s.commit {
+		ec.Commit()
+	}
+	return s.mockStage.Run(ctx, ec)
+}
+
+func (s *rollbackStage) Rollback(_ context.Context, _ *ExecutionContext) error {
+	*s.log = append(*s.log, s.name)
+	return s.err
+}
+
+func TestPipeline_Execute_RollsBackInReverse(t *testing.T) {
+	var log []string
+	s1 := &rollbackStage{mockStage: mockStage{name: "stage-1"}, log: &log}
+	s2 := &mockStage{name: "stage-2"}
+	s3 := &rollbackStage{mockStage: mockStage{name: "stage-3"}, log: &log, err: fmt.Errorf("busy")}
+	s4 := &rollbackStage{mockStage: mockStage{name: "stage-4", err: fmt.Errorf("boom")}, log: &log}
+	s5 := &rollbackStage{mockStage: mockStage{name: "stage-5"}, log: &log}
+
+	rec := &recorder{}
+	err := New(s1, s2, s3, s4, s5).Execute(context.Background(), &ExecutionContext{Observer: rec})
+	if err == nil || !strings.Contains(err.Error(), "stage-4: boom") {
+		t.Fatalf("Execute() error = %v, want the stage error", err)
+	}
+
+	if got := strings.Join(log, " "); got != "stage-4 stage-3 stage-1" {
+		t.Errorf("rolled back %q, want the failed stage and earlier ones, latest first", got)
+	}
+	var failed []string
+	for _, e := range rec.events {
+		if e.Kind == StageRolledBack && e.Err != nil {
+			failed = append(failed, e.Stage)
+		}
+	}
+	if strings.Join(failed, " ") != "stage-3" {
+		t.Errorf("failed rollbacks = %v, want stage-3", failed)
+	}
+}
+
+func TestPipeline_Execute_NoRollback(t *testing.T) {
+	tests := []struct {
+		name   string
+		keep   bool
+		commit bool
+	}{
+		{"keep on failure", true, false},
+		{"committed", false, true},
+	}
+	for _, tt := range tests {
+		var log []string
+		s1 := &rollbackStage{mockStage: mockStage{name: "stage-1"}, log: &log}
+		s2 := &rollbackStage{mockStage: mockStage{name: "stage-2", err: fmt.Errorf("boom")}, log: &log, commit: tt.commit}
+
+		p := New(s1, s2)
+		p.KeepOnFailure = tt.keep
+		if err := p.Execute(context.Background(), &ExecutionContext{Observer: &recorder{}}); err == nil {
+			t.Fatalf("%s: Execute() expected error", tt.name)
+		}
+		if len(log) != 0 {
+			t.Errorf("%s: rolled back %v, want nothing", tt.name, log)
+		}
+	}
+}
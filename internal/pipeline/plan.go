@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// Action is one thing a stage would do.
+type Action struct {
+	Description string   `json:"description"`
+	Command     []string `json:"command,omitempty"` // argv, when the action runs a command
+}
+
+// StagePlan lists the actions one stage would take.
+type StagePlan struct {
+	Stage   string   `json:"stage"`
+	Actions []Action `json:"actions"`
+}
+
+// Planner is implemented by stages that support dry runs.
+type Planner interface {
+	// Plan describes what Run would do without doing it: it must not create
+	// worktrees, build images, start containers or write files. It updates
+	// the ExecutionContext as Run would, so later stages plan against the
+	// same state.
+	Plan(ctx context.Context, ec *ExecutionContext) ([]Action, error)
+}
+
+// Plan runs every stage in planning mode and returns what each would do.
+func (p *Pipeline) Plan(ctx context.Context, ec *ExecutionContext) ([]StagePlan, error) {
+	plans := make([]StagePlan, 0, len(p.stages))
+	for _, s := range p.stages {
+		planner, ok := s.(Planner)
+		if !ok {
+			return nil, fmt.Errorf("%s: stage does not support dry runs", s.Name())
+		}
+		actions, err := planner.Plan(ctx, ec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name(), err)
+		}
+		plans = append(plans, StagePlan{Stage: s.Name(), Actions: actions})
+	}
+	return plans, nil
+}
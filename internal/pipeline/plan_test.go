@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// mockPlanner is a mockStage that also supports dry runs.
+type mockPlanner struct {
+	mockStage
+	actions []Action
+	planned bool
+}
+
+func (s *mockPlanner) Plan(_ context.Context, ec *ExecutionContext) ([]Action, error) {
+	s.planned = true
+	ec.WorkDir = "/planned/" + s.name
+	return s.actions, nil
+}
+
+func TestPipeline_Plan(t *testing.T) {
+	s1 := &mockPlanner{mockStage: mockStage{name: "stage-1"}, actions: []Action{{Description: "one", Command: []string{"echo", "1"}}}}
+	s2 := &mockPlanner{mockStage: mockStage{name: "stage-2"}}
+
+	ec := &ExecutionContext{}
+	plans, err := New(s1, s2).Plan(context.Background(), ec)
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+
+	if len(plans) != 2 || plans[0].Stage != "stage-1" || plans[1].Stage != "stage-2" {
+		t.Fatalf("plans = %+v, want one per stage in order", plans)
+	}
+	if len(plans[0].Actions) != 1 || plans[0].Actions[0].Description != "one" {
+		t.Errorf("stage-1 actions = %+v", plans[0].Actions)
+	}
+	for _, s := range []*mockPlanner{s1, s2} {
+		if !s.planned || s.ran {
+			t.Errorf("stage %q: planned = %v, ran = %v; want planned only", s.name, s.planned, s.ran)
+		}
+	}
+	if ec.WorkDir != "/planned/stage-2" {
+		t.Errorf("WorkDir = %q, want the last stage's update", ec.WorkDir)
+	}
+}
+
+func TestPipeline_Plan_StageWithoutPlanner(t *testing.T) {
+	s1 := &mockPlanner{mockStage: mockStage{name: "stage-1"}}
+	s2 := &mockStage{name: "stage-2"}
+
+	_, err := New(s1, s2).Plan(context.Background(), &ExecutionContext{})
+	if err == nil || !strings.Contains(err.Error(), "stage-2: stage does not support dry runs") {
+		t.Errorf("Plan() error = %v, want stage-2 to be reported", err)
+	}
+	if s2.ran {
+		t.Error("stage without a planner must not run")
+	}
+}
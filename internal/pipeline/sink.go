@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HumanSink prints events the way aw always has: a "[stage]" header per
+// stage followed by progress messages.
+type HumanSink struct {
+	w io.Writer
+}
+
+// NewHumanSink creates a HumanSink writing to w.
+func NewHumanSink(w io.Writer) *HumanSink {
+	return &HumanSink{w: w}
+}
+
+func (s *HumanSink) Observe(e Event) {
+	switch {
+	case e.Kind == StageStarted:
+		fmt.Fprintf(s.w, "[%s]\n", e.Stage)
+	case e.Kind == StageRolledBack && e.Err != nil:
+		fmt.Fprintf(s.w, "Warning: rolling back %s: %v\n", e.Stage, e.Err)
+	case e.Kind == StageRolledBack:
+		fmt.Fprintf(s.w, "Rolled back %s\n", e.Stage)
+	case e.Kind == Warning:
+		fmt.Fprintf(s.w, "Warning: %s\n", e.Message)
+	case e.Message != "":
+		fmt.Fprintln(s.w, e.Message)
+	}
+}
+
+// jsonEvent is the JSON-lines encoding of an Event.
+type jsonEvent struct {
+	Time       string            `json:"time"`
+	Event      EventKind         `json:"event"`
+	Stage      string            `json:"stage,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	DurationMS *float64          `json:"duration_ms,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// JSONSink writes one JSON object per event, for tools that wrap aw.
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink creates a JSONSink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+func (s *JSONSink) Observe(e Event) {
+	je := jsonEvent{
+		Time:    e.Time.Format(time.RFC3339Nano),
+		Event:   e.Kind,
+		Stage:   e.Stage,
+		Message: e.Message,
+		Fields:  e.Fields,
+	}
+	if e.Duration > 0 || e.Kind == StageFinished || e.Kind == StageFailed || e.Kind == StageRolledBack {
+		ms := float64(e.Duration) / float64(time.Millisecond)
+		je.DurationMS = &ms
+	}
+	if e.Err != nil {
+		je.Error = e.Err.Error()
+	}
+	line, err := json.Marshal(je)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(append(line, '\n'))
+}
+
+// SessionLog writes events as JSON lines to a file named after the session.
+// The session ID is only known once SessionStage has run, so events are held
+// in memory until a SessionRecorded event arrives.
+type SessionLog struct {
+	path    func(id string) string
+	pending []Event
+	file    *os.File
+	sink    *JSONSink
+	err     error
+}
+
+// NewSessionLog creates a SessionLog that writes to path(id).
+func NewSessionLog(path func(id string) string) *SessionLog {
+	return &SessionLog{path: path}
+}
+
+func (s *SessionLog) Observe(e Event) {
+	if s.sink != nil {
+		s.sink.Observe(e)
+		return
+	}
+	if s.err != nil {
+		return
+	}
+	s.pending = append(s.pending, e)
+	if e.Kind != SessionRecorded || e.Fields["id"] == "" {
+		return
+	}
+
+	path := s.path(e.Fields["id"])
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		s.err = err
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.file = f
+	s.sink = NewJSONSink(f)
+	for _, p := range s.pending {
+		s.sink.Observe(p)
+	}
+	s.pending = nil
+}
+
+// Close closes the log file. It returns the error that stopped the log from
+// being written, if any.
+func (s *SessionLog) Close() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewHumanSink(&buf)
+	for _, e := range []Event{
+		{Kind: StageStarted, Stage: "docker"},
+		{Kind: Info, Message: "Building Docker image 'x'..."},
+		{Kind: ImageBuilt, Fields: map[string]string{"image": "x"}},
+		{Kind: Warning, Message: "recording base ref: denied"},
+		{Kind: StageFinished, Stage: "docker", Duration: time.Second},
+	} {
+		s.Observe(e)
+	}
+
+	want := "[docker]\nBuilding Docker image 'x'...\nWarning: recording base ref: denied\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONSink(&buf)
+	at := time.Date(2026, 5, 2, 10, 14, 0, 0, time.UTC)
+	s.Observe(Event{Time: at, Kind: StageStarted, Stage: "docker"})
+	s.Observe(Event{Time: at, Kind: ImageBuilt, Stage: "docker", Duration: 1500 * time.Millisecond, Fields: map[string]string{"image": "x"}})
+	s.Observe(Event{Time: at, Kind: StageFailed, Stage: "docker", Err: errors.New("boom")})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if want := `{"time":"2026-05-02T10:14:00Z","event":"stage_started","stage":"docker"}`; lines[0] != want {
+		t.Errorf("line 0 = %s, want %s", lines[0], want)
+	}
+
+	var built map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &built); err != nil {
+		t.Fatal(err)
+	}
+	if built["duration_ms"] != 1500.0 || built["fields"].(map[string]any)["image"] != "x" {
+		t.Errorf("image_built = %v", built)
+	}
+	if !strings.Contains(lines[2], `"duration_ms":0`) || !strings.Contains(lines[2], `"error":"boom"`) {
+		t.Errorf("stage_failed = %s, want a duration and the error", lines[2])
+	}
+}
+
+func TestSessionLog_WaitsForSessionID(t *testing.T) {
+	dir := t.TempDir()
+	log := NewSessionLog(func(id string) string { return filepath.Join(dir, "sessions", id+".log") })
+
+	log.Observe(Event{Kind: StageStarted, Stage: "worktree"})
+	log.Observe(Event{Kind: SessionRecorded, Stage: "session", Fields: map[string]string{"id": "3f9c2a1b"}})
+	log.Observe(Event{Kind: Launching, Stage: "launch"})
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sessions", "3f9c2a1b.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"stage_started"`) || !strings.Contains(lines[2], `"launching"`) {
+		t.Errorf("log =\n%s\nwant the buffered events followed by later ones", data)
+	}
+}
+
+func TestSessionLog_NoSession(t *testing.T) {
+	log := NewSessionLog(func(id string) string { t.Fatalf("path(%q) called without a session", id); return "" })
+	log.Observe(Event{Kind: StageStarted, Stage: "worktree"})
+	if err := log.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origin describes where the effective value of a profile field came from.
+type Origin struct {
+	Layer Layer  `json:"layer,omitempty"` // empty for built-in fallbacks
+	File  string `json:"file,omitempty"`  // config file; empty for the builtin layer
+	Scope string `json:"scope"`           // "top-level", "profile", `profile "x" via extends`, or "default"
+}
+
+// String formats o for a YAML comment, e.g.
+// "repo profile (/repo/.agent-workspace.yml)" or "default".
+func (o Origin) String() string {
+	if o.Layer == "" {
+		return o.Scope
+	}
+	s := string(o.Layer) + " " + o.Scope
+	if o.File != "" {
+		s += " (" + o.File + ")"
+	}
+	return s
+}
+
+// ExplainedField is one field of an explained profile.
+type ExplainedField struct {
+	Path  string `json:"path"`            // dotted YAML path, e.g. "worktree.base" or "env.FOO"
+	Value any    `json:"value,omitempty"` // omitted for mappings
+	Origin
+}
+
+// Explanation is the effective profile together with the origin of each of
+// its fields.
+type Explanation struct {
+	Name    string
+	Profile Profile // effective profile, with built-in fallbacks filled in
+	Fields  []ExplainedField
+}
+
+// Explain returns the effective profile name as loaded by LoadLayers, with
+// the origin of every field that is set.
+//
+// Origins follow the merge order: top-level defaults from each layer, then
+// each profile of the `extends` chain (root ancestor first) from each layer.
+// A scalar comes from the last of these to set it; a mapping such as
+// `worktree` is attributed to the first one that introduced it.
+func (c *Config) Explain(name string) (*Explanation, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	if len(c.Source.layers) == 0 {
+		return nil, fmt.Errorf("config was not loaded from layers; cannot explain profile %q", name)
+	}
+	if _, err := ResolveExtends(c.Profiles, name); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+
+	srcs, err := c.fieldSources(name)
+	if err != nil {
+		return nil, err
+	}
+	origins := make(map[string]Origin, len(srcs))
+	for path, src := range srcs {
+		origins[path] = src.Origin
+	}
+
+	effective := withDefaults(p, origins)
+	node, err := profileNode(effective)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Explanation{Name: name, Profile: effective}
+	var walkErr error
+	walkProfileNode(node, "", func(path string, _, v *yaml.Node) {
+		f := ExplainedField{Path: path, Origin: origins[path]}
+		if v.Kind != yaml.MappingNode {
+			if err := v.Decode(&f.Value); err != nil && walkErr == nil {
+				walkErr = err
+			}
+		}
+		e.Fields = append(e.Fields, f)
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return e, nil
+}
+
+// fieldSource records which layer, and which profile within it, set a field.
+type fieldSource struct {
+	Origin
+	layer   int    // index into Config.Source.layers
+	profile string // profile the field was set in; "" for top-level defaults
+}
+
+// fieldSources replays the merge order for profile name and returns the
+// source of every field path that some layer sets.
+func (c *Config) fieldSources(name string) (map[string]fieldSource, error) {
+	var chain []string
+	visited := make(map[string]bool)
+	for n := name; n != "" && !visited[n]; n = c.Profiles[n].Extends {
+		visited[n] = true
+		chain = append([]string{n}, chain...)
+	}
+
+	srcs := make(map[string]fieldSource)
+	record := func(contrib Profile, src fieldSource) error {
+		node, err := profileNode(contrib)
+		if err != nil {
+			return err
+		}
+		walkProfileNode(node, "", func(path string, _, v *yaml.Node) {
+			if _, seen := srcs[path]; seen && v.Kind == yaml.MappingNode {
+				return
+			}
+			srcs[path] = src
+		})
+		return nil
+	}
+
+	for i, l := range c.Source.layers {
+		o := Origin{Layer: l.File.Layer, File: l.File.Path, Scope: "top-level"}
+		if err := record(l.Config.Profile, fieldSource{Origin: o, layer: i}); err != nil {
+			return nil, err
+		}
+	}
+	for _, pn := range chain {
+		scope := "profile"
+		if pn != name {
+			scope = fmt.Sprintf("profile %q via extends", pn)
+		}
+		for i, l := range c.Source.layers {
+			if lp, ok := l.Config.Profiles[pn]; ok {
+				o := Origin{Layer: l.File.Layer, File: l.File.Path, Scope: scope}
+				if err := record(lp, fieldSource{Origin: o, layer: i, profile: pn}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return srcs, nil
+}
+
+// withDefaults fills in the values the rest of aw falls back to when a
+// field is unset, recording them as "default".
+func withDefaults(p Profile, origins map[string]Origin) Profile {
+	def := Origin{Scope: "default"}
+	if p.Worktree != nil && p.Worktree.Base == "" {
+		w := *p.Worktree
+		w.Base = w.EffectiveBase()
+		p.Worktree = &w
+		origins["worktree.base"] = def
+	}
+	if p.Environment == EnvironmentDocker && p.Runtime == "" {
+		p.Runtime = p.EffectiveRuntime()
+		origins["runtime"] = def
+	}
+	if p.Docker != nil && p.Docker.Client == "" {
+		d := *p.Docker
+		d.Client = d.EffectiveClient()
+		p.Docker = &d
+		origins["docker.client"] = def
+	}
+	if p.Image != "" && (p.Docker == nil || p.Docker.Pull == "") {
+		var d DockerConfig
+		if p.Docker != nil {
+			d = *p.Docker
+		}
+		d.Pull = d.EffectivePull()
+		p.Docker = &d
+		origins["docker.pull"] = def
+	}
+	return p
+}
+
+// YAML renders the effective profile with each field's origin as a line comment.
+func (e *Explanation) YAML() ([]byte, error) {
+	node, err := profileNode(e.Profile)
+	if err != nil {
+		return nil, err
+	}
+	comments := make(map[string]string, len(e.Fields))
+	for _, f := range e.Fields {
+		comments[f.Path] = f.Origin.String()
+	}
+	walkProfileNode(node, "", func(path string, k, _ *yaml.Node) {
+		k.LineComment = comments[path]
+	})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSON renders the effective profile and its field origins as JSON.
+func (e *Explanation) JSON() ([]byte, error) {
+	node, err := profileNode(e.Profile)
+	if err != nil {
+		return nil, err
+	}
+	var effective map[string]any
+	if err := node.Decode(&effective); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(struct {
+		Profile   string           `json:"profile"`
+		Effective map[string]any   `json:"effective"`
+		Fields    []ExplainedField `json:"fields"`
+	}{e.Name, effective, e.Fields}, "", "  ")
+}
+
+// profileNode encodes p as a YAML mapping node, dropping fields that are
+// unset (non-omitempty string fields encode as "").
+func profileNode(p Profile) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(p); err != nil {
+		return nil, fmt.Errorf("encoding profile: %w", err)
+	}
+	pruneEmpty(&node)
+	return &node, nil
+}
+
+func pruneEmpty(n *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	kept := n.Content[:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if v.Kind == yaml.ScalarNode && v.Tag == "!!str" && v.Value == "" {
+			continue
+		}
+		pruneEmpty(v)
+		kept = append(kept, k, v)
+	}
+	n.Content = kept
+}
+
+// walkProfileNode calls visit for every key of the mapping n and its nested
+// mappings, with the key's dotted path.
+func walkProfileNode(n *yaml.Node, prefix string, visit func(path string, key, value *yaml.Node)) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		path := k.Value
+		if prefix != "" {
+			path = prefix + "." + k.Value
+		}
+		visit(path, k, v)
+		walkProfileNode(v, path, visit)
+	}
+}
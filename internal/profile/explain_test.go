@@ -0,0 +1,164 @@
+package profile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadExplainFixture(t *testing.T) (*Config, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.yml")
+	repoPath := filepath.Join(dir, "repo.yml")
+
+	user := `
+worktree:
+  dir: ~/wt
+profiles:
+  base:
+    environment: docker
+    launch: claude
+    env:
+      A: "1"
+`
+	repo := `
+profiles:
+  fast:
+    extends: base
+    worktree: {}
+    env:
+      B: "2"
+  claude:
+    launch: shell
+`
+	if err := os.WriteFile(userPath, []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(repoPath, []byte(repo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadLayers([]SourceFile{
+		{Layer: LayerUser, Path: userPath},
+		{Layer: LayerRepo, Path: repoPath},
+	})
+	if err != nil {
+		t.Fatalf("LoadLayers() error: %v", err)
+	}
+	return cfg, userPath, repoPath
+}
+
+func TestExplain_Origins(t *testing.T) {
+	cfg, userPath, repoPath := loadExplainFixture(t)
+
+	e, err := cfg.Explain("fast")
+	if err != nil {
+		t.Fatalf("Explain() error: %v", err)
+	}
+
+	got := make(map[string]Origin)
+	for _, f := range e.Fields {
+		got[f.Path] = f.Origin
+	}
+	want := map[string]Origin{
+		"extends":       {Layer: LayerRepo, File: repoPath, Scope: "profile"},
+		"worktree":      {Layer: LayerUser, File: userPath, Scope: "top-level"},
+		"worktree.base": {Scope: "default"},
+		"worktree.dir":  {Layer: LayerUser, File: userPath, Scope: "top-level"},
+		"environment":   {Layer: LayerUser, File: userPath, Scope: `profile "base" via extends`},
+		"launch":        {Layer: LayerUser, File: userPath, Scope: `profile "base" via extends`},
+		"env.A":         {Layer: LayerUser, File: userPath, Scope: `profile "base" via extends`},
+		"env.B":         {Layer: LayerRepo, File: repoPath, Scope: "profile"},
+		"runtime":       {Scope: "default"},
+	}
+	for path, w := range want {
+		if got[path] != w {
+			t.Errorf("origin of %s = %+v, want %+v", path, got[path], w)
+		}
+	}
+	if e.Profile.Worktree.Base != "origin/main" {
+		t.Errorf("effective worktree.base = %q, want default filled in", e.Profile.Worktree.Base)
+	}
+}
+
+func TestExplain_BuiltinOverriddenByFile(t *testing.T) {
+	cfg, _, repoPath := loadExplainFixture(t)
+
+	e, err := cfg.Explain("claude")
+	if err != nil {
+		t.Fatalf("Explain() error: %v", err)
+	}
+	for _, f := range e.Fields {
+		switch f.Path {
+		case "environment":
+			if f.Origin != (Origin{Layer: LayerBuiltin, Scope: "profile"}) {
+				t.Errorf("environment origin = %+v, want builtin profile", f.Origin)
+			}
+		case "launch":
+			if f.Origin.Layer != LayerRepo || f.Origin.File != repoPath || f.Value != "shell" {
+				t.Errorf("launch = %v from %+v, want shell from repo", f.Value, f.Origin)
+			}
+		}
+	}
+}
+
+func TestExplain_YAMLAndJSON(t *testing.T) {
+	cfg, _, repoPath := loadExplainFixture(t)
+	e, err := cfg.Explain("fast")
+	if err != nil {
+		t.Fatalf("Explain() error: %v", err)
+	}
+
+	y, err := e.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error: %v", err)
+	}
+	for _, line := range []string{
+		"extends: base # repo profile (" + repoPath + ")",
+		"  base: origin/main # default",
+		`  B: "2" # repo profile (` + repoPath + ")",
+	} {
+		if !strings.Contains(string(y), line) {
+			t.Errorf("YAML missing line %q:\n%s", line, y)
+		}
+	}
+
+	j, err := e.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error: %v", err)
+	}
+	var out struct {
+		Profile   string         `json:"profile"`
+		Effective map[string]any `json:"effective"`
+		Fields    []struct {
+			Path  string `json:"path"`
+			Value any    `json:"value"`
+			Layer string `json:"layer"`
+			Scope string `json:"scope"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(j, &out); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, j)
+	}
+	if out.Profile != "fast" || out.Effective["environment"] != "docker" {
+		t.Errorf("JSON = %s", j)
+	}
+	if len(out.Fields) != len(e.Fields) {
+		t.Errorf("JSON has %d fields, want %d", len(out.Fields), len(e.Fields))
+	}
+}
+
+func TestExplain_Errors(t *testing.T) {
+	cfg, _, _ := loadExplainFixture(t)
+	if _, err := cfg.Explain("nope"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+
+	manual := &Config{Profiles: map[string]Profile{"p": {Environment: EnvironmentHost, Launch: LaunchShell}}}
+	if _, err := manual.Explain("p"); err == nil {
+		t.Error("expected error for a config that was not loaded from layers")
+	}
+}
@@ -1,16 +1,25 @@
 package profile
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-const configFileName = ".agent-workspace.yml"
+const (
+	configFileName      = ".agent-workspace.yml"
+	localConfigFileName = ".agent-workspace.local.yml"
+	userConfigFileName  = "config.yml"
+)
 
 // builtinConfig is used when no config file is found.
 var builtinConfig = Config{
@@ -29,63 +38,149 @@ var builtinConfig = Config{
 	},
 }
 
-// Load finds and loads the config file.
-// It looks for .agent-workspace.yml at the git repository root.
-// If no config file is found, it returns the built-in default config.
-func Load() (*Config, error) {
-	repoRoot, err := findGitRoot()
+// Load finds and loads the layered config:
+//
+//  1. the built-in default
+//  2. $XDG_CONFIG_HOME/agent-workspace/config.yml (personal defaults and profiles)
+//  3. .agent-workspace.yml at the git repository root
+//  4. .agent-workspace.local.yml at the git repository root (per-developer overrides)
+//
+// Each layer is merged on top of the previous ones with MergeConfig. Missing
+// files are skipped; outside a git repository only the first two layers apply.
+func Load(ctx context.Context) (*Config, error) {
+	repoRoot, err := findGitRoot(ctx)
 	if err != nil {
-		// Not in a git repo — use built-in default
-		cfg := builtinConfig
-		cfg.Source = ConfigSource{IsBuiltin: true}
-		return &cfg, nil
+		repoRoot = ""
 	}
+	return LoadLayers(ConfigFiles(repoRoot))
+}
 
-	configPath := filepath.Join(repoRoot, configFileName)
-	return LoadFile(configPath)
+// ConfigFiles returns the candidate config files for repoRoot, lowest
+// precedence first. The files need not exist. An empty repoRoot yields only
+// the user-level file.
+func ConfigFiles(repoRoot string) []SourceFile {
+	var files []SourceFile
+	if path := UserConfigPath(); path != "" {
+		files = append(files, SourceFile{Layer: LayerUser, Path: path})
+	}
+	if repoRoot != "" {
+		files = append(files,
+			SourceFile{Layer: LayerRepo, Path: filepath.Join(repoRoot, configFileName)},
+			SourceFile{Layer: LayerLocal, Path: filepath.Join(repoRoot, localConfigFileName)},
+		)
+	}
+	return files
 }
 
-// LoadFile loads a config from the given file path.
-// If the file does not exist, it returns the built-in default config.
-func LoadFile(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			cfg := builtinConfig
-			cfg.Source = ConfigSource{IsBuiltin: true}
-			return &cfg, nil
+// UserConfigPath returns the path of the user-level config file:
+// $XDG_CONFIG_HOME/agent-workspace/config.yml, falling back to
+// ~/.config/agent-workspace/config.yml. It returns "" if neither
+// XDG_CONFIG_HOME nor the home directory is known.
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
 		}
-		return nil, fmt.Errorf("reading config file: %w", err)
+		dir = filepath.Join(home, ".config")
 	}
+	return filepath.Join(dir, "agent-workspace", userConfigFileName)
+}
 
-	userCfg, err := Parse(data)
-	if err != nil {
-		return nil, err
+// LoadFile loads a config from the given file path on top of the built-in
+// default. If the file does not exist, it returns the built-in default config.
+func LoadFile(path string) (*Config, error) {
+	return LoadLayers([]SourceFile{{Layer: LayerRepo, Path: path}})
+}
+
+// LoadLayers merges files on top of the built-in default, in order, and
+// applies top-level defaults. Missing files are skipped.
+func LoadLayers(files []SourceFile) (*Config, error) {
+	merged := builtinConfig
+	src := ConfigSource{
+		ProfileLayers: make(map[string][]Layer),
+		layers:        []parsedLayer{{File: SourceFile{Layer: LayerBuiltin}, Config: builtinConfig}},
 	}
+	for name := range builtinConfig.Profiles {
+		src.ProfileLayers[name] = []Layer{LayerBuiltin}
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
 
-	merged := MergeConfig(builtinConfig, *userCfg)
-	merged.Source = ConfigSource{FilePath: path}
+		layerCfg, node, err := parse(f.Path, data)
+		if err != nil {
+			return nil, err
+		}
+
+		merged = MergeConfig(merged, *layerCfg)
+		src.Files = append(src.Files, f)
+		src.layers = append(src.layers, parsedLayer{File: f, Config: *layerCfg, Node: node})
+		for name := range layerCfg.Profiles {
+			src.ProfileLayers[name] = append(src.ProfileLayers[name], f.Layer)
+		}
+	}
+
+	src.IsBuiltin = len(src.Files) == 0
+	merged.Source = src
 	applied := ApplyTopLevel(merged)
 	return &applied, nil
 }
 
-// Parse parses YAML bytes into a Config.
+// Parse parses YAML bytes into a Config. Unknown keys are rejected, with a
+// suggestion when they look like a misspelled known key.
 func Parse(data []byte) (*Config, error) {
+	cfg, _, err := parse("", data)
+	return cfg, err
+}
+
+// parse is Parse that also returns the document node, for locating
+// validation errors later. Errors are prefixed with file:line:col; file may be
+// empty.
+func parse(file string, data []byte) (*Config, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		msg := "parsing config: " + err.Error()
+		if file != "" {
+			msg = file + ": " + msg
+		}
+		return nil, nil, errors.New(msg)
+	}
+
+	if errs := checkKnownKeys(file, &root, reflect.TypeOf(Config{})); len(errs) == 1 {
+		return nil, nil, errors.New(errs[0])
+	} else if len(errs) > 1 {
+		return nil, nil, fmt.Errorf("config errors:\n  %s", strings.Join(errs, "\n  "))
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config: %w", err)
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		msg := "parsing config: " + err.Error()
+		if file != "" {
+			msg = file + ": " + msg
+		}
+		return nil, nil, errors.New(msg)
 	}
 
 	if cfg.Profiles == nil {
 		cfg.Profiles = make(map[string]Profile)
 	}
 
-	return &cfg, nil
+	return &cfg, &root, nil
 }
 
 // findGitRoot returns the top-level directory of the current git repository.
-var findGitRoot = func() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+var findGitRoot = func(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("not in a git repository")
@@ -1,9 +1,12 @@
 package profile
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -301,15 +304,143 @@ profiles:
 	}
 }
 
+func TestParse_WorktreeCopy(t *testing.T) {
+	yaml := `
+profiles:
+  test:
+    environment: host
+    launch: shell
+    worktree:
+      copy:
+        - .env
+        - path: node_modules
+          link: true
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	got := cfg.Profiles["test"].Worktree.Copy
+	want := []CopyEntry{{Path: ".env"}, {Path: "node_modules", Link: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Copy = %+v, want %+v", got, want)
+	}
+}
+
+func TestParse_DockerClient(t *testing.T) {
+	yaml := `
+profiles:
+  test:
+    environment: docker
+    launch: claude
+    docker:
+      client: api
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	p := cfg.Profiles["test"]
+	if p.Docker == nil || p.Docker.Client != DockerClientAPI {
+		t.Errorf("Docker = %+v, want client api", p.Docker)
+	}
+	if (*DockerConfig)(nil).EffectiveClient() != DockerClientCLI {
+		t.Error("nil DockerConfig should default to the cli client")
+	}
+}
+
+func TestParse_DockerBuild(t *testing.T) {
+	yaml := `
+profiles:
+  test:
+    environment: docker
+    launch: claude
+    dockerfile: docker/Dockerfile
+    docker:
+      build:
+        context: .
+        args:
+          GO_VERSION: "1.23"
+        target: dev
+        platform: linux/amd64
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	want := &DockerBuild{Context: ".", Args: map[string]string{"GO_VERSION": "1.23"}, Target: "dev", Platform: "linux/amd64"}
+	if got := cfg.Profiles["test"].Docker.Build; !reflect.DeepEqual(got, want) {
+		t.Errorf("Docker.Build = %+v, want %+v", got, want)
+	}
+}
+
+func TestParse_DockerFeatures(t *testing.T) {
+	yaml := `
+profiles:
+  test:
+    environment: docker
+    launch: claude
+    docker:
+      features:
+        go: 1.20
+        rust: stable
+        python: 3.12
+        apt: [ripgrep, jq]
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	// Unquoted versions are YAML numbers, but keep their text.
+	want := &DockerFeatures{Go: "1.20", Rust: "stable", Python: "3.12", Apt: []string{"ripgrep", "jq"}}
+	if got := cfg.Profiles["test"].Docker.Features; !reflect.DeepEqual(got, want) {
+		t.Errorf("Docker.Features = %+v, want %+v", got, want)
+	}
+}
+
+func TestParse_Runtime(t *testing.T) {
+	yaml := `
+runtime: auto
+profiles:
+  test:
+    environment: docker
+    launch: claude
+  rootless:
+    environment: docker
+    launch: claude
+    runtime: podman
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	*cfg = ApplyTopLevel(*cfg)
+
+	if got := cfg.Profiles["test"].EffectiveRuntime(); got != RuntimeAuto {
+		t.Errorf("test runtime = %q, want %q (from top level)", got, RuntimeAuto)
+	}
+	if got := cfg.Profiles["rootless"].EffectiveRuntime(); got != RuntimePodman {
+		t.Errorf("rootless runtime = %q, want %q", got, RuntimePodman)
+	}
+	if got := (Profile{}).EffectiveRuntime(); got != RuntimeDocker {
+		t.Errorf("default runtime = %q, want %q", got, RuntimeDocker)
+	}
+}
+
 func TestLoad_NoGitRepo(t *testing.T) {
 	// Override findGitRoot to simulate not being in a git repo
 	orig := findGitRoot
-	findGitRoot = func() (string, error) {
+	findGitRoot = func(context.Context) (string, error) {
 		return "", fmt.Errorf("not in a git repository")
 	}
 	defer func() { findGitRoot = orig }()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
 
-	cfg, err := Load()
+	cfg, err := Load(context.Background())
 	if err != nil {
 		t.Fatalf("Load() should not error when not in git repo, got: %v", err)
 	}
@@ -318,3 +449,262 @@ func TestLoad_NoGitRepo(t *testing.T) {
 		t.Errorf("Default = %q, want %q", cfg.Default, "worktree-zellij")
 	}
 }
+
+func TestLoad_Layers(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	orig := findGitRoot
+	findGitRoot = func(context.Context) (string, error) { return repoRoot, nil }
+	defer func() { findGitRoot = orig }()
+
+	userPath := filepath.Join(configHome, "agent-workspace", "config.yml")
+	if err := os.MkdirAll(filepath.Dir(userPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(userPath, `
+default: mine
+worktree:
+  dir: ~/wt
+profiles:
+  mine:
+    environment: host
+    launch: shell
+  shared:
+    environment: host
+    launch: shell
+`)
+	write(filepath.Join(repoRoot, ".agent-workspace.yml"), `
+default: shared
+profiles:
+  shared:
+    environment: docker
+    launch: claude
+    env:
+      A: "1"
+`)
+	write(filepath.Join(repoRoot, ".agent-workspace.local.yml"), `
+profiles:
+  shared:
+    env:
+      B: "2"
+  claude:
+    runtime: podman
+`)
+
+	cfg, err := Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if cfg.Default != "shared" {
+		t.Errorf("Default = %q, want %q (repo overrides user)", cfg.Default, "shared")
+	}
+	shared := cfg.Profiles["shared"]
+	if shared.Environment != EnvironmentDocker || shared.Launch != LaunchClaude {
+		t.Errorf("shared = %s + %s, want docker + claude from repo layer", shared.Environment, shared.Launch)
+	}
+	if shared.Env["A"] != "1" || shared.Env["B"] != "2" {
+		t.Errorf("shared.Env = %v, want A from repo and B from local", shared.Env)
+	}
+	if shared.Worktree == nil || shared.Worktree.Dir != "~/wt" {
+		t.Errorf("shared.Worktree = %+v, want user top-level dir", shared.Worktree)
+	}
+	if cfg.Profiles["claude"].Runtime != RuntimePodman || cfg.Profiles["claude"].Environment != EnvironmentDocker {
+		t.Errorf("claude = %+v, want builtin profile with local runtime override", cfg.Profiles["claude"])
+	}
+
+	if cfg.Source.IsBuiltin {
+		t.Error("IsBuiltin should be false when files contributed")
+	}
+	wantFiles := []SourceFile{
+		{LayerUser, userPath},
+		{LayerRepo, filepath.Join(repoRoot, ".agent-workspace.yml")},
+		{LayerLocal, filepath.Join(repoRoot, ".agent-workspace.local.yml")},
+	}
+	if fmt.Sprint(cfg.Source.Files) != fmt.Sprint(wantFiles) {
+		t.Errorf("Source.Files = %v, want %v", cfg.Source.Files, wantFiles)
+	}
+
+	layers := map[string]string{
+		"mine":   "[user]",
+		"shared": "[user repo local]",
+		"claude": "[builtin local]",
+	}
+	for name, want := range layers {
+		if got := fmt.Sprint(cfg.Source.ProfileLayers[name]); got != want {
+			t.Errorf("ProfileLayers[%q] = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestLoad_LayerParseErrorNamesFile(t *testing.T) {
+	repoRoot := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	orig := findGitRoot
+	findGitRoot = func(context.Context) (string, error) { return repoRoot, nil }
+	defer func() { findGitRoot = orig }()
+
+	localPath := filepath.Join(repoRoot, ".agent-workspace.local.yml")
+	if err := os.WriteFile(localPath, []byte("profiles: [oops"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(context.Background())
+	if err == nil || !strings.Contains(err.Error(), localPath) {
+		t.Errorf("Load() error = %v, want it to name %s", err, localPath)
+	}
+}
+
+func TestConfigFiles_OutsideRepo(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/cfg")
+	files := ConfigFiles("")
+	if len(files) != 1 || files[0].Layer != LayerUser || files[0].Path != "/cfg/agent-workspace/config.yml" {
+		t.Errorf("ConfigFiles(\"\") = %v, want only the user config", files)
+	}
+}
+
+func TestParse_UnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr []string
+	}{
+		{
+			name: "misspelled profile key",
+			yaml: `
+profiles:
+  dev:
+    enviroment: docker
+    launch: claude
+`,
+			wantErr: []string{`line 4, column 5: unknown key "enviroment" in profiles.dev; did you mean "environment"?`},
+		},
+		{
+			name: "nested worktree key",
+			yaml: `
+profiles:
+  dev:
+    environment: host
+    launch: shell
+    worktree:
+      on_create: make setup
+`,
+			wantErr: []string{`line 7, column 7: unknown key "on_create" in profiles.dev.worktree; did you mean "on-create"?`},
+		},
+		{
+			name: "top-level key without suggestion",
+			yaml: `
+colour: blue
+profiles: {}
+`,
+			wantErr: []string{`line 2, column 1: unknown key "colour"`},
+		},
+		{
+			name: "several errors are all reported",
+			yaml: `
+defualt: dev
+profiles:
+  dev:
+    lanch: shell
+`,
+			wantErr: []string{
+				"config errors:",
+				`unknown key "defualt"; did you mean "default"?`,
+				`unknown key "lanch" in profiles.dev; did you mean "launch"?`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil {
+				t.Fatal("Parse() expected error, got nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Parse() error = %q, want containing %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_MergeKeys(t *testing.T) {
+	yaml := `
+profiles:
+  a: &a {environment: host, launch: claude}
+  wt: &wt {environment: host, launch: shell, worktree: {base: main}}
+  b: {<<: *a, launch: shell}
+  c:
+    <<: [*wt, *a]
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if b := cfg.Profiles["b"]; b.Environment != EnvironmentHost || b.Launch != LaunchShell {
+		t.Errorf("profiles.b = %+v, want environment from a and its own launch", b)
+	}
+	if c := cfg.Profiles["c"]; c.Worktree == nil || c.Worktree.Base != "main" || c.Launch != LaunchShell {
+		t.Errorf("profiles.c = %+v, want the fields of wt", c)
+	}
+}
+
+func TestParse_UnknownKeyInAnchor(t *testing.T) {
+	yaml := `
+profiles:
+  a: &a
+    environment: host
+    lanch: claude
+  b:
+    <<: *a
+    worktree: &wt {on_create: make}
+  c: {<<: *a, worktree: *wt}
+`
+	_, err := Parse([]byte(yaml))
+	if err == nil {
+		t.Fatal("Parse() expected error, got nil")
+	}
+	for _, want := range []string{
+		`line 5, column 5: unknown key "lanch" in profiles.a; did you mean "launch"?`,
+		`line 8, column 20: unknown key "on_create" in profiles.b.worktree; did you mean "on-create"?`,
+	} {
+		if n := strings.Count(err.Error(), want); n != 1 {
+			t.Errorf("Parse() error = %q, want %q exactly once, got %d", err.Error(), want, n)
+		}
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	cfg, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse() error for empty input: %v", err)
+	}
+	if cfg.Profiles == nil {
+		t.Error("Profiles should not be nil")
+	}
+}
+
+func TestLoadFile_UnknownKeyNamesPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".agent-workspace.yml")
+	content := "profiles:\n  dev:\n    environment: host\n    launch: shell\n    dockerfil: Dockerfile\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFile(path)
+	want := path + `:5:5: unknown key "dockerfil" in profiles.dev; did you mean "dockerfile"?`
+	if err == nil || err.Error() != want {
+		t.Errorf("LoadFile() error = %v, want %q", err, want)
+	}
+}
@@ -1,11 +1,19 @@
 package profile
 
+import (
+	"fmt"
+	"strings"
+)
+
 // MergeProfile merges override into base.
 // Non-zero values in override take precedence over base.
-// Sub-structs (Worktree, Zellij) are merged field-by-field rather than replaced wholesale.
+// Sub-structs (Worktree, Zellij, Docker) are merged field-by-field rather than replaced wholesale.
 func MergeProfile(base, override Profile) Profile {
 	merged := base
 
+	if override.Extends != "" {
+		merged.Extends = override.Extends
+	}
 	if override.Environment != "" {
 		merged.Environment = override.Environment
 	}
@@ -27,6 +35,13 @@ func MergeProfile(base, override Profile) Profile {
 	if override.Dockerfile != "" {
 		merged.Dockerfile = override.Dockerfile
 	}
+	if override.Image != "" {
+		merged.Image = override.Image
+	}
+	merged.Docker = mergeDocker(merged.Docker, override.Docker)
+	if override.Runtime != "" {
+		merged.Runtime = override.Runtime
+	}
 
 	return merged
 }
@@ -52,6 +67,27 @@ func mergeWorktree(base, override *WorktreeConfig) *WorktreeConfig {
 	if override.OnEnd != "" {
 		merged.OnEnd = override.OnEnd
 	}
+	if override.Reuse != "" {
+		merged.Reuse = override.Reuse
+	}
+	if override.Branch != "" {
+		merged.Branch = override.Branch
+	}
+	if override.Copy != nil {
+		merged.Copy = override.Copy
+	}
+	if override.Sparse != nil {
+		merged.Sparse = override.Sparse
+	}
+	if override.Submodules != "" {
+		merged.Submodules = override.Submodules
+	}
+	if override.Fetch != "" {
+		merged.Fetch = override.Fetch
+	}
+	if override.FetchInterval != "" {
+		merged.FetchInterval = override.FetchInterval
+	}
 	return &merged
 }
 
@@ -70,6 +106,81 @@ func mergeZellij(base, override *ZellijConfig) *ZellijConfig {
 	return &merged
 }
 
+func mergeDocker(base, override *DockerConfig) *DockerConfig {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		v := *override
+		return &v
+	}
+	merged := *base
+	if override.Client != "" {
+		merged.Client = override.Client
+	}
+	merged.Build = mergeDockerBuild(merged.Build, override.Build)
+	if override.Pull != "" {
+		merged.Pull = override.Pull
+	}
+	merged.Features = mergeDockerFeatures(merged.Features, override.Features)
+	return &merged
+}
+
+func mergeDockerBuild(base, override *DockerBuild) *DockerBuild {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		v := *override
+		return &v
+	}
+	merged := *base
+	if override.Context != "" {
+		merged.Context = override.Context
+	}
+	if override.Args != nil {
+		args := make(map[string]string, len(merged.Args)+len(override.Args))
+		for k, v := range merged.Args {
+			args[k] = v
+		}
+		for k, v := range override.Args {
+			args[k] = v
+		}
+		merged.Args = args
+	}
+	if override.Target != "" {
+		merged.Target = override.Target
+	}
+	if override.Platform != "" {
+		merged.Platform = override.Platform
+	}
+	return &merged
+}
+
+func mergeDockerFeatures(base, override *DockerFeatures) *DockerFeatures {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		v := *override
+		return &v
+	}
+	merged := *base
+	if override.Go != "" {
+		merged.Go = override.Go
+	}
+	if override.Rust != "" {
+		merged.Rust = override.Rust
+	}
+	if override.Python != "" {
+		merged.Python = override.Python
+	}
+	if override.Apt != nil {
+		merged.Apt = override.Apt
+	}
+	return &merged
+}
+
 // MergeConfig merges a user config on top of the builtin config.
 //   - Builtin-only profiles are preserved as-is.
 //   - User-only profiles are added as-is.
@@ -106,8 +217,45 @@ func MergeConfig(builtin, user Config) Config {
 	return merged
 }
 
+// ResolveExtends returns the named profile with its `extends` chain applied:
+// the parent is resolved first (recursively) and the profile is merged on top
+// of it with MergeProfile. It fails if a parent does not exist or the chain
+// loops back on itself.
+func ResolveExtends(profiles map[string]Profile, name string) (Profile, error) {
+	return resolveExtends(profiles, name, nil)
+}
+
+func resolveExtends(profiles map[string]Profile, name string, chain []string) (Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found", name)
+	}
+	if p.Extends == "" {
+		return p, nil
+	}
+
+	chain = append(chain, name)
+	for _, seen := range chain {
+		if seen == p.Extends {
+			return Profile{}, fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), p.Extends)
+		}
+	}
+	if _, ok := profiles[p.Extends]; !ok {
+		return Profile{}, fmt.Errorf("extends unknown profile %q", p.Extends)
+	}
+
+	parent, err := resolveExtends(profiles, p.Extends, chain)
+	if err != nil {
+		return Profile{}, err
+	}
+	return MergeProfile(parent, p), nil
+}
+
 // ApplyTopLevel returns a new Config in which each profile is the result of
 // merging the top-level Profile defaults with the per-profile overrides.
+// Each profile's `extends` chain is resolved first, so a child inherits from
+// its parent before falling back to the top-level defaults. Profiles whose
+// chain cannot be resolved are left unresolved; ValidateConfig reports them.
 // The returned Config's top-level Profile is left as-is (it's redundant once
 // applied, but harmless and useful for round-tripping).
 func ApplyTopLevel(cfg Config) Config {
@@ -118,6 +266,9 @@ func ApplyTopLevel(cfg Config) Config {
 		Source:   cfg.Source,
 	}
 	for name, p := range cfg.Profiles {
+		if resolved, err := ResolveExtends(cfg.Profiles, name); err == nil {
+			p = resolved
+		}
 		out.Profiles[name] = MergeProfile(cfg.Profile, p)
 	}
 	return out
@@ -69,7 +69,7 @@ func TestMergeProfile_OverrideWorktree(t *testing.T) {
 		Zellij:      &ZellijConfig{Layout: "default"},
 	}
 	override := Profile{
-		Worktree: &WorktreeConfig{Base: "origin/develop", OnCreate: "./setup.sh"},
+		Worktree: &WorktreeConfig{Base: "origin/develop", OnCreate: "./setup.sh", Reuse: WorktreeReuseLatest},
 	}
 
 	merged := MergeProfile(base, override)
@@ -83,6 +83,9 @@ func TestMergeProfile_OverrideWorktree(t *testing.T) {
 	if merged.Worktree.OnCreate != "./setup.sh" {
 		t.Errorf("Worktree.OnCreate = %q, want %q", merged.Worktree.OnCreate, "./setup.sh")
 	}
+	if merged.Worktree.Reuse != WorktreeReuseLatest {
+		t.Errorf("Worktree.Reuse = %q, want %q", merged.Worktree.Reuse, WorktreeReuseLatest)
+	}
 }
 
 func TestMergeProfile_PreserveWorktreeFromBase(t *testing.T) {
@@ -362,6 +365,162 @@ func TestMergeProfile_EmptyDockerfileOverridePreservesBase(t *testing.T) {
 	}
 }
 
+func TestMergeProfile_DockerConfig(t *testing.T) {
+	base := Profile{
+		Environment: EnvironmentDocker,
+		Launch:      LaunchClaude,
+		Docker:      &DockerConfig{Client: DockerClientAPI},
+	}
+
+	merged := MergeProfile(base, Profile{})
+	if merged.Docker.EffectiveClient() != DockerClientAPI {
+		t.Errorf("Docker.Client = %q, want %q (should be preserved from base)", merged.Docker.Client, DockerClientAPI)
+	}
+
+	merged = MergeProfile(base, Profile{Docker: &DockerConfig{Client: DockerClientCLI}})
+	if merged.Docker.Client != DockerClientCLI {
+		t.Errorf("Docker.Client = %q, want %q", merged.Docker.Client, DockerClientCLI)
+	}
+	if base.Docker.Client != DockerClientAPI {
+		t.Error("base.Docker should not have been mutated")
+	}
+}
+
+func TestMergeProfile_DockerBuild(t *testing.T) {
+	base := Profile{Docker: &DockerConfig{Build: &DockerBuild{
+		Context: "docker",
+		Args:    map[string]string{"A": "1", "B": "2"},
+	}}}
+	override := Profile{Docker: &DockerConfig{Build: &DockerBuild{
+		Args:     map[string]string{"B": "3"},
+		Platform: "linux/amd64",
+	}}}
+
+	merged := MergeProfile(base, override)
+	b := merged.Docker.Build
+	if b.Context != "docker" || b.Platform != "linux/amd64" {
+		t.Errorf("Build = %+v, want context from base and platform from override", b)
+	}
+	if b.Args["A"] != "1" || b.Args["B"] != "3" {
+		t.Errorf("Build.Args = %v, want A=1 B=3", b.Args)
+	}
+	if base.Docker.Build.Args["B"] != "2" {
+		t.Error("base.Docker.Build.Args should not have been mutated")
+	}
+}
+
+func TestMergeProfile_DockerFeatures(t *testing.T) {
+	base := Profile{Docker: &DockerConfig{Features: &DockerFeatures{Go: "1.24", Apt: []string{"jq"}}}}
+	override := Profile{Docker: &DockerConfig{Features: &DockerFeatures{Rust: "stable", Apt: []string{"ripgrep"}}}}
+
+	f := MergeProfile(base, override).Docker.Features
+	if f.Go != "1.24" || f.Rust != "stable" || len(f.Apt) != 1 || f.Apt[0] != "ripgrep" {
+		t.Errorf("Features = %+v, want go from base, rust and apt from override", f)
+	}
+	if base.Docker.Features.Rust != "" {
+		t.Error("base.Docker.Features should not have been mutated")
+	}
+}
+
+func TestResolveExtends(t *testing.T) {
+	profiles := map[string]Profile{
+		"docker-claude": {
+			Environment: EnvironmentDocker,
+			Launch:      LaunchClaude,
+			Env:         map[string]string{"A": "1"},
+		},
+		"docker-claude-fast": {
+			Extends: "docker-claude",
+			Env:     map[string]string{"B": "2"},
+		},
+		"docker-claude-fast-wt": {
+			Extends:  "docker-claude-fast",
+			Worktree: &WorktreeConfig{Base: "origin/develop"},
+			Launch:   LaunchZellij,
+		},
+	}
+
+	got, err := ResolveExtends(profiles, "docker-claude-fast-wt")
+	if err != nil {
+		t.Fatalf("ResolveExtends() error: %v", err)
+	}
+	if got.Environment != EnvironmentDocker {
+		t.Errorf("Environment = %q, want %q (from grandparent)", got.Environment, EnvironmentDocker)
+	}
+	if got.Launch != LaunchZellij {
+		t.Errorf("Launch = %q, want %q (child override)", got.Launch, LaunchZellij)
+	}
+	if got.Env["A"] != "1" || got.Env["B"] != "2" {
+		t.Errorf("Env = %v, want A and B merged down the chain", got.Env)
+	}
+	if got.Worktree == nil || got.Worktree.Base != "origin/develop" {
+		t.Errorf("Worktree = %+v", got.Worktree)
+	}
+	if got.Extends != "docker-claude-fast" {
+		t.Errorf("Extends = %q, want the direct parent", got.Extends)
+	}
+	if len(profiles["docker-claude"].Env) != 1 {
+		t.Error("parent profile should not have been mutated")
+	}
+}
+
+func TestResolveExtends_Errors(t *testing.T) {
+	profiles := map[string]Profile{
+		"self":     {Extends: "self"},
+		"a":        {Extends: "b"},
+		"b":        {Extends: "c"},
+		"c":        {Extends: "a"},
+		"orphan":   {Extends: "missing"},
+		"grandkid": {Extends: "orphan"},
+	}
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"self", "extends cycle: self -> self"},
+		{"a", "extends cycle: a -> b -> c -> a"},
+		{"orphan", `extends unknown profile "missing"`},
+		{"grandkid", `extends unknown profile "missing"`},
+		{"nope", `profile "nope" not found`},
+	}
+	for _, tt := range tests {
+		_, err := ResolveExtends(profiles, tt.name)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("ResolveExtends(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyTopLevel_ExtendsBeforeTopLevel(t *testing.T) {
+	cfg := Config{
+		Profile: Profile{
+			Environment: EnvironmentHost,
+			Worktree:    &WorktreeConfig{Base: "origin/main"},
+		},
+		Profiles: map[string]Profile{
+			"parent": {
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+			},
+			"child": {
+				Extends:  "parent",
+				Worktree: &WorktreeConfig{Dir: "/tmp/wt"},
+			},
+		},
+	}
+
+	child := ApplyTopLevel(cfg).Profiles["child"]
+	if child.Environment != EnvironmentDocker {
+		t.Errorf("Environment = %q, want %q (parent beats top-level)", child.Environment, EnvironmentDocker)
+	}
+	if child.Launch != LaunchClaude {
+		t.Errorf("Launch = %q, want %q (from parent)", child.Launch, LaunchClaude)
+	}
+	if child.Worktree == nil || child.Worktree.Base != "origin/main" || child.Worktree.Dir != "/tmp/wt" {
+		t.Errorf("Worktree = %+v, want top-level base with child dir", child.Worktree)
+	}
+}
+
 func TestApplyTopLevel_PropagatesToProfiles(t *testing.T) {
 	cfg := Config{
 		Profile: Profile{
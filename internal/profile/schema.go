@@ -0,0 +1,232 @@
+package profile
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaFile is where the generated schema is checked in, relative to the
+// repository root, for editors to reference. It is regenerated with
+// `aw schema > docs/agent-workspace.schema.json`.
+const SchemaFile = "docs/agent-workspace.schema.json"
+
+// jsonSchema is the subset of JSON Schema (draft-07) that Schema emits.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Const                string                 `json:"const,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AllOf                []*jsonSchema          `json:"allOf,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Not                  *jsonSchema            `json:"not,omitempty"`
+	If                   *jsonSchema            `json:"if,omitempty"`
+	Then                 *jsonSchema            `json:"then,omitempty"`
+	Definitions          map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+// schemaEnums lists the allowed values of each enum type. Keep these in step
+// with the constants in types.go; TestSchema_EnumsPassValidate checks that
+// every listed value is accepted by Validate.
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(Environment("")):        {string(EnvironmentHost), string(EnvironmentDocker)},
+	reflect.TypeOf(LaunchMode("")):         {string(LaunchShell), string(LaunchClaude), string(LaunchZellij)},
+	reflect.TypeOf(DockerClient("")):       {string(DockerClientCLI), string(DockerClientAPI)},
+	reflect.TypeOf(Runtime("")):            {string(RuntimeDocker), string(RuntimePodman), string(RuntimeAuto)},
+	reflect.TypeOf(WorktreeReuse("")):      {string(WorktreeReuseNever), string(WorktreeReusePrompt), string(WorktreeReuseLatest)},
+	reflect.TypeOf(WorktreeSubmodules("")): {string(WorktreeSubmodulesNone), string(WorktreeSubmodulesRecursive)},
+	reflect.TypeOf(WorktreeFetch("")):      {string(WorktreeFetchAlways), string(WorktreeFetchIfStale), string(WorktreeFetchNever)},
+	reflect.TypeOf(DockerPull("")):         {string(DockerPullAlways), string(DockerPullMissing), string(DockerPullNever)},
+}
+
+// schemaDefs names the struct types emitted under "definitions".
+var schemaDefs = map[reflect.Type]string{
+	reflect.TypeOf(Profile{}):        "profile",
+	reflect.TypeOf(WorktreeConfig{}): "worktree",
+	reflect.TypeOf(ZellijConfig{}):   "zellij",
+	reflect.TypeOf(DockerConfig{}):   "docker",
+	reflect.TypeOf(CopyEntry{}):      "copy",
+	reflect.TypeOf(DockerBuild{}):    "build",
+	reflect.TypeOf(DockerFeatures{}): "features",
+}
+
+// schemaDescriptions documents each field, keyed by definition and YAML key.
+var schemaDescriptions = map[string]string{
+	"config.default":          "Profile used when aw is run without a profile name.",
+	"config.profiles":         "Named profiles. Fields set at the top level are defaults for every profile.",
+	"profile.extends":         "Name of a profile to inherit fields from.",
+	"profile.worktree":        "Create a git worktree for the session.",
+	"profile.environment":     "Where the main process runs. Required, either here, in a parent profile, or at the top level.",
+	"profile.launch":          "What to launch. Required, either here, in a parent profile, or at the top level.",
+	"profile.zellij":          "Zellij session settings. Only valid with launch: zellij.",
+	"profile.env":             "Extra environment variables passed into the container.",
+	"profile.dockerfile":      "Custom Dockerfile path, relative to the repository root. Only valid with environment: docker.",
+	"profile.image":           "Prebuilt image to run instead of building one, e.g. registry.example.com/agents/claude:1.4. It must keep aw's entrypoint contract. Not valid with dockerfile. Only valid with environment: docker.",
+	"profile.docker":          "Container engine settings. Only valid with environment: docker.",
+	"profile.runtime":         "Container runtime. Only valid with environment: docker. Default: docker.",
+	"worktree.base":           "Ref the new branch starts from. Default: origin/main.",
+	"worktree.dir":            "Directory to create worktrees in. Default: <repoRoot>/worktrees.",
+	"worktree.on-create":      "Shell command run in the worktree after it is created.",
+	"worktree.on-end":         "Shell command run in the worktree after the launched process exits.",
+	"worktree.branch":         "Template for new branch names, with {{.User}}, {{.Date}}, {{.Words}}, {{.Issue}}, {{.Title}}, {{.PR}} and {{.Profile}}. Default: {{.Words}} (three random words), issue-{{.Issue}}-{{.Title}} with --issue, pr-{{.PR}} with --pr.",
+	"worktree.reuse":          "Resume an existing worktree of this profile instead of creating one: never (default), prompt to pick one, or latest. A resumed worktree skips on-create.",
+	"worktree.copy":           "Untracked files to bring into a new worktree before on-create: globs relative to the repository root, or {path, link} entries. Files already in the worktree are left alone.",
+	"worktree.sparse":         "Directories to check out, relative to the repository root (cone-mode sparse checkout). Files at the top level are always checked out. Default: everything.",
+	"worktree.submodules":     "Whether to check out submodules in a new worktree: none (default) or recursive.",
+	"worktree.fetch":          "When to fetch the base ref from its remote before creating a worktree: always (default), if-stale (when the last fetch is older than fetch-interval) or never. If the fetch fails, the local ref is used with a warning.",
+	"worktree.fetch-interval": "With fetch: if-stale, how old the last fetch may be, as a Go duration such as 30m or 2h. Default: 1h.",
+	"copy.path":               "Glob relative to the repository root, e.g. .env or certs/*.pem.",
+	"copy.link":               "Symlink each match instead of copying it. Default: false (copy, as a reflink where the filesystem supports it).",
+	"zellij.layout":           "Zellij layout name.",
+	"docker.client":           "How aw talks to the container engine. Default: cli.",
+	"docker.pull":             "When to pull the image of image: missing (default, only if not present locally), always or never.",
+	"docker.build":            "How the image is built. Changing any of it rebuilds the image.",
+	"docker.features":         "Toolchains layered onto the embedded Dockerfile, which aw renders as extra layers of a generated Dockerfile. Not valid with dockerfile or image.",
+	"features.go":             "Go release replacing the embedded Go 1.23, e.g. 1.24 (the latest 1.24.x at build time) or 1.24.2.",
+	"features.rust":           "Rust toolchain to install with rustup: stable, beta, nightly or a release such as 1.82.",
+	"features.python":         "Python release to install with uv and make the default python3, e.g. 3.12.",
+	"features.apt":            "Extra Debian packages to install, e.g. [ripgrep, jq].",
+	"build.context":           "Build context directory for a custom dockerfile, relative to the repository root. .dockerignore is honored. Default: only the Dockerfile and entrypoint.sh.",
+	"build.args":              "Build args passed to the Dockerfile. Values may use ${VAR} to read the host environment.",
+	"build.target":            "Stage of a multi-stage custom dockerfile to build. Default: the last stage.",
+	"build.platform":          "Platform to build the image for, e.g. linux/amd64. Default: the engine's platform.",
+}
+
+// schemaVersions lists the string fields holding a version, which may be
+// written unquoted, so that YAML reads them as numbers, e.g. go: 1.24.
+var schemaVersions = map[string]bool{
+	"features.go":     true,
+	"features.rust":   true,
+	"features.python": true,
+}
+
+// Schema returns a JSON Schema for .agent-workspace.yml, derived from the
+// Config and Profile types. Required fields are not enforced, since they may
+// come from another layer, a parent profile, or the top level.
+func Schema() ([]byte, error) {
+	defs := make(map[string]*jsonSchema)
+	for t, name := range schemaDefs {
+		defs[name] = structSchema(t, name)
+	}
+	defs["profile"].AllOf = profileRules()
+
+	top := structSchema(reflect.TypeOf(Config{}), "config")
+	delete(top.Properties, "extends") // only valid inside a profile
+	top.Schema = "http://json-schema.org/draft-07/schema#"
+	top.Title = "agent-workspace config"
+	top.Description = "Configuration for aw (.agent-workspace.yml, .agent-workspace.local.yml, or ~/.config/agent-workspace/config.yml)."
+	top.Definitions = defs
+
+	out, err := json.MarshalIndent(top, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// profileRules mirrors the cross-field checks in Validate that can be
+// decided from a single profile.
+func profileRules() []*jsonSchema {
+	notZellij := &jsonSchema{
+		Required:   []string{"launch"},
+		Properties: map[string]*jsonSchema{"launch": {Not: &jsonSchema{Const: string(LaunchZellij)}}},
+	}
+	notDocker := &jsonSchema{
+		Required:   []string{"environment"},
+		Properties: map[string]*jsonSchema{"environment": {Not: &jsonSchema{Const: string(EnvironmentDocker)}}},
+	}
+	return []*jsonSchema{
+		{
+			Description: "zellij config is only valid with launch: zellij",
+			If:          notZellij,
+			Then:        &jsonSchema{Not: &jsonSchema{Required: []string{"zellij"}}},
+		},
+		{
+			Description: "dockerfile, image, docker and runtime are only valid with environment: docker",
+			If:          notDocker,
+			Then: &jsonSchema{Not: &jsonSchema{AnyOf: []*jsonSchema{
+				{Required: []string{"dockerfile"}},
+				{Required: []string{"image"}},
+				{Required: []string{"docker"}},
+				{Required: []string{"runtime"}},
+			}}},
+		},
+		{
+			Description: "image and dockerfile are mutually exclusive",
+			Not:         &jsonSchema{Required: []string{"image", "dockerfile"}},
+		},
+	}
+}
+
+// structSchema describes the YAML fields of struct type t. Inline fields are
+// flattened; fields tagged "-" are skipped.
+func structSchema(t reflect.Type, def string) *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range structSchema(f.Type, schemaDefs[f.Type]).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fs := typeSchema(f.Type)
+		if schemaVersions[def+"."+name] {
+			fs = &jsonSchema{AnyOf: []*jsonSchema{{Type: "string"}, {Type: "number"}}}
+		}
+		fs.Description = schemaDescriptions[def+"."+name]
+		s.Properties[name] = fs
+	}
+	return s
+}
+
+// typeSchema describes a field of type t, referring to definitions for
+// struct types.
+func typeSchema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(CopyEntry{}) {
+		// A copy entry may also be written as just its path.
+		return &jsonSchema{AnyOf: []*jsonSchema{{Type: "string"}, {Ref: "#/definitions/copy"}}}
+	}
+	if name, ok := schemaDefs[t]; ok {
+		return &jsonSchema{Ref: "#/definitions/" + name}
+	}
+	if values, ok := schemaEnums[t]; ok {
+		return &jsonSchema{Type: "string", Enum: values}
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	default:
+		return &jsonSchema{Type: "string"}
+	}
+}
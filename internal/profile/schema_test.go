@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchema_UpToDate(t *testing.T) {
+	got, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", "..", SchemaFile))
+	if err != nil {
+		t.Fatalf("reading checked-in schema: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date with the config types; regenerate it with:\n  go run . schema > %s", SchemaFile, SchemaFile)
+	}
+}
+
+func TestSchema_DescribesEveryField(t *testing.T) {
+	for typ, def := range schemaDefs {
+		for name := range yamlFields(typ) {
+			if schemaDescriptions[def+"."+name] == "" {
+				t.Errorf("schemaDescriptions has no entry for %s.%s", def, name)
+			}
+		}
+	}
+}
+
+func TestSchema_EveryEnumTypeListed(t *testing.T) {
+	pkg := reflect.TypeOf(Profile{}).PkgPath()
+	for typ, def := range schemaDefs {
+		for name, ft := range yamlFields(typ) {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.String && ft.PkgPath() == pkg {
+				if _, ok := schemaEnums[ft]; !ok {
+					t.Errorf("%s.%s has enum type %s with no schemaEnums entry", def, name, ft.Name())
+				}
+			}
+		}
+	}
+}
+
+func TestSchema_EnumsPassValidate(t *testing.T) {
+	for _, v := range schemaEnums[reflect.TypeOf(Environment(""))] {
+		if err := Validate(Profile{Environment: Environment(v), Launch: LaunchShell}); err != nil {
+			t.Errorf("environment %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(LaunchMode(""))] {
+		if err := Validate(Profile{Environment: EnvironmentHost, Launch: LaunchMode(v)}); err != nil {
+			t.Errorf("launch %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(DockerClient(""))] {
+		p := Profile{Environment: EnvironmentDocker, Launch: LaunchShell, Docker: &DockerConfig{Client: DockerClient(v)}}
+		if err := Validate(p); err != nil {
+			t.Errorf("docker.client %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(Runtime(""))] {
+		p := Profile{Environment: EnvironmentDocker, Launch: LaunchShell, Runtime: Runtime(v)}
+		if err := Validate(p); err != nil {
+			t.Errorf("runtime %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(WorktreeReuse(""))] {
+		p := Profile{Environment: EnvironmentHost, Launch: LaunchShell, Worktree: &WorktreeConfig{Reuse: WorktreeReuse(v)}}
+		if err := Validate(p); err != nil {
+			t.Errorf("worktree.reuse %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(WorktreeSubmodules(""))] {
+		p := Profile{Environment: EnvironmentHost, Launch: LaunchShell, Worktree: &WorktreeConfig{Submodules: WorktreeSubmodules(v)}}
+		if err := Validate(p); err != nil {
+			t.Errorf("worktree.submodules %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(DockerPull(""))] {
+		p := Profile{Environment: EnvironmentDocker, Launch: LaunchShell, Image: "img", Docker: &DockerConfig{Pull: DockerPull(v)}}
+		if err := Validate(p); err != nil {
+			t.Errorf("docker.pull %q: %v", v, err)
+		}
+	}
+	for _, v := range schemaEnums[reflect.TypeOf(WorktreeFetch(""))] {
+		p := Profile{Environment: EnvironmentHost, Launch: LaunchShell, Worktree: &WorktreeConfig{Fetch: WorktreeFetch(v)}}
+		if err := Validate(p); err != nil {
+			t.Errorf("worktree.fetch %q: %v", v, err)
+		}
+	}
+}
+
+func TestSchema_Structure(t *testing.T) {
+	out, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error: %v", err)
+	}
+	var s struct {
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Definitions map[string]struct {
+			Properties           map[string]json.RawMessage `json:"properties"`
+			AdditionalProperties bool                       `json:"additionalProperties"`
+			AllOf                []json.RawMessage          `json:"allOf"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(out, &s); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if _, ok := s.Properties["extends"]; ok {
+		t.Error("top-level schema should not allow extends")
+	}
+	for _, key := range []string{"default", "profiles", "environment", "worktree"} {
+		if _, ok := s.Properties[key]; !ok {
+			t.Errorf("top-level schema missing %q", key)
+		}
+	}
+	profile := s.Definitions["profile"]
+	if _, ok := profile.Properties["extends"]; !ok {
+		t.Error("profile schema missing extends")
+	}
+	if len(profile.AllOf) != 3 {
+		t.Errorf("profile schema has %d cross-field rules, want 3", len(profile.AllOf))
+	}
+}
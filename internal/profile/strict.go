@@ -0,0 +1,203 @@
+package profile
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkKnownKeys reports every mapping key in n that has no matching field in
+// t, with a "did you mean" suggestion when a known key is close. file may be
+// empty; it only prefixes the reported positions.
+func checkKnownKeys(file string, n *yaml.Node, t reflect.Type) []string {
+	var errs []string
+	reported := make(map[*yaml.Node]bool)
+	walkKnownKeys(n, t, "", func(key *yaml.Node, where string, known []string) {
+		// A key in an anchored mapping is reached again through each alias.
+		if reported[key] {
+			return
+		}
+		reported[key] = true
+		msg := fmt.Sprintf("unknown key %q", key.Value)
+		if where != "" {
+			msg += " in " + where
+		}
+		if s := suggest(key.Value, known); s != "" {
+			msg += fmt.Sprintf("; did you mean %q?", s)
+		}
+		errs = append(errs, positioned(file, key, msg))
+	})
+	return errs
+}
+
+func walkKnownKeys(n *yaml.Node, t reflect.Type, path string, unknown func(key *yaml.Node, where string, known []string)) {
+	if n == nil {
+		return
+	}
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			walkKnownKeys(c, t, path, unknown)
+		}
+		return
+	}
+	if n.Kind == yaml.AliasNode {
+		walkKnownKeys(n.Alias, t, path, unknown)
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		known := make([]string, 0, len(fields))
+		for name := range fields {
+			known = append(known, name)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if isMergeKey(k) {
+				walkMerged(v, t, path, unknown)
+				continue
+			}
+			ft, ok := fields[k.Value]
+			if !ok {
+				unknown(k, path, known)
+				continue
+			}
+			walkKnownKeys(v, ft, joinPath(path, k.Value), unknown)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if isMergeKey(n.Content[i]) {
+				walkMerged(n.Content[i+1], t, path, unknown)
+				continue
+			}
+			walkKnownKeys(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value), unknown)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, c := range n.Content {
+			walkKnownKeys(c, t.Elem(), path, unknown)
+		}
+	}
+}
+
+// isMergeKey reports whether key is a YAML merge key ("<<").
+func isMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.Tag == "!!merge"
+}
+
+// walkMerged checks the mappings merged into a mapping of type t by a "<<"
+// key: a mapping, an alias of one, or a sequence of them.
+func walkMerged(v *yaml.Node, t reflect.Type, path string, unknown func(key *yaml.Node, where string, known []string)) {
+	if v.Kind == yaml.SequenceNode {
+		for _, c := range v.Content {
+			walkKnownKeys(c, t, path, unknown)
+		}
+		return
+	}
+	walkKnownKeys(v, t, path, unknown)
+}
+
+// yamlFields maps the YAML keys of struct type t to their field types,
+// flattening `,inline` fields and skipping `-`.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// positioned prefixes msg with the position of n: "file:line:col: msg", or
+// "line L, column C: msg" when file is unknown.
+func positioned(file string, n *yaml.Node, msg string) string {
+	if n == nil || n.Line == 0 {
+		if file != "" {
+			return file + ": " + msg
+		}
+		return msg
+	}
+	if file == "" {
+		return fmt.Sprintf("line %d, column %d: %s", n.Line, n.Column, msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", file, n.Line, n.Column, msg)
+}
+
+// suggest returns the candidate closest to s, or "" if none is close enough
+// to be a plausible typo.
+func suggest(s string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(s), strings.ToLower(c))
+		if bestDist < 0 || d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	limit := len(s) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -1,11 +1,48 @@
 package profile
 
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // ConfigSource describes where the config was loaded from.
 type ConfigSource struct {
-	IsBuiltin bool   // true if the built-in default config was used
-	FilePath  string // non-empty if loaded from a file
+	IsBuiltin bool         // true if no config file was found and only the built-in default is used
+	Files     []SourceFile // config files that contributed, lowest precedence first
+
+	// ProfileLayers lists, for each profile, the layers that declared it,
+	// lowest precedence first. The built-in layer is included for built-in
+	// profiles.
+	ProfileLayers map[string][]Layer
+
+	layers []parsedLayer // raw per-layer configs, lowest precedence first (for Explain)
 }
 
+// parsedLayer is one config layer as parsed, before merging.
+type parsedLayer struct {
+	File   SourceFile
+	Config Config
+	Node   *yaml.Node // document node, for error positions; nil for the builtin layer
+}
+
+// SourceFile is a config file that contributed to the loaded config.
+type SourceFile struct {
+	Layer Layer
+	Path  string
+}
+
+// Layer identifies one level of the layered config. Later layers override
+// earlier ones.
+type Layer string
+
+const (
+	LayerBuiltin Layer = "builtin" // compiled-in default
+	LayerUser    Layer = "user"    // $XDG_CONFIG_HOME/agent-workspace/config.yml
+	LayerRepo    Layer = "repo"    // <repoRoot>/.agent-workspace.yml
+	LayerLocal   Layer = "local"   // <repoRoot>/.agent-workspace.local.yml (git-ignored)
+)
+
 // Config represents the top-level .agent-workspace.yml file.
 //
 // Profile fields declared at the top level (via the embedded Profile) act as
@@ -20,20 +57,66 @@ type Config struct {
 
 // Profile describes a single named workspace profile.
 type Profile struct {
+	Extends     string            `yaml:"extends,omitempty"` // name of the profile this one inherits from
 	Worktree    *WorktreeConfig   `yaml:"worktree,omitempty"`
 	Environment Environment       `yaml:"environment"`
 	Launch      LaunchMode        `yaml:"launch"`
 	Zellij      *ZellijConfig     `yaml:"zellij,omitempty"`
 	Env         map[string]string `yaml:"env,omitempty"`    // custom env vars to pass into Docker container
 	Dockerfile  string            `yaml:"dockerfile,omitempty"` // custom Dockerfile path (docker environment only)
+	Image       string            `yaml:"image,omitempty"`      // prebuilt image to run instead of building one (docker environment only)
+	Docker      *DockerConfig     `yaml:"docker,omitempty"`     // container engine settings (docker environment only)
+	Runtime     Runtime           `yaml:"runtime,omitempty"`    // container runtime (docker environment only); default: "docker"
+}
+
+// EffectiveRuntime returns the container runtime, defaulting to "docker" if empty.
+func (p Profile) EffectiveRuntime() Runtime {
+	if p.Runtime != "" {
+		return p.Runtime
+	}
+	return RuntimeDocker
 }
 
 // WorktreeConfig controls git worktree creation.
 type WorktreeConfig struct {
-	Base     string `yaml:"base,omitempty"`      // default: "origin/main"
-	Dir      string `yaml:"dir,omitempty"`       // directory to host worktrees in; default: <repoRoot>/worktrees. Supports ~ expansion and paths relative to repoRoot.
-	OnCreate string `yaml:"on-create,omitempty"` // shell command to run after worktree creation
-	OnEnd    string `yaml:"on-end,omitempty"`    // shell command to run after launched process exits
+	Base          string             `yaml:"base,omitempty"`           // default: "origin/main"
+	Dir           string             `yaml:"dir,omitempty"`            // directory to host worktrees in; default: <repoRoot>/worktrees. Supports ~ expansion and paths relative to repoRoot.
+	OnCreate      string             `yaml:"on-create,omitempty"`      // shell command to run after worktree creation
+	OnEnd         string             `yaml:"on-end,omitempty"`         // shell command to run after launched process exits
+	Reuse         WorktreeReuse      `yaml:"reuse,omitempty"`          // "never" (default), "prompt" or "latest"
+	Branch        string             `yaml:"branch,omitempty"`         // branch name template; default: "{{.Words}}"
+	Copy          []CopyEntry        `yaml:"copy,omitempty"`           // untracked files to copy from the repo root into new worktrees
+	Sparse        []string           `yaml:"sparse,omitempty"`         // directories to check out (cone-mode sparse checkout); default: everything
+	Submodules    WorktreeSubmodules `yaml:"submodules,omitempty"`     // "none" (default) or "recursive"
+	Fetch         WorktreeFetch      `yaml:"fetch,omitempty"`          // "always" (default), "if-stale" or "never"
+	FetchInterval string             `yaml:"fetch-interval,omitempty"` // with fetch: if-stale, how old the last fetch may be, e.g. "30m"; default: "1h"
+}
+
+// CopyEntry is an item of worktree.copy: a glob, relative to the repository
+// root, of files to bring into a new worktree. In YAML it is either the glob
+// alone or a mapping with path and link.
+type CopyEntry struct {
+	Path string `yaml:"path"`           // glob relative to the repository root
+	Link bool   `yaml:"link,omitempty"` // symlink matches instead of copying them
+}
+
+// UnmarshalYAML accepts the short form, a plain glob.
+func (c *CopyEntry) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*c = CopyEntry{}
+		return n.Decode(&c.Path)
+	}
+	type plain CopyEntry
+	return n.Decode((*plain)(c))
+}
+
+// MarshalYAML writes the short form when Link is not set.
+func (c CopyEntry) MarshalYAML() (any, error) {
+	if !c.Link {
+		return c.Path, nil
+	}
+	type plain CopyEntry
+	return plain(c), nil
 }
 
 // EffectiveBase returns the base ref, defaulting to "origin/main" if empty.
@@ -44,6 +127,97 @@ func (w *WorktreeConfig) EffectiveBase() string {
 	return "origin/main"
 }
 
+// EffectiveBranch returns the branch name template, defaulting to three
+// random words if empty.
+func (w *WorktreeConfig) EffectiveBranch() string {
+	if w != nil && w.Branch != "" {
+		return w.Branch
+	}
+	return "{{.Words}}"
+}
+
+// EffectiveReuse returns the reuse policy, defaulting to "never" if empty.
+func (w *WorktreeConfig) EffectiveReuse() WorktreeReuse {
+	if w != nil && w.Reuse != "" {
+		return w.Reuse
+	}
+	return WorktreeReuseNever
+}
+
+// EffectiveSubmodules returns the submodule policy, defaulting to "none" if
+// empty.
+func (w *WorktreeConfig) EffectiveSubmodules() WorktreeSubmodules {
+	if w != nil && w.Submodules != "" {
+		return w.Submodules
+	}
+	return WorktreeSubmodulesNone
+}
+
+// DefaultFetchInterval is how old the last fetch may be before
+// fetch: if-stale fetches again.
+const DefaultFetchInterval = time.Hour
+
+// EffectiveFetch returns the fetch policy, defaulting to "always" if empty.
+func (w *WorktreeConfig) EffectiveFetch() WorktreeFetch {
+	if w != nil && w.Fetch != "" {
+		return w.Fetch
+	}
+	return WorktreeFetchAlways
+}
+
+// EffectiveFetchInterval returns the staleness window of fetch: if-stale,
+// defaulting to DefaultFetchInterval if empty or invalid.
+func (w *WorktreeConfig) EffectiveFetchInterval() time.Duration {
+	if w != nil && w.FetchInterval != "" {
+		if d, err := time.ParseDuration(w.FetchInterval); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultFetchInterval
+}
+
+// DockerConfig controls how aw talks to the container engine.
+type DockerConfig struct {
+	Client   DockerClient    `yaml:"client,omitempty"`   // "cli" (default) or "api"
+	Build    *DockerBuild    `yaml:"build,omitempty"`    // how the image is built
+	Pull     DockerPull      `yaml:"pull,omitempty"`     // when to pull the image of image: "missing" (default), "always" or "never"
+	Features *DockerFeatures `yaml:"features,omitempty"` // toolchains layered onto the embedded Dockerfile
+}
+
+// DockerBuild controls the build of the profile's image.
+type DockerBuild struct {
+	Context  string            `yaml:"context,omitempty"`  // build context directory, relative to the repository root (custom dockerfile only); default: the Dockerfile and entrypoint.sh alone
+	Args     map[string]string `yaml:"args,omitempty"`     // build args; values may use ${VAR} to read the host environment
+	Target   string            `yaml:"target,omitempty"`   // stage of a multi-stage Dockerfile to build (custom dockerfile only)
+	Platform string            `yaml:"platform,omitempty"` // platform to build for, e.g. "linux/amd64"; default: the engine's
+}
+
+// DockerFeatures adds toolchains to the embedded image. aw renders them as
+// extra layers of a generated Dockerfile, so the image tag, a hash of the
+// build context, changes with them.
+type DockerFeatures struct {
+	Go     string   `yaml:"go,omitempty"`     // Go release, e.g. "1.24" (the latest patch release) or "1.24.2"; replaces the embedded Go
+	Rust   string   `yaml:"rust,omitempty"`   // Rust toolchain: "stable", "beta", "nightly" or a release such as "1.82"
+	Python string   `yaml:"python,omitempty"` // Python release, e.g. "3.12", installed with uv and made the default python3
+	Apt    []string `yaml:"apt,omitempty"`    // extra Debian packages, e.g. [ripgrep, jq]
+}
+
+// EffectiveClient returns the client kind, defaulting to "cli" if empty.
+func (d *DockerConfig) EffectiveClient() DockerClient {
+	if d != nil && d.Client != "" {
+		return d.Client
+	}
+	return DockerClientCLI
+}
+
+// EffectivePull returns the pull policy, defaulting to "missing" if empty.
+func (d *DockerConfig) EffectivePull() DockerPull {
+	if d != nil && d.Pull != "" {
+		return d.Pull
+	}
+	return DockerPullMissing
+}
+
 // ZellijConfig controls zellij session settings.
 type ZellijConfig struct {
 	Layout string `yaml:"layout,omitempty"` // "default" or custom path (future)
@@ -57,6 +231,60 @@ const (
 	EnvironmentDocker Environment = "docker"
 )
 
+// DockerClient specifies how aw talks to the Docker daemon.
+type DockerClient string
+
+const (
+	DockerClientCLI DockerClient = "cli" // shell out to the docker CLI
+	DockerClientAPI DockerClient = "api" // Engine API over DOCKER_HOST / the unix socket
+)
+
+// DockerPull specifies when the image of a profile's image field is pulled.
+type DockerPull string
+
+const (
+	DockerPullAlways  DockerPull = "always"  // pull before every run
+	DockerPullMissing DockerPull = "missing" // pull only if the image is not present locally
+	DockerPullNever   DockerPull = "never"   // never pull; the image must be present locally
+)
+
+// Runtime specifies which container engine runs the docker environment.
+type Runtime string
+
+const (
+	RuntimeDocker Runtime = "docker"
+	RuntimePodman Runtime = "podman"
+	RuntimeAuto   Runtime = "auto" // docker if available, otherwise podman
+)
+
+// WorktreeReuse specifies whether a run resumes an existing worktree instead
+// of creating a new one.
+type WorktreeReuse string
+
+const (
+	WorktreeReuseNever  WorktreeReuse = "never"  // always create a new worktree
+	WorktreeReusePrompt WorktreeReuse = "prompt" // ask which worktree to resume
+	WorktreeReuseLatest WorktreeReuse = "latest" // resume the most recently used worktree
+)
+
+// WorktreeSubmodules specifies whether submodules are checked out in new
+// worktrees.
+type WorktreeSubmodules string
+
+const (
+	WorktreeSubmodulesNone      WorktreeSubmodules = "none"      // leave submodules uninitialized
+	WorktreeSubmodulesRecursive WorktreeSubmodules = "recursive" // check out submodules and theirs
+)
+
+// WorktreeFetch specifies when a run fetches the base ref from its remote.
+type WorktreeFetch string
+
+const (
+	WorktreeFetchAlways  WorktreeFetch = "always"   // fetch on every run
+	WorktreeFetchIfStale WorktreeFetch = "if-stale" // fetch when the last fetch is older than fetch-interval
+	WorktreeFetchNever   WorktreeFetch = "never"    // use the local ref
+)
+
 // LaunchMode specifies what to launch.
 type LaunchMode string
 
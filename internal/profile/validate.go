@@ -2,19 +2,60 @@ package profile
 
 import (
 	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// FieldError is a validation error about one field of a profile. ValidateConfig
+// uses Field to point at the line of the config file that set it.
+type FieldError struct {
+	Field string // dotted YAML path within the profile, e.g. "docker.client"
+	Msg   string
+}
+
+func (e *FieldError) Error() string { return e.Msg }
+
+func fieldErrorf(field, format string, args ...any) *FieldError {
+	return &FieldError{Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+// unknownValue reports a value that is not one of allowed, suggesting the
+// closest allowed value when the input looks like a typo.
+func unknownValue(field, what, value string, allowed ...string) *FieldError {
+	quoted := make([]string, len(allowed))
+	for i, a := range allowed {
+		quoted[i] = fmt.Sprintf("%q", a)
+	}
+	list := strings.Join(quoted, " or ")
+	if len(quoted) > 2 {
+		list = strings.Join(quoted[:len(quoted)-1], ", ") + ", or " + quoted[len(quoted)-1]
+	}
+	msg := fmt.Sprintf("unknown %s: %q (must be %s)", what, value, list)
+	if s := suggest(value, allowed); s != "" {
+		msg += fmt.Sprintf("; did you mean %q?", s)
+	}
+	return &FieldError{Field: field, Msg: msg}
+}
+
 // Validate checks that a profile configuration is semantically valid.
+// Errors about a specific field are returned as *FieldError.
 func Validate(p Profile) error {
 	// Validate environment
 	switch p.Environment {
 	case EnvironmentHost, EnvironmentDocker:
 		// ok
 	case "":
-		return fmt.Errorf("environment is required (\"host\" or \"docker\")")
+		return fieldErrorf("environment", "environment is required (\"host\" or \"docker\")")
 	default:
-		return fmt.Errorf("unknown environment: %q (must be \"host\" or \"docker\")", p.Environment)
+		return unknownValue("environment", "environment", string(p.Environment), "host", "docker")
 	}
 
 	// Validate launch mode
@@ -22,25 +63,146 @@ func Validate(p Profile) error {
 	case LaunchShell, LaunchClaude, LaunchZellij:
 		// ok
 	case "":
-		return fmt.Errorf("launch is required (\"shell\", \"claude\", or \"zellij\")")
+		return fieldErrorf("launch", "launch is required (\"shell\", \"claude\", or \"zellij\")")
 	default:
-		return fmt.Errorf("unknown launch mode: %q (must be \"shell\", \"claude\", or \"zellij\")", p.Launch)
+		return unknownValue("launch", "launch mode", string(p.Launch), "shell", "claude", "zellij")
+	}
+
+	// Validate worktree reuse policy, branch template, copy globs, checkout
+	// and fetch policy
+	if p.Worktree != nil {
+		switch p.Worktree.Reuse {
+		case "", WorktreeReuseNever, WorktreeReusePrompt, WorktreeReuseLatest:
+			// ok
+		default:
+			return unknownValue("worktree.reuse", "worktree reuse policy", string(p.Worktree.Reuse), "never", "prompt", "latest")
+		}
+		if err := checkBranchTemplate(p.Worktree.Branch); err != nil {
+			return fieldErrorf("worktree.branch", "invalid worktree.branch template: %v", err)
+		}
+		for _, c := range p.Worktree.Copy {
+			if err := checkCopyPath(c.Path); err != nil {
+				return fieldErrorf("worktree.copy", "invalid worktree.copy entry %q: %v", c.Path, err)
+			}
+		}
+		for _, dir := range p.Worktree.Sparse {
+			if err := checkSparsePath(dir); err != nil {
+				return fieldErrorf("worktree.sparse", "invalid worktree.sparse entry %q: %v", dir, err)
+			}
+		}
+		switch p.Worktree.Submodules {
+		case "", WorktreeSubmodulesNone, WorktreeSubmodulesRecursive:
+			// ok
+		default:
+			return unknownValue("worktree.submodules", "worktree submodules policy", string(p.Worktree.Submodules), "none", "recursive")
+		}
+		switch p.Worktree.Fetch {
+		case "", WorktreeFetchAlways, WorktreeFetchIfStale, WorktreeFetchNever:
+			// ok
+		default:
+			return unknownValue("worktree.fetch", "worktree fetch policy", string(p.Worktree.Fetch), "always", "if-stale", "never")
+		}
+		if p.Worktree.FetchInterval != "" {
+			if d, err := time.ParseDuration(p.Worktree.FetchInterval); err != nil || d <= 0 {
+				return fieldErrorf("worktree.fetch-interval", "invalid worktree.fetch-interval %q: must be a positive duration such as \"30m\" or \"2h\"", p.Worktree.FetchInterval)
+			}
+		}
 	}
 
 	// Validate zellij config is only used with launch: zellij
 	if p.Zellij != nil && p.Launch != LaunchZellij {
-		return fmt.Errorf("zellij config is only valid with launch: zellij")
+		return fieldErrorf("zellij", "zellij config is only valid with launch: zellij")
 	}
 
 	// Validate dockerfile is only used with environment: docker
 	if p.Dockerfile != "" && p.Environment != EnvironmentDocker {
-		return fmt.Errorf("dockerfile is only valid with environment: docker")
+		return fieldErrorf("dockerfile", "dockerfile is only valid with environment: docker")
+	}
+
+	// Validate image: a prebuilt image replaces the build entirely
+	if p.Image != "" {
+		if p.Environment != EnvironmentDocker {
+			return fieldErrorf("image", "image is only valid with environment: docker")
+		}
+		if p.Dockerfile != "" {
+			return fieldErrorf("image", "image and dockerfile are mutually exclusive: run a prebuilt image or build one, not both")
+		}
+		if strings.ContainsAny(p.Image, " \t\n") || strings.HasPrefix(p.Image, "-") {
+			return fieldErrorf("image", "invalid image reference %q", p.Image)
+		}
+	}
+
+	// Validate docker config
+	if p.Docker != nil {
+		if p.Environment != EnvironmentDocker {
+			return fieldErrorf("docker", "docker config is only valid with environment: docker")
+		}
+		switch p.Docker.Client {
+		case "", DockerClientCLI, DockerClientAPI:
+			// ok
+		default:
+			return unknownValue("docker.client", "docker client", string(p.Docker.Client), "cli", "api")
+		}
+		if err := validateDockerBuild(p); err != nil {
+			return err
+		}
+		switch p.Docker.Pull {
+		case "", DockerPullAlways, DockerPullMissing, DockerPullNever:
+			// ok
+		default:
+			return unknownValue("docker.pull", "docker pull policy", string(p.Docker.Pull), "always", "missing", "never")
+		}
+		if p.Docker.Pull != "" && p.Image == "" {
+			return fieldErrorf("docker.pull", "docker.pull is only valid with image")
+		}
+		if err := validateDockerFeatures(p); err != nil {
+			return err
+		}
+	}
+
+	// Validate runtime
+	if p.Runtime != "" {
+		if p.Environment != EnvironmentDocker {
+			return fieldErrorf("runtime", "runtime is only valid with environment: docker")
+		}
+		switch p.Runtime {
+		case RuntimeDocker, RuntimePodman, RuntimeAuto:
+			// ok
+		default:
+			return unknownValue("runtime", "runtime", string(p.Runtime), "docker", "podman", "auto")
+		}
 	}
 
 	return nil
 }
 
-// ValidateConfig checks the entire config for errors.
+// branchTemplateFields are the fields a worktree.branch template can use.
+// Keep in step with worktree.BranchData.
+var branchTemplateFields = []string{"User", "Date", "Words", "Issue", "Title", "PR", "Profile"}
+
+// checkBranchTemplate parses tmpl and expands it with placeholder values, so
+// that syntax errors and unknown fields such as {{.Usr}} are reported when
+// the config is loaded rather than when a worktree is created.
+func checkBranchTemplate(tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	t, err := template.New("branch").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]string, len(branchTemplateFields))
+	for _, f := range branchTemplateFields {
+		data[f] = "x"
+	}
+	if err := t.Execute(io.Discard, data); err != nil {
+		return fmt.Errorf("%v (available: %s)", err, "{{."+strings.Join(branchTemplateFields, "}}, {{.")+"}}")
+	}
+	return nil
+}
+
+// ValidateConfig checks the entire config for errors. For a config loaded
+// with LoadLayers, each error is prefixed with the file:line:col that caused it.
 func ValidateConfig(cfg *Config) error {
 	if len(cfg.Profiles) == 0 {
 		return fmt.Errorf("no profiles defined")
@@ -49,21 +211,214 @@ func ValidateConfig(cfg *Config) error {
 	// Check that default profile exists if specified
 	if cfg.Default != "" {
 		if _, ok := cfg.Profiles[cfg.Default]; !ok {
-			return fmt.Errorf("default profile %q not found in profiles", cfg.Default)
+			names := make([]string, 0, len(cfg.Profiles))
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			msg := fmt.Sprintf("default profile %q not found in profiles", cfg.Default)
+			if s := suggest(cfg.Default, names); s != "" {
+				msg += fmt.Sprintf("; did you mean %q?", s)
+			}
+			file, node := cfg.topLevelKey("default")
+			return fmt.Errorf("%s", positioned(file, node, msg))
 		}
 	}
 
+	if cfg.Profile.Extends != "" {
+		file, node := cfg.topLevelKey("extends")
+		return fmt.Errorf("%s", positioned(file, node, "extends is only valid inside a profile, not at the top level"))
+	}
+
 	// Validate each profile
 	var errs []string
 	for name, p := range cfg.Profiles {
+		if _, err := ResolveExtends(cfg.Profiles, name); err != nil {
+			file, node := cfg.profileKey(name, "extends")
+			errs = append(errs, positioned(file, node, fmt.Sprintf("profile %q: %v", name, err)))
+			continue
+		}
 		if err := Validate(p); err != nil {
-			errs = append(errs, fmt.Sprintf("profile %q: %v", name, err))
+			var field string
+			if fe, ok := err.(*FieldError); ok {
+				field = fe.Field
+			}
+			file, node := cfg.profileKey(name, field)
+			errs = append(errs, positioned(file, node, fmt.Sprintf("profile %q: %v", name, err)))
 		}
 	}
 
 	if len(errs) > 0 {
+		sort.Strings(errs)
 		return fmt.Errorf("config validation errors:\n  %s", strings.Join(errs, "\n  "))
 	}
 
 	return nil
 }
+
+// topLevelKey returns the file and key node of the highest layer that sets
+// the top-level key, or ("", nil) if no layer carries positions for it.
+func (c *Config) topLevelKey(key string) (string, *yaml.Node) {
+	for i := len(c.Source.layers) - 1; i >= 0; i-- {
+		l := c.Source.layers[i]
+		if n := lookupKey(l.Node, key); n != nil {
+			return l.File.Path, n
+		}
+	}
+	return "", nil
+}
+
+// profileKey returns the file and key node that set field of the named
+// profile, following the merge order through top-level defaults and
+// `extends` parents. If no file sets the field (e.g. a missing required
+// field), it points at the profile's own key in the highest layer.
+func (c *Config) profileKey(name, field string) (string, *yaml.Node) {
+	if field != "" {
+		if srcs, err := c.fieldSources(name); err == nil {
+			if src, ok := srcs[field]; ok {
+				l := c.Source.layers[src.layer]
+				path := strings.Split(field, ".")
+				if src.profile != "" {
+					path = append([]string{"profiles", src.profile}, path...)
+				}
+				if n := lookupKey(l.Node, path...); n != nil {
+					return l.File.Path, n
+				}
+			}
+		}
+	}
+	for i := len(c.Source.layers) - 1; i >= 0; i-- {
+		l := c.Source.layers[i]
+		if n := lookupKey(l.Node, "profiles", name); n != nil {
+			return l.File.Path, n
+		}
+	}
+	return "", nil
+}
+
+// lookupKey returns the key node at path in the document or mapping n, or
+// nil if any element of path is missing.
+func lookupKey(n *yaml.Node, path ...string) *yaml.Node {
+	if n != nil && n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+		n = n.Content[0]
+	}
+	var key *yaml.Node
+	for _, p := range path {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		key = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == p {
+				key, n = n.Content[i], n.Content[i+1]
+				break
+			}
+		}
+		if key == nil {
+			return nil
+		}
+	}
+	return key
+}
+
+// checkCopyPath reports whether path is a glob that stays inside the
+// repository: relative, without "..", and well-formed.
+func checkCopyPath(path string) error {
+	switch {
+	case path == "":
+		return fmt.Errorf("path is required")
+	case filepath.IsAbs(path):
+		return fmt.Errorf("path must be relative to the repository root")
+	case slices.Contains(strings.Split(filepath.ToSlash(path), "/"), ".."):
+		return fmt.Errorf("path must not contain \"..\"")
+	}
+	if _, err := filepath.Match(path, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkSparsePath reports whether dir can be a cone-mode sparse checkout
+// entry: a directory relative to the repository root, not a pattern.
+func checkSparsePath(dir string) error {
+	switch {
+	case dir == "":
+		return fmt.Errorf("path is required")
+	case filepath.IsAbs(dir):
+		return fmt.Errorf("path must be relative to the repository root")
+	case slices.Contains(strings.Split(filepath.ToSlash(dir), "/"), ".."):
+		return fmt.Errorf("path must not contain \"..\"")
+	case strings.ContainsAny(dir, "*?["):
+		return fmt.Errorf("sparse checkout takes directories, not patterns")
+	}
+	return nil
+}
+
+// validateDockerBuild checks docker.build. The context and target only make
+// sense for a custom Dockerfile; the embedded one copies nothing but
+// entrypoint.sh and has a single stage.
+func validateDockerBuild(p Profile) error {
+	b := p.Docker.Build
+	if b == nil {
+		return nil
+	}
+	if p.Image != "" {
+		return fieldErrorf("docker.build", "docker.build is not valid with image: the image is pulled, not built")
+	}
+	if b.Context != "" && p.Dockerfile == "" {
+		return fieldErrorf("docker.build.context", "docker.build.context is only valid with a custom dockerfile")
+	}
+	if b.Target != "" && p.Dockerfile == "" {
+		return fieldErrorf("docker.build.target", "docker.build.target is only valid with a custom dockerfile")
+	}
+	for k := range b.Args {
+		if k == "" || strings.ContainsAny(k, "= \t") {
+			return fieldErrorf("docker.build.args", "invalid docker.build.args name %q", k)
+		}
+	}
+	if b.Platform != "" {
+		if parts := strings.Split(b.Platform, "/"); len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+			return fieldErrorf("docker.build.platform", "invalid docker.build.platform %q: must be os/arch[/variant], e.g. \"linux/amd64\"", b.Platform)
+		}
+	}
+	return nil
+}
+
+var (
+	goVersionRe     = regexp.MustCompile(`^1\.[0-9]+(\.[0-9]+)?$`)
+	rustVersionRe   = regexp.MustCompile(`^(stable|beta|nightly|1\.[0-9]+(\.[0-9]+)?)$`)
+	pythonVersionRe = regexp.MustCompile(`^3\.[0-9]+(\.[0-9]+)?$`)
+	aptPackageRe    = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+)
+
+// validateDockerFeatures checks docker.features. The features are rendered
+// into shell commands, so every value must match a strict pattern.
+func validateDockerFeatures(p Profile) error {
+	f := p.Docker.Features
+	if f == nil {
+		return nil
+	}
+	if p.Image != "" {
+		return fieldErrorf("docker.features", "docker.features is not valid with image: the image is pulled, not built")
+	}
+	if p.Dockerfile != "" {
+		return fieldErrorf("docker.features", "docker.features only extends the embedded Dockerfile: install the tools in your dockerfile instead")
+	}
+	if f.Go != "" && !goVersionRe.MatchString(f.Go) {
+		return fieldErrorf("docker.features.go", "invalid docker.features.go %q: must be a Go release such as \"1.24\" or \"1.24.2\"", f.Go)
+	}
+	if f.Rust != "" && !rustVersionRe.MatchString(f.Rust) {
+		return fieldErrorf("docker.features.rust", "invalid docker.features.rust %q: must be \"stable\", \"beta\", \"nightly\" or a release such as \"1.82\"", f.Rust)
+	}
+	if f.Python != "" && !pythonVersionRe.MatchString(f.Python) {
+		return fieldErrorf("docker.features.python", "invalid docker.features.python %q: must be a Python release such as \"3.12\"", f.Python)
+	}
+	for _, pkg := range f.Apt {
+		if !aptPackageRe.MatchString(pkg) {
+			return fieldErrorf("docker.features.apt", "invalid docker.features.apt package %q", pkg)
+		}
+	}
+	return nil
+}
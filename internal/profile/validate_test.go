@@ -1,6 +1,8 @@
 package profile
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -57,6 +59,14 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "unknown environment",
 		},
+		{
+			name: "misspelled environment suggests the closest value",
+			profile: Profile{
+				Environment: "dcoker",
+				Launch:      LaunchClaude,
+			},
+			wantErr: `unknown environment: "dcoker" (must be "host" or "docker"); did you mean "docker"?`,
+		},
 		{
 			name: "unknown launch mode",
 			profile: Profile{
@@ -65,6 +75,14 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "unknown launch mode",
 		},
+		{
+			name: "unrelated launch mode has no suggestion",
+			profile: Profile{
+				Environment: EnvironmentHost,
+				Launch:      "tmux",
+			},
+			wantErr: `(must be "shell", "claude", or "zellij")`,
+		},
 		{
 			name: "zellij config with non-zellij launch",
 			profile: Profile{
@@ -91,6 +109,330 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "dockerfile is only valid with environment: docker",
 		},
+		{
+			name: "valid docker with api client",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Client: DockerClientAPI},
+			},
+		},
+		{
+			name: "unknown docker client",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Client: "grpc"},
+			},
+			wantErr: "unknown docker client",
+		},
+		{
+			name: "valid docker build",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Dockerfile:  "docker/Dockerfile",
+				Docker: &DockerConfig{Build: &DockerBuild{
+					Context:  "docker",
+					Args:     map[string]string{"NPM_TOKEN": "${NPM_TOKEN}"},
+					Target:   "dev",
+					Platform: "linux/arm64/v8",
+				}},
+			},
+		},
+		{
+			name: "docker build context without a custom dockerfile",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Build: &DockerBuild{Context: "."}},
+			},
+			wantErr: "docker.build.context is only valid with a custom dockerfile",
+		},
+		{
+			name: "docker build target without a custom dockerfile",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Build: &DockerBuild{Target: "dev"}},
+			},
+			wantErr: "docker.build.target is only valid with a custom dockerfile",
+		},
+		{
+			name: "invalid docker build arg name",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Build: &DockerBuild{Args: map[string]string{"A=B": "c"}}},
+			},
+			wantErr: `invalid docker.build.args name "A=B"`,
+		},
+		{
+			name: "invalid docker build platform",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Build: &DockerBuild{Platform: "amd64"}},
+			},
+			wantErr: `invalid docker.build.platform "amd64"`,
+		},
+		{
+			name: "valid prebuilt image",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Image:       "registry.example.com/agents/claude:1.4",
+				Docker:      &DockerConfig{Pull: DockerPullAlways},
+			},
+		},
+		{
+			name: "image with dockerfile",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Image:       "registry.example.com/agents/claude:1.4",
+				Dockerfile:  "Dockerfile",
+			},
+			wantErr: "image and dockerfile are mutually exclusive",
+		},
+		{
+			name: "image with non-docker environment",
+			profile: Profile{
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+				Image:       "claude:latest",
+			},
+			wantErr: "image is only valid with environment: docker",
+		},
+		{
+			name: "image with docker build",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Image:       "claude:latest",
+				Docker:      &DockerConfig{Build: &DockerBuild{Platform: "linux/amd64"}},
+			},
+			wantErr: "docker.build is not valid with image",
+		},
+		{
+			name: "docker pull without image",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Pull: DockerPullNever},
+			},
+			wantErr: "docker.pull is only valid with image",
+		},
+		{
+			name: "unknown docker pull policy",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Image:       "claude:latest",
+				Docker:      &DockerConfig{Pull: "sometimes"},
+			},
+			wantErr: `unknown docker pull policy: "sometimes"`,
+		},
+		{
+			name: "valid docker features",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker: &DockerConfig{Features: &DockerFeatures{
+					Go:     "1.24",
+					Rust:   "stable",
+					Python: "3.12.7",
+					Apt:    []string{"ripgrep", "jq", "g++"},
+				}},
+			},
+		},
+		{
+			name: "docker features with a custom dockerfile",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Dockerfile:  "Dockerfile",
+				Docker:      &DockerConfig{Features: &DockerFeatures{Go: "1.24"}},
+			},
+			wantErr: "docker.features only extends the embedded Dockerfile",
+		},
+		{
+			name: "docker features with image",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Image:       "claude:latest",
+				Docker:      &DockerConfig{Features: &DockerFeatures{Apt: []string{"jq"}}},
+			},
+			wantErr: "docker.features is not valid with image",
+		},
+		{
+			name: "invalid go feature version",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Features: &DockerFeatures{Go: "latest"}},
+			},
+			wantErr: `invalid docker.features.go "latest"`,
+		},
+		{
+			name: "invalid rust feature version",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Features: &DockerFeatures{Rust: "stable; rm -rf /"}},
+			},
+			wantErr: `invalid docker.features.rust`,
+		},
+		{
+			name: "invalid python feature version",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Features: &DockerFeatures{Python: "2.7"}},
+			},
+			wantErr: `invalid docker.features.python "2.7"`,
+		},
+		{
+			name: "invalid apt package",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Docker:      &DockerConfig{Features: &DockerFeatures{Apt: []string{"jq", "--allow-unauthenticated"}}},
+			},
+			wantErr: `invalid docker.features.apt package "--allow-unauthenticated"`,
+		},
+		{
+			name: "docker config with non-docker environment",
+			profile: Profile{
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+				Docker:      &DockerConfig{Client: DockerClientAPI},
+			},
+			wantErr: "docker config is only valid with environment: docker",
+		},
+		{
+			name: "valid podman runtime",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Runtime:     RuntimePodman,
+			},
+		},
+		{
+			name: "unknown runtime",
+			profile: Profile{
+				Environment: EnvironmentDocker,
+				Launch:      LaunchClaude,
+				Runtime:     "lxc",
+			},
+			wantErr: "unknown runtime",
+		},
+		{
+			name: "runtime with non-docker environment",
+			profile: Profile{
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+				Runtime:     RuntimeAuto,
+			},
+			wantErr: "runtime is only valid with environment: docker",
+		},
+		{
+			name: "unknown worktree reuse policy",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Reuse: "lastest"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: `unknown worktree reuse policy: "lastest" (must be "never", "prompt", or "latest"); did you mean "latest"?`,
+		},
+		{
+			name: "valid worktree branch template",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Branch: "agent/{{.User}}/{{.Date}}-{{.Words}}"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+		},
+		{
+			name: "worktree branch template with an unknown field",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Branch: "agent/{{.Usr}}"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: "invalid worktree.branch template",
+		},
+		{
+			name: "worktree branch template with a syntax error",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Branch: "{{.Words"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: "invalid worktree.branch template",
+		},
+		{
+			name: "valid worktree copy globs",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Copy: []CopyEntry{{Path: ".env*"}, {Path: "node_modules", Link: true}}},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+		},
+		{
+			name: "worktree copy path outside the repository",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Copy: []CopyEntry{{Path: "../secrets/.env"}}},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: `invalid worktree.copy entry "../secrets/.env": path must not contain ".."`,
+		},
+		{
+			name: "worktree copy with a malformed glob",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Copy: []CopyEntry{{Path: "certs/[a-"}}},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: "invalid worktree.copy entry",
+		},
+		{
+			name: "worktree sparse checkout of a pattern",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Sparse: []string{"services/api", "libs/*"}},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: `invalid worktree.sparse entry "libs/*": sparse checkout takes directories, not patterns`,
+		},
+		{
+			name: "unknown worktree submodules policy",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Submodules: "recursve"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: `unknown worktree submodules policy: "recursve" (must be "none" or "recursive"); did you mean "recursive"?`,
+		},
+		{
+			name: "worktree fetch if stale",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Fetch: WorktreeFetchIfStale, FetchInterval: "30m"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+		},
+		{
+			name: "worktree fetch interval without a unit",
+			profile: Profile{
+				Worktree:    &WorktreeConfig{Fetch: WorktreeFetchIfStale, FetchInterval: "30"},
+				Environment: EnvironmentHost,
+				Launch:      LaunchShell,
+			},
+			wantErr: `invalid worktree.fetch-interval "30"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,6 +504,44 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: "config validation errors",
 		},
+		{
+			name: "extends unknown profile",
+			config: Config{
+				Profiles: map[string]Profile{
+					"child": {Extends: "missing", Launch: LaunchClaude},
+				},
+			},
+			wantErr: `profile "child": extends unknown profile "missing"`,
+		},
+		{
+			name: "extends cycle",
+			config: Config{
+				Profiles: map[string]Profile{
+					"a": {Extends: "b", Environment: EnvironmentHost, Launch: LaunchShell},
+					"b": {Extends: "a", Environment: EnvironmentHost, Launch: LaunchShell},
+				},
+			},
+			wantErr: "extends cycle",
+		},
+		{
+			name: "extends at top level",
+			config: Config{
+				Profile: Profile{Extends: "base"},
+				Profiles: map[string]Profile{
+					"base": {Environment: EnvironmentHost, Launch: LaunchShell},
+				},
+			},
+			wantErr: "extends is only valid inside a profile",
+		},
+		{
+			name: "valid extends",
+			config: Config{
+				Profiles: map[string]Profile{
+					"base":  {Environment: EnvironmentDocker, Launch: LaunchClaude},
+					"child": {Extends: "base", Environment: EnvironmentDocker, Launch: LaunchClaude, Dockerfile: "Dockerfile.gpu"},
+				},
+			},
+		},
 		{
 			name: "no default is ok",
 			config: Config{
@@ -193,3 +573,72 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_FieldError(t *testing.T) {
+	err := Validate(Profile{Environment: EnvironmentDocker, Launch: LaunchClaude, Docker: &DockerConfig{Client: "sdk"}})
+	fe, ok := err.(*FieldError)
+	if !ok {
+		t.Fatalf("Validate() error = %T %v, want *FieldError", err, err)
+	}
+	if fe.Field != "docker.client" {
+		t.Errorf("Field = %q, want %q", fe.Field, "docker.client")
+	}
+}
+
+func TestValidateConfig_Positions(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.yml")
+	repoPath := filepath.Join(dir, "repo.yml")
+
+	user := `runtime: podmn
+profiles:
+  base:
+    environment: docker
+    launch: claude
+`
+	repo := `default: devv
+profiles:
+  dev:
+    extends: base
+    launch: zelij
+  half:
+    environment: host
+`
+	if err := os.WriteFile(userPath, []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(repoPath, []byte(repo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadLayers([]SourceFile{
+		{Layer: LayerUser, Path: userPath},
+		{Layer: LayerRepo, Path: repoPath},
+	})
+	if err != nil {
+		t.Fatalf("LoadLayers() error: %v", err)
+	}
+
+	err = ValidateConfig(cfg)
+	want := repoPath + `:1:1: default profile "devv" not found in profiles; did you mean "dev"?`
+	if err == nil || err.Error() != want {
+		t.Fatalf("ValidateConfig() error = %v, want %q", err, want)
+	}
+
+	cfg.Default = "dev"
+	err = ValidateConfig(cfg)
+	if err == nil {
+		t.Fatal("ValidateConfig() expected error, got nil")
+	}
+	for _, want := range []string{
+		// own field, in the repo layer
+		repoPath + `:5:5: profile "dev": unknown launch mode: "zelij" (must be "shell", "claude", or "zellij"); did you mean "zellij"?`,
+		// missing field points at the profile key
+		repoPath + `:6:3: profile "half": launch is required`,
+		// top-level default inherited from the user layer
+		userPath + `:1:1: profile "base": unknown runtime: "podmn" (must be "docker", "podman", or "auto"); did you mean "podman"?`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateConfig() error = %q, want containing %q", err.Error(), want)
+		}
+	}
+}
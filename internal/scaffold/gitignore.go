@@ -0,0 +1,71 @@
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MissingGitignoreEntries returns the entries that root's .gitignore does
+// not already list. A pattern counts as listed with or without its leading
+// or trailing slash.
+func MissingGitignoreEntries(root string, entries []string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading .gitignore: %w", err)
+	}
+
+	present := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		present[normalizePattern(scanner.Text())] = true
+	}
+
+	var missing []string
+	for _, e := range entries {
+		if !present[normalizePattern(e)] {
+			missing = append(missing, e)
+		}
+	}
+	return missing, nil
+}
+
+// AppendGitignore appends entries to root's .gitignore under a comment,
+// creating the file if needed.
+func AppendGitignore(root string, entries []string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	path := filepath.Join(root, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading .gitignore: %w", err)
+	}
+
+	var b bytes.Buffer
+	if len(data) > 0 {
+		b.Write(data)
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			b.WriteByte('\n')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("# aw (agent-workspace)\n")
+	for _, e := range entries {
+		b.WriteString(e + "\n")
+	}
+
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing .gitignore: %w", err)
+	}
+	return nil
+}
+
+func normalizePattern(p string) string {
+	p = strings.TrimSpace(p)
+	p = strings.TrimPrefix(p, "/")
+	return strings.TrimSuffix(p, "/")
+}
@@ -0,0 +1,92 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Profile names written by Render.
+const (
+	ProfileWorktreeZellij = "worktree-zellij"
+	ProfileClaude         = "claude"
+	ProfileShell          = "worktree-shell"
+)
+
+// Profiles lists the profiles Render writes, in order.
+var Profiles = []string{ProfileWorktreeZellij, ProfileClaude, ProfileShell}
+
+// Options are the choices made during `aw init`.
+type Options struct {
+	Default  string // default profile; one of Profiles
+	Base     string // worktree.base
+	OnCreate string // worktree.on-create; "" to omit
+}
+
+// DefaultOptions returns the choices `aw init --yes` makes for r.
+func DefaultOptions(r Repo) Options {
+	return Options{
+		Default:  ProfileWorktreeZellij,
+		Base:     r.DefaultBase,
+		OnCreate: r.InstallCommand(),
+	}
+}
+
+// Render returns the starter config for r.
+func Render(r Repo, opts Options) []byte {
+	var b bytes.Buffer
+	b.WriteString("# aw (agent-workspace) config, generated by `aw init`.\n")
+	b.WriteString("# Reference: https://github.com/hiragram/agent-workspace/blob/main/docs/configuration.md\n")
+	b.WriteString("# yaml-language-server: $schema=https://raw.githubusercontent.com/hiragram/agent-workspace/main/docs/agent-workspace.schema.json\n\n")
+	fmt.Fprintf(&b, "default: %s\n\n", opts.Default)
+	b.WriteString("profiles:\n")
+
+	b.WriteString("  # Claude Code in Docker, in a new worktree, with a zellij layout\n")
+	fmt.Fprintf(&b, "  %s:\n", ProfileWorktreeZellij)
+	writeWorktree(&b, r, opts)
+	b.WriteString("    environment: docker\n")
+	writeDockerfileHint(&b, r)
+	b.WriteString("    launch: zellij\n")
+	b.WriteString("    zellij:\n")
+	b.WriteString("      layout: default\n\n")
+
+	b.WriteString("  # Claude Code in Docker, in the current checkout\n")
+	fmt.Fprintf(&b, "  %s:\n", ProfileClaude)
+	b.WriteString("    environment: docker\n")
+	writeDockerfileHint(&b, r)
+	b.WriteString("    launch: claude\n\n")
+
+	b.WriteString("  # A shell on the host, in a new worktree\n")
+	fmt.Fprintf(&b, "  %s:\n", ProfileShell)
+	writeWorktree(&b, r, opts)
+	b.WriteString("    environment: host\n")
+	b.WriteString("    launch: shell\n")
+	return b.Bytes()
+}
+
+func writeWorktree(b *bytes.Buffer, r Repo, opts Options) {
+	b.WriteString("    worktree:\n")
+	fmt.Fprintf(b, "      base: %s\n", opts.Base)
+	if r.WorktreesDir != "" {
+		fmt.Fprintf(b, "      dir: %s\n", r.WorktreesDir)
+	}
+	if opts.OnCreate != "" {
+		fmt.Fprintf(b, "      on-create: %s\n", strconv.Quote(opts.OnCreate))
+	}
+}
+
+// writeDockerfileHint points at the repository's own Dockerfile without
+// using it: aw's image must keep the default entrypoint, so a custom one has
+// to start from `aw default-dockerfile`.
+func writeDockerfileHint(b *bytes.Buffer, r Repo) {
+	switch {
+	case r.Dockerfile != "":
+		fmt.Fprintf(b, "    # This repository has its own Dockerfile (%s). To add its toolchain to the\n", r.Dockerfile)
+		b.WriteString("    # container, start from `aw default-dockerfile > Dockerfile.aw`, extend it, and set:\n")
+		b.WriteString("    # dockerfile: Dockerfile.aw\n")
+	case r.Devcontainer:
+		b.WriteString("    # This repository has a .devcontainer. To add its toolchain to the container,\n")
+		b.WriteString("    # start from `aw default-dockerfile > Dockerfile.aw`, extend it, and set:\n")
+		b.WriteString("    # dockerfile: Dockerfile.aw\n")
+	}
+}
@@ -0,0 +1,139 @@
+// Package scaffold inspects a repository and writes a starter
+// .agent-workspace.yml for `aw init`.
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileName is the file `aw init` writes at the repository root.
+const ConfigFileName = ".agent-workspace.yml"
+
+// defaultWorktreesDir is where aw puts worktrees unless worktree.dir is set.
+const defaultWorktreesDir = "worktrees"
+
+// PackageManager is a dependency manager detected in the repository.
+type PackageManager struct {
+	Name     string // e.g. "pnpm"
+	Marker   string // file that revealed it, e.g. "pnpm-lock.yaml"
+	Install  string // command that installs dependencies in a fresh checkout
+	Frontend bool   // JavaScript package managers; only the first one found is used
+}
+
+// packageManagers is checked in order. For JavaScript, lockfiles are listed
+// before package.json so the most specific manager wins.
+var packageManagers = []PackageManager{
+	{Name: "pnpm", Marker: "pnpm-lock.yaml", Install: "pnpm install --frozen-lockfile", Frontend: true},
+	{Name: "yarn", Marker: "yarn.lock", Install: "yarn install --frozen-lockfile", Frontend: true},
+	{Name: "bun", Marker: "bun.lockb", Install: "bun install --frozen-lockfile", Frontend: true},
+	{Name: "npm", Marker: "package-lock.json", Install: "npm ci", Frontend: true},
+	{Name: "npm", Marker: "package.json", Install: "npm install", Frontend: true},
+	{Name: "go", Marker: "go.mod", Install: "go mod download"},
+	{Name: "cargo", Marker: "Cargo.toml", Install: "cargo fetch"},
+	{Name: "uv", Marker: "uv.lock", Install: "uv sync"},
+	{Name: "poetry", Marker: "poetry.lock", Install: "poetry install"},
+	{Name: "pip", Marker: "requirements.txt", Install: "pip install -r requirements.txt"},
+	{Name: "bundler", Marker: "Gemfile", Install: "bundle install"},
+}
+
+// Repo is what Inspect learned about a repository.
+type Repo struct {
+	Root            string
+	DefaultBase     string           // e.g. "origin/main", from origin/HEAD
+	Dockerfile      string           // repo-relative path of an existing Dockerfile, if any
+	Devcontainer    bool             // .devcontainer/ exists
+	PackageManagers []PackageManager // in detection order
+	WorktreesDir    string           // directory for worktrees; "" for the default
+	ConfigExists    bool             // ConfigFileName already exists
+}
+
+// Inspect examines the repository at root.
+func Inspect(root string) (Repo, error) {
+	r := Repo{Root: root, DefaultBase: defaultBase(root)}
+
+	for _, name := range []string{"Dockerfile", ".devcontainer/Dockerfile"} {
+		if fileExists(filepath.Join(root, name)) {
+			r.Dockerfile = name
+			break
+		}
+	}
+	r.Devcontainer = dirExists(filepath.Join(root, ".devcontainer"))
+
+	frontend := false
+	for _, pm := range packageManagers {
+		if pm.Frontend && frontend {
+			continue
+		}
+		if fileExists(filepath.Join(root, pm.Marker)) {
+			r.PackageManagers = append(r.PackageManagers, pm)
+			frontend = frontend || pm.Frontend
+		}
+	}
+
+	// A worktrees/ directory that is part of the repository belongs to the
+	// project, not to aw; keep aw's worktrees out of it.
+	if dirExists(filepath.Join(root, defaultWorktreesDir)) {
+		out, err := exec.Command("git", "-C", root, "ls-files", "--", defaultWorktreesDir).Output()
+		if err != nil {
+			return Repo{}, fmt.Errorf("checking %s/: %w", defaultWorktreesDir, err)
+		}
+		if strings.TrimSpace(string(out)) != "" {
+			r.WorktreesDir = ".worktrees"
+		}
+	}
+
+	r.ConfigExists = fileExists(filepath.Join(root, ConfigFileName))
+	return r, nil
+}
+
+// InstallCommand returns the on-create command that installs the detected
+// dependencies, or "" if none were detected.
+func (r Repo) InstallCommand() string {
+	cmds := make([]string, len(r.PackageManagers))
+	for i, pm := range r.PackageManagers {
+		cmds[i] = pm.Install
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// GitignoreEntries returns the entries `aw init` adds to .gitignore: the
+// worktrees directory, the env files aw reads from a checkout and the
+// prompt file `aw --issue` writes.
+func (r Repo) GitignoreEntries() []string {
+	dir := r.WorktreesDir
+	if dir == "" {
+		dir = defaultWorktreesDir
+	}
+	return []string{"/" + dir + "/", ".aw-env", ".aw-profile-env", ".aw-prompt.md"}
+}
+
+// defaultBase returns the remote default branch, e.g. "origin/main", from
+// origin/HEAD, falling back to origin/main or origin/master if present.
+func defaultBase(root string) string {
+	out, err := exec.Command("git", "-C", root, "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	if err == nil {
+		if ref := strings.TrimSpace(string(out)); ref != "" {
+			return ref
+		}
+	}
+	for _, ref := range []string{"origin/main", "origin/master"} {
+		if exec.Command("git", "-C", root, "rev-parse", "--verify", "--quiet", "refs/remotes/"+ref).Run() == nil {
+			return ref
+		}
+	}
+	return "origin/main"
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
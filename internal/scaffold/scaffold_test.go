@@ -0,0 +1,175 @@
+package scaffold
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/profile"
+)
+
+// initRepo creates a git repository with one commit and the given files.
+func initRepo(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(t, dir, "init", "-q", "-b", "main")
+	run(t, dir, "add", "-A")
+	run(t, dir, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init")
+	return dir
+}
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := initRepo(t, "Dockerfile", ".devcontainer/devcontainer.json", "yarn.lock", "package.json", "go.mod")
+	run(t, dir, "update-ref", "refs/remotes/origin/develop", "HEAD")
+	run(t, dir, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/develop")
+
+	r, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if r.DefaultBase != "origin/develop" {
+		t.Errorf("DefaultBase = %q, want origin/develop", r.DefaultBase)
+	}
+	if r.Dockerfile != "Dockerfile" || !r.Devcontainer {
+		t.Errorf("Dockerfile = %q, Devcontainer = %v", r.Dockerfile, r.Devcontainer)
+	}
+	var names []string
+	for _, pm := range r.PackageManagers {
+		names = append(names, pm.Name)
+	}
+	if want := []string{"yarn", "go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("package managers = %v, want %v", names, want)
+	}
+	if got := r.InstallCommand(); got != "yarn install --frozen-lockfile && go mod download" {
+		t.Errorf("InstallCommand() = %q", got)
+	}
+	if r.WorktreesDir != "" || r.ConfigExists {
+		t.Errorf("WorktreesDir = %q, ConfigExists = %v, want defaults", r.WorktreesDir, r.ConfigExists)
+	}
+}
+
+func TestInspect_DefaultBaseFallback(t *testing.T) {
+	dir := initRepo(t)
+	r, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if r.DefaultBase != "origin/main" {
+		t.Errorf("DefaultBase without a remote = %q, want origin/main", r.DefaultBase)
+	}
+
+	run(t, dir, "update-ref", "refs/remotes/origin/master", "HEAD")
+	if r, _ = Inspect(dir); r.DefaultBase != "origin/master" {
+		t.Errorf("DefaultBase with only origin/master = %q, want origin/master", r.DefaultBase)
+	}
+}
+
+func TestInspect_TrackedWorktreesDir(t *testing.T) {
+	dir := initRepo(t, "worktrees/README.md")
+	r, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if r.WorktreesDir != ".worktrees" {
+		t.Errorf("WorktreesDir = %q, want .worktrees", r.WorktreesDir)
+	}
+	if got := r.GitignoreEntries()[0]; got != "/.worktrees/" {
+		t.Errorf("first gitignore entry = %q, want /.worktrees/", got)
+	}
+
+	// An untracked worktrees/ (e.g. from an earlier aw run) is aw's own.
+	dir = initRepo(t)
+	if err := os.Mkdir(filepath.Join(dir, "worktrees"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ = Inspect(dir); r.WorktreesDir != "" {
+		t.Errorf("WorktreesDir for untracked worktrees/ = %q, want default", r.WorktreesDir)
+	}
+}
+
+func TestRender_ValidConfig(t *testing.T) {
+	repos := []Repo{
+		{DefaultBase: "origin/main"},
+		{DefaultBase: "origin/trunk", Dockerfile: "Dockerfile", WorktreesDir: ".worktrees",
+			PackageManagers: []PackageManager{{Name: "npm", Install: `npm ci --prefix "web"`}}},
+	}
+	for _, r := range repos {
+		opts := DefaultOptions(r)
+		opts.Default = ProfileShell
+		data := Render(r, opts)
+
+		cfg, err := profile.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse(Render()) error: %v\n%s", err, data)
+		}
+		if err := profile.ValidateConfig(cfg); err != nil {
+			t.Errorf("ValidateConfig(Render()) error: %v\n%s", err, data)
+		}
+		if cfg.Default != ProfileShell {
+			t.Errorf("Default = %q, want %q", cfg.Default, ProfileShell)
+		}
+		for _, name := range Profiles {
+			if _, ok := cfg.Profiles[name]; !ok {
+				t.Errorf("missing profile %q", name)
+			}
+		}
+		wt := cfg.Profiles[ProfileWorktreeZellij].Worktree
+		if wt.Base != r.DefaultBase || wt.Dir != r.WorktreesDir || wt.OnCreate != r.InstallCommand() {
+			t.Errorf("worktree = %+v, want base %q, dir %q, on-create %q", wt, r.DefaultBase, r.WorktreesDir, r.InstallCommand())
+		}
+		if hint := strings.Contains(string(data), "# dockerfile: Dockerfile.aw"); hint != (r.Dockerfile != "") {
+			t.Errorf("dockerfile hint present = %v, want %v", hint, r.Dockerfile != "")
+		}
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	dir := t.TempDir()
+	entries := []string{"/worktrees/", ".aw-env", ".aw-profile-env"}
+
+	missing, err := MissingGitignoreEntries(dir, entries)
+	if err != nil || !reflect.DeepEqual(missing, entries) {
+		t.Fatalf("MissingGitignoreEntries() without .gitignore = %v, %v", missing, err)
+	}
+
+	path := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(path, []byte("node_modules\nworktrees"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing, err = MissingGitignoreEntries(dir, entries)
+	if err != nil || !reflect.DeepEqual(missing, entries[1:]) {
+		t.Fatalf("MissingGitignoreEntries() = %v, %v, want %v", missing, err, entries[1:])
+	}
+
+	if err := AppendGitignore(dir, missing); err != nil {
+		t.Fatalf("AppendGitignore() error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "node_modules\nworktrees\n\n# aw (agent-workspace)\n.aw-env\n.aw-profile-env\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+	if missing, _ = MissingGitignoreEntries(dir, entries); len(missing) != 0 {
+		t.Errorf("entries still missing after append: %v", missing)
+	}
+}
@@ -0,0 +1,30 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ProcessStart returns when process pid started, in clock ticks since boot
+// as /proc/<pid>/stat reports it. Together with the pid it identifies the
+// process: a later process that reuses the pid has a different start time.
+// exec keeps it, so it also identifies the program aw replaced itself with.
+func ProcessStart(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+	// The command name in parentheses may hold spaces; the fields after it
+	// start with the state, the 3rd field.
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return "", fmt.Errorf("parsing /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	const startTime = 22 - 3
+	if len(fields) <= startTime {
+		return "", fmt.Errorf("parsing /proc/%d/stat", pid)
+	}
+	return fields[startTime], nil
+}
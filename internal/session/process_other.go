@@ -0,0 +1,26 @@
+//go:build !linux
+
+package session
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ProcessStart returns when process pid started, as ps reports it. Together
+// with the pid it identifies the process: a later process that reuses the
+// pid has a different start time. exec keeps it, so it also identifies the
+// program aw replaced itself with.
+func ProcessStart(pid int) (string, error) {
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	start := strings.TrimSpace(string(out))
+	if start == "" {
+		return "", fmt.Errorf("no process %d", pid)
+	}
+	return start, nil
+}
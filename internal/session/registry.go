@@ -0,0 +1,193 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session records the resources created by a single `aw` run.
+type Session struct {
+	ID             string    `json:"id"`
+	Profile        string    `json:"profile"`
+	Environment    string    `json:"environment"`
+	Launch         string    `json:"launch"`
+	WorkDir        string    `json:"work_dir"`
+	RepoRoot       string    `json:"repo_root,omitempty"`
+	WorktreePath   string    `json:"worktree_path,omitempty"`
+	WorktreeBranch string    `json:"worktree_branch,omitempty"`
+	WorktreeBase   string    `json:"worktree_base,omitempty"`
+	Image          string    `json:"image,omitempty"`
+	Container      string    `json:"container,omitempty"`      // docker container name (usable wherever docker expects an ID)
+	DockerClient   string    `json:"docker_client,omitempty"`  // "cli" or "api"; how to reach the container
+	Runtime        string    `json:"runtime,omitempty"`        // "docker" or "podman"; the engine running the container
+	ZellijSession  string    `json:"zellij_session,omitempty"` // empty unless launch: zellij
+	PID            int       `json:"pid"`                      // pid of the aw process that started the session
+	PIDStart       string    `json:"pid_start,omitempty"`      // ProcessStart(PID), to tell that process from a later one with the same pid
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Registry stores one JSON file per session in Dir.
+type Registry struct {
+	Dir string
+}
+
+// NewRegistry creates a Registry rooted at dir.
+func NewRegistry(dir string) *Registry {
+	return &Registry{Dir: dir}
+}
+
+// DefaultDir returns the directory sessions are stored in:
+// $XDG_STATE_HOME/agent-workspace/sessions, falling back to
+// <homeDir>/.local/state/agent-workspace/sessions.
+//
+// This deliberately lives outside ~/.agent-workspace, which is mounted into
+// containers as the Claude home directory.
+func DefaultDir(homeDir string) string {
+	if v := os.Getenv("XDG_STATE_HOME"); v != "" {
+		return filepath.Join(v, "agent-workspace", "sessions")
+	}
+	return filepath.Join(homeDir, ".local", "state", "agent-workspace", "sessions")
+}
+
+// NewID returns a short random session identifier.
+func NewID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *Registry) path(id string) string {
+	return filepath.Join(r.Dir, id+".json")
+}
+
+// LogPath returns the path of the event log for session id. Logs are kept
+// after the session record is removed.
+func (r *Registry) LogPath(id string) string {
+	return filepath.Join(r.Dir, id+".log")
+}
+
+// Save writes the session record, replacing any existing record with the same ID.
+func (r *Registry) Save(s Session) error {
+	if s.ID == "" {
+		return fmt.Errorf("session id is empty")
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return fmt.Errorf("creating session directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding session: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never see a partial record.
+	tmp, err := os.CreateTemp(r.Dir, s.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.path(s.ID)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing session: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the session record. Removing a missing record is not an error.
+func (r *Registry) Remove(id string) error {
+	if err := os.Remove(r.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing session %s: %w", id, err)
+	}
+	return nil
+}
+
+// List returns all recorded sessions, oldest first.
+// A missing registry directory yields an empty list.
+func (r *Registry) List() ([]Session, error) {
+	entries, err := os.ReadDir(r.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading session directory: %w", err)
+	}
+
+	var sessions []Session
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.Dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading session %s: %w", e.Name(), err)
+		}
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("parsing session %s: %w", e.Name(), err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// Find looks up a session by exact ID, zellij session name, worktree branch,
+// or unique ID prefix (in that order of precedence).
+func (r *Registry) Find(ref string) (Session, error) {
+	if ref == "" {
+		return Session{}, fmt.Errorf("session reference is empty")
+	}
+
+	sessions, err := r.List()
+	if err != nil {
+		return Session{}, err
+	}
+
+	for _, s := range sessions {
+		if s.ID == ref {
+			return s, nil
+		}
+	}
+	for _, s := range sessions {
+		if (s.ZellijSession != "" && s.ZellijSession == ref) ||
+			(s.WorktreeBranch != "" && s.WorktreeBranch == ref) {
+			return s, nil
+		}
+	}
+
+	var matches []Session
+	for _, s := range sessions {
+		if strings.HasPrefix(s.ID, ref) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Session{}, fmt.Errorf("session %q not found", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return Session{}, fmt.Errorf("session %q is ambiguous (%d matches)", ref, len(matches))
+	}
+}
@@ -0,0 +1,174 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_SaveListRemove(t *testing.T) {
+	r := NewRegistry(filepath.Join(t.TempDir(), "sessions"))
+
+	now := time.Now()
+	older := Session{ID: "aaaa1111", Profile: "claude", CreatedAt: now.Add(-time.Hour)}
+	newer := Session{ID: "bbbb2222", Profile: "worktree-zellij", ZellijSession: "red-fox-sky", CreatedAt: now}
+
+	for _, s := range []Session{newer, older} {
+		if err := r.Save(s); err != nil {
+			t.Fatalf("Save(%s) error: %v", s.ID, err)
+		}
+	}
+
+	got, err := r.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("List() returned %d sessions, want 2", len(got))
+	}
+	if got[0].ID != older.ID || got[1].ID != newer.ID {
+		t.Errorf("List() order = [%s %s], want oldest first", got[0].ID, got[1].ID)
+	}
+	if got[1].ZellijSession != "red-fox-sky" {
+		t.Errorf("ZellijSession = %q, want %q", got[1].ZellijSession, "red-fox-sky")
+	}
+
+	if err := r.Remove(older.ID); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	got, err = r.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != newer.ID {
+		t.Errorf("List() after Remove = %v, want only %s", got, newer.ID)
+	}
+}
+
+func TestRegistry_ListMissingDir(t *testing.T) {
+	r := NewRegistry(filepath.Join(t.TempDir(), "does-not-exist"))
+	got, err := r.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("List() = %v, want empty", got)
+	}
+}
+
+func TestRegistry_RemoveMissing(t *testing.T) {
+	r := NewRegistry(t.TempDir())
+	if err := r.Remove("nope"); err != nil {
+		t.Errorf("Remove() of missing session should not error, got: %v", err)
+	}
+}
+
+func TestRegistry_SaveRejectsEmptyID(t *testing.T) {
+	r := NewRegistry(t.TempDir())
+	if err := r.Save(Session{}); err == nil {
+		t.Error("Save() with empty ID should error")
+	}
+}
+
+func TestRegistry_ListIgnoresNonJSON(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry(dir)
+	if err := r.Save(Session{ID: "abcd0000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "abcd0000.1234.tmp"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("List() returned %d sessions, want 1", len(got))
+	}
+}
+
+func TestRegistry_Find(t *testing.T) {
+	r := NewRegistry(t.TempDir())
+	sessions := []Session{
+		{ID: "abc12345", WorktreeBranch: "tidy-owl-nap", ZellijSession: "tidy-owl-nap"},
+		{ID: "abd67890", ZellijSession: "claude"},
+		{ID: "ffff0000", WorktreeBranch: "feature-x"},
+	}
+	for _, s := range sessions {
+		if err := r.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ref     string
+		wantID  string
+		wantErr string
+	}{
+		{"abc12345", "abc12345", ""},
+		{"tidy-owl-nap", "abc12345", ""},
+		{"claude", "abd67890", ""},
+		{"feature-x", "ffff0000", ""},
+		{"ff", "ffff0000", ""},
+		{"ab", "", "ambiguous"},
+		{"zzz", "", "not found"},
+		{"", "", "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := r.Find(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Find(%q) error = %v, want containing %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find(%q) error: %v", tt.ref, err)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("Find(%q) = %s, want %s", tt.ref, got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestDefaultDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "")
+	if got := DefaultDir("/home/u"); got != "/home/u/.local/state/agent-workspace/sessions" {
+		t.Errorf("DefaultDir() = %q", got)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	if got := DefaultDir("/home/u"); got != "/xdg/state/agent-workspace/sessions" {
+		t.Errorf("DefaultDir() with XDG_STATE_HOME = %q", got)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	id, err := NewID()
+	if err != nil {
+		t.Fatalf("NewID() error: %v", err)
+	}
+	if len(id) != 8 {
+		t.Errorf("NewID() = %q, want 8 hex chars", id)
+	}
+}
+
+func TestProcessStart(t *testing.T) {
+	self, err := ProcessStart(os.Getpid())
+	if err != nil || self == "" {
+		t.Fatalf("ProcessStart(self) = %q, %v", self, err)
+	}
+	if again, _ := ProcessStart(os.Getpid()); again != self {
+		t.Errorf("ProcessStart(self) = %q, then %q", self, again)
+	}
+	if _, err := ProcessStart(1 << 30); err == nil {
+		t.Error("ProcessStart() of a missing process succeeded")
+	}
+}
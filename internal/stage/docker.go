@@ -7,13 +7,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hiragram/agent-workspace/internal/config"
 	"github.com/hiragram/agent-workspace/internal/docker"
 	"github.com/hiragram/agent-workspace/internal/image"
 	"github.com/hiragram/agent-workspace/internal/mount"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/worktree"
 )
 
 const (
@@ -44,45 +48,53 @@ func (s *DockerStage) Run(ctx context.Context, ec *pipeline.ExecutionContext) er
 	if err := s.DockerClient.CheckAvailable(); err != nil {
 		return fmt.Errorf("docker is not available: %w", err)
 	}
-
-	// 2. Resolve custom Dockerfile path
-	customDockerfile := ""
-	if ec.Profile.Dockerfile != "" {
-		resolved, err := resolveDockerfilePath(ec.Profile.Dockerfile)
-		if err != nil {
-			return fmt.Errorf("resolving dockerfile path: %w", err)
+	if rt := s.DockerClient.RuntimeInfo(); rt.Name == docker.RuntimePodman {
+		mode := "rootful"
+		if rt.Rootless {
+			mode = "rootless"
 		}
-		customDockerfile = resolved
+		ec.Logf("Using podman (%s)", mode)
 	}
 
-	// 3. Build Docker image
-	buildDir, cleanup, err := image.PrepareBuildContext(customDockerfile)
-	if err != nil {
-		return fmt.Errorf("preparing build context: %w", err)
-	}
-	defer cleanup()
-
-	// Compute image tag from Dockerfile content hash to bust Docker cache
-	// when the Dockerfile changes.
-	imageName := defaultImageName
-	if dfBytes, err := os.ReadFile(filepath.Join(buildDir, "Dockerfile")); err == nil {
-		hash := fmt.Sprintf("%x", sha256.Sum256(dfBytes))[:12]
-		imageName = fmt.Sprintf("%s:%s", defaultImageName, hash)
-	}
-
-	if customDockerfile != "" {
-		fmt.Fprintf(os.Stderr, "Building Docker image '%s' (custom Dockerfile: %s)...\n", imageName, ec.Profile.Dockerfile)
+	// 2. Pull the profile's image, or build one unless an image of the same
+	// build context exists
+	var imageName string
+	if ec.Profile.Image != "" {
+		if err := s.pullImage(ctx, ec); err != nil {
+			return err
+		}
+		imageName = ec.Profile.Image
 	} else {
-		fmt.Fprintf(os.Stderr, "Building Docker image '%s'...\n", imageName)
-	}
-	if err := s.DockerClient.Build(ctx, imageName, buildDir); err != nil {
-		return fmt.Errorf("building image: %w", err)
+		img, err := prepareImage(ctx, ec.Profile)
+		if err != nil {
+			return err
+		}
+		exists, err := s.DockerClient.ImageExists(ctx, img.name)
+		if err != nil {
+			ec.Warnf("checking for image %s: %v", img.name, err)
+		}
+		if exists {
+			ec.Logf("Using Docker image '%s' (already built; `aw image rebuild` to rebuild it)", img.name)
+			ec.Emit(pipeline.Event{Kind: pipeline.ImageCached, Fields: map[string]string{"image": img.name}})
+		} else if err := buildImage(ctx, ec, s.DockerClient, img); err != nil {
+			return err
+		}
+		imageName = img.name
 	}
 
 	// 3. Create Docker volume
 	if err := s.DockerClient.VolumeCreate(ctx, defaultVolumeName); err != nil {
 		return fmt.Errorf("creating volume: %w", err)
 	}
+	ec.Emit(pipeline.Event{Kind: pipeline.VolumeCreated, Fields: map[string]string{"volume": defaultVolumeName}})
+
+	// A prebuilt image was not made from our entrypoint.sh, so check that it
+	// behaves like it. This needs the volume, where Claude Code is installed.
+	if ec.Profile.Image != "" {
+		if err := verifyImageContract(ctx, s.DockerClient, imageName); err != nil {
+			return err
+		}
+	}
 
 	// 4. Sync host settings
 	claudeHome := claudeHomePath(ec.HomeDir)
@@ -99,35 +111,348 @@ func (s *DockerStage) Run(ctx context.Context, ec *pipeline.ExecutionContext) er
 	}
 
 	// 6. Build mounts
-	mounts, err := s.MountBuilder.BuildMounts(mount.MountOptions{
-		HomeDir:             ec.HomeDir,
-		WorkDir:             ec.WorkDir,
-		ClaudeHome:          claudeHome,
-		ContainerClaudeHome: containerClaudeHome,
-		ContainerClaudeJSON: containerClaudeJSON,
-		VolumeName:          defaultVolumeName,
-	})
+	mounts, err := s.buildMounts(ec, claudeHome)
+	if err != nil {
+		return fmt.Errorf("building mounts: %w", err)
+	}
+	links, err := linkMounts(ec)
 	if err != nil {
 		return fmt.Errorf("building mounts: %w", err)
 	}
+	mounts = append(mounts, links...)
 
 	// 7. Update execution context
 	ec.DockerImage = imageName
 	ec.DockerMounts = mounts
 	ec.DockerVolume = defaultVolumeName
+	ec.DockerClient = s.DockerClient
+	ec.DockerRuntime = s.DockerClient.RuntimeInfo()
+
+	return nil
+}
+
+// Plan describes the image Run would build and the mounts the container
+// would get. An unreachable runtime is reported but does not fail the plan.
+func (s *DockerStage) Plan(ctx context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	var actions []pipeline.Action
+	if err := s.DockerClient.CheckAvailable(); err != nil {
+		actions = append(actions, pipeline.Action{Description: "warning: docker is not available: " + err.Error()})
+	}
+	runtime := s.DockerClient.RuntimeInfo()
+
+	var image pipeline.Action
+	imageName := ec.Profile.Image
+	if imageName != "" {
+		image = s.planPull(ctx, ec, runtime)
+	} else {
+		img, err := prepareImage(ctx, ec.Profile)
+		if err != nil {
+			return nil, err
+		}
+
+		// Show build args as configured: interpolated, they may hold secrets.
+		opts := img.opts
+		if opts.Args != nil {
+			opts.Args = ec.Profile.Docker.Build.Args
+		}
+		build := pipeline.Action{
+			Description: fmt.Sprintf("build image %s from %s", img.name, img.source),
+			Command:     append([]string{runtime.Command()}, docker.BuildArgs(img.name, "<build context>", opts)...),
+		}
+		if exists, err := s.DockerClient.ImageExists(ctx, img.name); err == nil && exists {
+			build = pipeline.Action{Description: fmt.Sprintf("use image %s (already built from %s)", img.name, img.source)}
+		}
+		image = build
+		imageName = img.name
+	}
+
+	claudeHome := claudeHomePath(ec.HomeDir)
+	actions = append(actions,
+		image,
+		pipeline.Action{
+			Description: "create volume " + defaultVolumeName,
+			Command:     []string{runtime.Command(), "volume", "create", defaultVolumeName},
+		},
+	)
+	if ec.Profile.Image != "" {
+		actions = append(actions, pipeline.Action{
+			Description: fmt.Sprintf("check that %s keeps the entrypoint contract (runs as the claude user, links HOST_CLAUDE_HOME)", imageName),
+		})
+	}
+	actions = append(actions,
+		pipeline.Action{Description: fmt.Sprintf("sync settings from %s to %s", claudeHome, filepath.Join(ec.HomeDir, ".agent-workspace"))},
+		pipeline.Action{Description: "ensure onboarding state in " + filepath.Join(ec.HomeDir, ".agent-workspace.json")},
+	)
+
+	mounts, err := s.buildMounts(ec, claudeHome)
+	if err != nil {
+		return nil, fmt.Errorf("building mounts: %w", err)
+	}
+	links, err := linkMounts(ec)
+	if err != nil {
+		return nil, fmt.Errorf("building mounts: %w", err)
+	}
+	mounts = append(mounts, links...)
+	// A worktree that is only planned has no .git file yet, so the mount
+	// builder cannot see that it needs the main repository's .git.
+	if ec.WorktreePath != "" && ec.RepoRoot != "" {
+		if _, err := os.Lstat(filepath.Join(ec.WorktreePath, ".git")); err != nil {
+			gitDir := filepath.Join(ec.RepoRoot, ".git")
+			if !mount.IsSubpath(ec.WorkDir, gitDir) {
+				mounts = append(mounts, docker.Mount{Source: gitDir, Target: gitDir})
+			}
+		}
+	}
+
+	ec.DockerImage = imageName
+	ec.DockerMounts = mounts
+	ec.DockerVolume = defaultVolumeName
+	ec.DockerClient = s.DockerClient
+	ec.DockerRuntime = runtime
+	return actions, nil
+}
+
+func (s *DockerStage) buildMounts(ec *pipeline.ExecutionContext, claudeHome string) ([]docker.Mount, error) {
+	return s.MountBuilder.BuildMounts(mount.MountOptions{
+		HomeDir:             ec.HomeDir,
+		WorkDir:             ec.WorkDir,
+		ClaudeHome:          claudeHome,
+		ContainerClaudeHome: filepath.Join(ec.HomeDir, ".agent-workspace"),
+		ContainerClaudeJSON: filepath.Join(ec.HomeDir, ".agent-workspace.json"),
+		VolumeName:          defaultVolumeName,
+	})
+}
+
+// linkMounts mounts the targets of the symlinks worktree.copy entries with
+// link: true put in the worktree. The links point into the main repository,
+// which is not mounted otherwise, so they would dangle in the container.
+func linkMounts(ec *pipeline.ExecutionContext) ([]docker.Mount, error) {
+	if ec.WorktreePath == "" || ec.RepoRoot == "" || ec.Profile.Worktree == nil {
+		return nil, nil
+	}
+	var mounts []docker.Mount
+	for _, c := range ec.Profile.Worktree.Copy {
+		if !c.Link {
+			continue
+		}
+		sources, err := worktree.LinkSources(ec.RepoRoot, ec.WorktreePath, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range sources {
+			if !mount.IsSubpath(ec.WorkDir, src) {
+				mounts = append(mounts, docker.Mount{Source: src, Target: src})
+			}
+		}
+	}
+	return mounts, nil
+}
 
+// pullImage makes the prebuilt image of the profile available according to
+// its pull policy.
+func (s *DockerStage) pullImage(ctx context.Context, ec *pipeline.ExecutionContext) error {
+	imageName := ec.Profile.Image
+	start := time.Now()
+	pulled, err := docker.EnsureImage(ctx, s.DockerClient, imageName, string(ec.Profile.Docker.EffectivePull()))
+	if err != nil {
+		return err
+	}
+	if !pulled {
+		ec.Logf("Using Docker image '%s'", imageName)
+		ec.Emit(pipeline.Event{Kind: pipeline.ImageCached, Fields: map[string]string{"image": imageName}})
+		return nil
+	}
+	ec.Logf("Pulled Docker image '%s'", imageName)
+	ec.Emit(pipeline.Event{Kind: pipeline.ImagePulled, Duration: time.Since(start), Fields: map[string]string{"image": imageName}})
 	return nil
 }
 
+// planPull describes what pullImage would do.
+func (s *DockerStage) planPull(ctx context.Context, ec *pipeline.ExecutionContext, runtime docker.RuntimeInfo) pipeline.Action {
+	imageName, policy := ec.Profile.Image, ec.Profile.Docker.EffectivePull()
+	pull := pipeline.Action{
+		Description: fmt.Sprintf("pull image %s (pull: %s)", imageName, policy),
+		Command:     []string{runtime.Command(), "pull", imageName},
+	}
+	if policy != profile.DockerPullAlways {
+		exists, err := s.DockerClient.ImageExists(ctx, imageName)
+		switch {
+		case err == nil && exists:
+			pull = pipeline.Action{Description: fmt.Sprintf("use image %s (present locally)", imageName)}
+		case policy == profile.DockerPullNever:
+			pull = pipeline.Action{Description: fmt.Sprintf("use image %s, which must be present locally (pull: never)", imageName)}
+		}
+	}
+	return pull
+}
+
+// contractClaudeHome is the HOST_CLAUDE_HOME verifyImageContract passes to
+// the entrypoint; any path other than /home/claude/.claude will do.
+const contractClaudeHome = "/tmp/aw-contract-check/.claude"
+
+// verifyImageContract checks that a prebuilt image keeps the contract that
+// aw's containers rely on, as the embedded entrypoint.sh does: the
+// entrypoint starts as root, links HOST_CLAUDE_HOME to /home/claude/.claude,
+// so that host paths in the synced settings resolve, and runs the command
+// as the claude user. It runs the image once with a probe command.
+func verifyImageContract(ctx context.Context, client docker.Client, imageName string) error {
+	out, err := client.Output(ctx, docker.RunConfig{
+		ImageName: imageName,
+		Mounts:    []docker.Mount{{Source: defaultVolumeName, Target: "/home/claude/.local", IsVolume: true}},
+		EnvVars:   map[string]string{"HOST_CLAUDE_HOME": contractClaudeHome},
+		Command:   []string{"sh", "-c", `id -un; readlink "$HOST_CLAUDE_HOME"`},
+	})
+	if err != nil {
+		return fmt.Errorf("image %s does not keep aw's entrypoint contract: %w", imageName, err)
+	}
+	// The entrypoint may print more first, e.g. while installing Claude Code.
+	fields := strings.Fields(out)
+	if n := len(fields); n < 2 || fields[n-2] != "claude" || fields[n-1] != "/home/claude/.claude" {
+		return fmt.Errorf("image %s does not keep aw's entrypoint contract: its entrypoint must run the command as the claude user "+
+			"after linking $HOST_CLAUDE_HOME to /home/claude/.claude (see `aw default-dockerfile`), but the probe printed %q", imageName, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// preparedImage is the build context of a profile's image, ready to build.
+type preparedImage struct {
+	name    string              // image name, tagged with the hash of the build context and options
+	context *image.BuildContext // files sent to the build
+	opts    docker.BuildOptions // from docker.build
+	source  string              // where the Dockerfile came from, for messages
+}
+
+// prepareImage lists the build context of p's image and names the image
+// after it. The context is hashed where it is; it is only copied to build.
+func prepareImage(ctx context.Context, p profile.Profile) (preparedImage, error) {
+	customDockerfile := ""
+	source := "embedded Dockerfile"
+	if p.Dockerfile != "" {
+		resolved, err := resolveDockerfilePath(ctx, p.Dockerfile)
+		if err != nil {
+			return preparedImage{}, fmt.Errorf("resolving dockerfile path: %w", err)
+		}
+		customDockerfile, source = resolved, "custom Dockerfile "+p.Dockerfile
+	}
+
+	var opts docker.BuildOptions
+	contextDir := ""
+	var skip []string
+	if p.Docker != nil && p.Docker.Build != nil {
+		b := p.Docker.Build
+		if b.Context != "" {
+			// The context is relative to the repository root, like the
+			// Dockerfile.
+			resolved, err := resolveDockerfilePath(ctx, b.Context)
+			if err != nil {
+				return preparedImage{}, fmt.Errorf("resolving docker.build.context: %w", err)
+			}
+			contextDir = resolved
+			source += " with context " + b.Context
+			// New worktrees must not change the context, e.g. when it is
+			// the repository root.
+			if repoRoot, err := gitRepoRoot(ctx); err == nil {
+				dir := ""
+				if p.Worktree != nil {
+					dir = p.Worktree.Dir
+				}
+				if worktrees, err := worktree.ResolveDir(dir, repoRoot); err == nil {
+					skip = append(skip, worktrees)
+				}
+			}
+		}
+		if len(b.Args) > 0 {
+			opts.Args = make(map[string]string, len(b.Args))
+			for k, v := range b.Args {
+				opts.Args[k] = os.ExpandEnv(v)
+			}
+		}
+		opts.Target, opts.Platform = b.Target, b.Platform
+	}
+	var features image.Features
+	if p.Docker != nil && p.Docker.Features != nil {
+		f := p.Docker.Features
+		features = image.Features{Go: f.Go, Rust: f.Rust, Python: f.Python, Apt: f.Apt}
+		if !features.IsZero() {
+			source += " with features " + features.String()
+		}
+	}
+
+	buildContext, err := image.NewBuildContext(customDockerfile, contextDir, skip, features)
+	if err != nil {
+		return preparedImage{}, fmt.Errorf("preparing build context: %w", err)
+	}
+	name, err := imageTag(buildContext, opts)
+	if err != nil {
+		return preparedImage{}, err
+	}
+	return preparedImage{name: name, context: buildContext, opts: opts, source: source}, nil
+}
+
+// buildImage builds img and reports it.
+func buildImage(ctx context.Context, ec *pipeline.ExecutionContext, client docker.Client, img preparedImage) error {
+	ec.Logf("Building Docker image '%s' (%s)...", img.name, img.source)
+	start := time.Now()
+	dir, cleanup, err := img.context.Prepare()
+	if err != nil {
+		return fmt.Errorf("preparing build context: %w", err)
+	}
+	defer cleanup()
+	if err := client.Build(ctx, img.name, dir, img.opts); err != nil {
+		return fmt.Errorf("building image: %w", err)
+	}
+	ec.Emit(pipeline.Event{Kind: pipeline.ImageBuilt, Duration: time.Since(start), Fields: map[string]string{"image": img.name}})
+	return nil
+}
+
+// RebuildImage builds the image of the docker profile in ec even if it
+// exists, as `aw image rebuild` does, and returns its name. With noCache,
+// no cached layers are used either, e.g. to pick up newer packages.
+func RebuildImage(ctx context.Context, ec *pipeline.ExecutionContext, client docker.Client, noCache bool) (string, error) {
+	if err := client.CheckAvailable(); err != nil {
+		return "", fmt.Errorf("docker is not available: %w", err)
+	}
+	img, err := prepareImage(ctx, ec.Profile)
+	if err != nil {
+		return "", err
+	}
+	img.opts.NoCache = noCache
+	return img.name, buildImage(ctx, ec, client, img)
+}
+
+// imageTag names the image built from c with opts. The tag is a hash of
+// both, so editing the Dockerfile, any file it copies or a build arg busts
+// the cache.
+func imageTag(c *image.BuildContext, opts docker.BuildOptions) (string, error) {
+	hash, err := c.Hash()
+	if err != nil {
+		return "", err
+	}
+	if len(opts.Args) > 0 || opts.Target != "" || opts.Platform != "" {
+		keys := make([]string, 0, len(opts.Args))
+		for k := range opts.Args {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\ntarget %s\nplatform %s\n", hash, opts.Target, opts.Platform)
+		for _, k := range keys {
+			fmt.Fprintf(h, "arg %s=%s\n", k, opts.Args[k])
+		}
+		hash = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return fmt.Sprintf("%s:%s", defaultImageName, hash[:12]), nil
+}
+
 // resolveDockerfilePath resolves a Dockerfile path.
 // If the path is absolute, it is returned as-is.
 // If relative, it is resolved against the git repo root.
-func resolveDockerfilePath(dockerfilePath string) (string, error) {
+func resolveDockerfilePath(ctx context.Context, dockerfilePath string) (string, error) {
 	if filepath.IsAbs(dockerfilePath) {
 		return dockerfilePath, nil
 	}
 
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("finding git root to resolve dockerfile path: %w", err)
@@ -3,10 +3,15 @@ package stage
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/image"
 	"github.com/hiragram/agent-workspace/internal/mount"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
@@ -14,10 +19,18 @@ import (
 
 type mockDockerClient struct {
 	available    bool
+	imageExists  bool
 	buildCalled  bool
+	buildOpts    docker.BuildOptions
+	buildFiles   []string // in the build context, while building
 	volumeCalled bool
 	runCalled    bool
 	runConfig    docker.RunConfig
+	runErr       error
+	runtime      docker.RuntimeInfo
+	pullCalled   bool
+	output       string
+	outputConfig docker.RunConfig
 }
 
 func (m *mockDockerClient) CheckAvailable() error {
@@ -27,8 +40,22 @@ func (m *mockDockerClient) CheckAvailable() error {
 	return nil
 }
 
-func (m *mockDockerClient) Build(_ context.Context, _, _ string) error {
+func (m *mockDockerClient) Build(_ context.Context, _, contextDir string, opts docker.BuildOptions) error {
 	m.buildCalled = true
+	m.buildOpts = opts
+	entries, _ := os.ReadDir(contextDir)
+	for _, e := range entries {
+		m.buildFiles = append(m.buildFiles, e.Name())
+	}
+	return nil
+}
+
+func (m *mockDockerClient) ImageExists(_ context.Context, _ string) (bool, error) {
+	return m.imageExists, nil
+}
+
+func (m *mockDockerClient) Pull(_ context.Context, _ string) error {
+	m.pullCalled = true
 	return nil
 }
 
@@ -40,9 +67,26 @@ func (m *mockDockerClient) VolumeCreate(_ context.Context, _ string) error {
 func (m *mockDockerClient) Run(_ context.Context, config docker.RunConfig) error {
 	m.runCalled = true
 	m.runConfig = config
+	return m.runErr
+}
+
+func (m *mockDockerClient) Output(_ context.Context, config docker.RunConfig) (string, error) {
+	m.outputConfig = config
+	return m.output, nil
+}
+
+func (m *mockDockerClient) Stop(_ context.Context, _ string) error {
 	return nil
 }
 
+func (m *mockDockerClient) Attach(_ context.Context, _ string) error {
+	return nil
+}
+
+func (m *mockDockerClient) RuntimeInfo() docker.RuntimeInfo {
+	return m.runtime
+}
+
 type mockConfigSyncer struct {
 	syncCalled      bool
 	onboardCalled   bool
@@ -71,7 +115,7 @@ func (m *mockMountBuilder) BuildMounts(_ mount.MountOptions) ([]docker.Mount, er
 
 func TestResolveDockerfilePath_Absolute(t *testing.T) {
 	absPath := "/absolute/path/Dockerfile"
-	resolved, err := resolveDockerfilePath(absPath)
+	resolved, err := resolveDockerfilePath(context.Background(), absPath)
 	if err != nil {
 		t.Fatalf("resolveDockerfilePath() error: %v", err)
 	}
@@ -121,3 +165,346 @@ func TestDockerStage_NewDockerStage(t *testing.T) {
 		t.Error("MountBuilder should not be nil")
 	}
 }
+
+func TestDockerStage_PlanHasNoSideEffects(t *testing.T) {
+	client := &mockDockerClient{available: false}
+	syncer := &mockConfigSyncer{}
+	s := &DockerStage{
+		DockerClient: client,
+		ConfigSyncer: syncer,
+		MountBuilder: &mockMountBuilder{mounts: []docker.Mount{{Source: "/repo/worktrees/a", Target: "/repo/worktrees/a"}}},
+	}
+
+	// The worktree is only planned, so it has no .git file yet.
+	ec := &pipeline.ExecutionContext{
+		Profile:      profile.Profile{Environment: profile.EnvironmentDocker},
+		HomeDir:      "/home/test",
+		WorkDir:      "/repo/worktrees/a",
+		WorktreePath: "/repo/worktrees/a",
+		RepoRoot:     "/repo",
+	}
+
+	actions, err := s.Plan(context.Background(), ec)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if client.buildCalled || client.volumeCalled || syncer.syncCalled || syncer.onboardCalled {
+		t.Error("Plan() must not build, create volumes or sync settings")
+	}
+	if !strings.HasPrefix(actions[0].Description, "warning: docker is not available") {
+		t.Errorf("actions[0] = %q, want a warning about docker", actions[0].Description)
+	}
+
+	img, err := prepareImage(context.Background(), ec.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantImage := img.name
+	if ec.DockerImage != wantImage {
+		t.Errorf("DockerImage = %q, want %q", ec.DockerImage, wantImage)
+	}
+	if got := strings.Join(actions[1].Command, " "); got != "docker build -t "+wantImage+" <build context>" {
+		t.Errorf("build command = %q", got)
+	}
+	want := []docker.Mount{
+		{Source: "/repo/worktrees/a", Target: "/repo/worktrees/a"},
+		{Source: "/repo/.git", Target: "/repo/.git"},
+	}
+	if fmt.Sprint(ec.DockerMounts) != fmt.Sprint(want) {
+		t.Errorf("DockerMounts = %v, want %v", ec.DockerMounts, want)
+	}
+}
+
+func TestDockerStage_RunReusesExistingImage(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		client := &mockDockerClient{available: true, imageExists: exists}
+		s := &DockerStage{
+			DockerClient: client,
+			ConfigSyncer: &mockConfigSyncer{},
+			MountBuilder: &mockMountBuilder{},
+		}
+		var kinds []pipeline.EventKind
+		ec := &pipeline.ExecutionContext{
+			Profile:  profile.Profile{Environment: profile.EnvironmentDocker},
+			HomeDir:  t.TempDir(),
+			WorkDir:  "/workspace",
+			Observer: observerFunc(func(e pipeline.Event) { kinds = append(kinds, e.Kind) }),
+		}
+
+		if err := s.Run(context.Background(), ec); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		if client.buildCalled == exists {
+			t.Errorf("image exists = %v: buildCalled = %v", exists, client.buildCalled)
+		}
+		if !exists && strings.Join(client.buildFiles, ",") != "Dockerfile,entrypoint.sh" {
+			t.Errorf("build context = %v, want Dockerfile and entrypoint.sh", client.buildFiles)
+		}
+		want := pipeline.ImageBuilt
+		if exists {
+			want = pipeline.ImageCached
+		}
+		if !slices.Contains(kinds, want) {
+			t.Errorf("image exists = %v: events = %v, want %s", exists, kinds, want)
+		}
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	c, err := image.NewBuildContext("", "", nil, image.Features{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := imageTag(c, docker.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := c.Hash()
+	if tag != defaultImageName+":"+hash[:12] {
+		t.Errorf("imageTag() = %q, want %s:%s", tag, defaultImageName, hash[:12])
+	}
+}
+
+func TestPrepareImage_Features(t *testing.T) {
+	plain, err := prepareImage(context.Background(), profile.Profile{Environment: profile.EnvironmentDocker})
+	if err != nil {
+		t.Fatalf("prepareImage() error: %v", err)
+	}
+
+	p := profile.Profile{
+		Environment: profile.EnvironmentDocker,
+		Docker:      &profile.DockerConfig{Features: &profile.DockerFeatures{Go: "1.24", Apt: []string{"jq"}}},
+	}
+	img, err := prepareImage(context.Background(), p)
+	if err != nil {
+		t.Fatalf("prepareImage() error: %v", err)
+	}
+	if img.name == plain.name {
+		t.Errorf("tag %s did not change with the features", img.name)
+	}
+	if img.source != "embedded Dockerfile with features go 1.24, apt jq" {
+		t.Errorf("source = %q", img.source)
+	}
+
+	// The same features give the same tag, so the image is reused.
+	again, err := prepareImage(context.Background(), p)
+	if err != nil {
+		t.Fatalf("prepareImage() error: %v", err)
+	}
+	if again.name != img.name {
+		t.Errorf("tag = %s, then %s", img.name, again.name)
+	}
+}
+
+func TestDockerStage_BuildOptions(t *testing.T) {
+	contextDir := t.TempDir()
+	dockerfile := filepath.Join(contextDir, "Dockerfile")
+	if err := os.WriteFile(dockerfile, []byte("FROM alpine\nARG TOKEN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AW_TEST_TOKEN", "s3cret")
+	p := profile.Profile{
+		Environment: profile.EnvironmentDocker,
+		Dockerfile:  dockerfile,
+		Docker: &profile.DockerConfig{Build: &profile.DockerBuild{
+			Context:  contextDir,
+			Args:     map[string]string{"TOKEN": "${AW_TEST_TOKEN}"},
+			Platform: "linux/amd64",
+		}},
+	}
+
+	img, err := prepareImage(context.Background(), p)
+	if err != nil {
+		t.Fatalf("prepareImage() error: %v", err)
+	}
+	if img.opts.Args["TOKEN"] != "s3cret" || img.opts.Platform != "linux/amd64" {
+		t.Errorf("opts = %+v, want TOKEN interpolated and the platform set", img.opts)
+	}
+
+	// The build args are part of the tag.
+	t.Setenv("AW_TEST_TOKEN", "other")
+	other, err := prepareImage(context.Background(), p)
+	if err != nil {
+		t.Fatalf("prepareImage() error: %v", err)
+	}
+	if other.name == img.name {
+		t.Errorf("tag %s did not change with the build args", img.name)
+	}
+
+	// The plan shows the args as configured, not their values.
+	client := &mockDockerClient{available: true}
+	s := &DockerStage{DockerClient: client, ConfigSyncer: &mockConfigSyncer{}, MountBuilder: &mockMountBuilder{}}
+	actions, err := s.Plan(context.Background(), &pipeline.ExecutionContext{Profile: p, HomeDir: "/home/test", WorkDir: "/workspace"})
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	got := strings.Join(actions[0].Command, " ")
+	if !strings.Contains(got, "--build-arg TOKEN=${AW_TEST_TOKEN}") || !strings.Contains(got, "--platform linux/amd64") {
+		t.Errorf("build command = %q", got)
+	}
+}
+
+func TestDockerStage_PrebuiltImage(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		client := &mockDockerClient{available: true, imageExists: exists, output: "claude\n/home/claude/.claude\n"}
+		s := &DockerStage{DockerClient: client, ConfigSyncer: &mockConfigSyncer{}, MountBuilder: &mockMountBuilder{}}
+		var kinds []pipeline.EventKind
+		ec := &pipeline.ExecutionContext{
+			Profile:  profile.Profile{Environment: profile.EnvironmentDocker, Image: "ghcr.io/acme/claude:1.4"},
+			HomeDir:  t.TempDir(),
+			WorkDir:  "/workspace",
+			Observer: observerFunc(func(e pipeline.Event) { kinds = append(kinds, e.Kind) }),
+		}
+
+		if err := s.Run(context.Background(), ec); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		if client.buildCalled {
+			t.Error("Run() built an image for a profile with image:")
+		}
+		if client.pullCalled == exists {
+			t.Errorf("image exists = %v: pullCalled = %v", exists, client.pullCalled)
+		}
+		want := pipeline.ImagePulled
+		if exists {
+			want = pipeline.ImageCached
+		}
+		if !slices.Contains(kinds, want) {
+			t.Errorf("image exists = %v: events = %v, want %s", exists, kinds, want)
+		}
+		if client.outputConfig.ImageName != "ghcr.io/acme/claude:1.4" || ec.DockerImage != "ghcr.io/acme/claude:1.4" {
+			t.Errorf("probed %q and chose %q, want the prebuilt image", client.outputConfig.ImageName, ec.DockerImage)
+		}
+	}
+}
+
+func TestVerifyImageContract(t *testing.T) {
+	tests := []struct {
+		output  string
+		wantErr bool
+	}{
+		{"claude\n/home/claude/.claude\n", false},
+		{"Installing Claude Code...\nclaude\n/home/claude/.claude\n", false},
+		{"root\n\n", true},
+		{"claude\n\n", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		client := &mockDockerClient{output: tt.output}
+		err := verifyImageContract(context.Background(), client, "acme/claude")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("output %q: err = %v, wantErr %v", tt.output, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "entrypoint contract") {
+			t.Errorf("output %q: err = %v, want it to name the entrypoint contract", tt.output, err)
+		}
+	}
+}
+
+func TestDockerStage_PlanPrebuiltImage(t *testing.T) {
+	tests := []struct {
+		pull   profile.DockerPull
+		exists bool
+		want   string
+	}{
+		{"", false, "pull image acme/claude"},
+		{"", true, "use image acme/claude (present locally)"},
+		{profile.DockerPullAlways, true, "pull image acme/claude"},
+		{profile.DockerPullNever, false, "use image acme/claude, which must be present locally"},
+	}
+	for _, tt := range tests {
+		client := &mockDockerClient{available: true, imageExists: tt.exists}
+		s := &DockerStage{DockerClient: client, ConfigSyncer: &mockConfigSyncer{}, MountBuilder: &mockMountBuilder{}}
+		ec := &pipeline.ExecutionContext{
+			Profile: profile.Profile{
+				Environment: profile.EnvironmentDocker,
+				Image:       "acme/claude",
+				Docker:      &profile.DockerConfig{Pull: tt.pull},
+			},
+			HomeDir: "/home/test",
+			WorkDir: "/workspace",
+		}
+		actions, err := s.Plan(context.Background(), ec)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		if !strings.HasPrefix(actions[0].Description, tt.want) {
+			t.Errorf("pull %q, exists %v: actions[0] = %q, want %q", tt.pull, tt.exists, actions[0].Description, tt.want)
+		}
+		if client.pullCalled || client.buildCalled {
+			t.Error("Plan() must not pull or build")
+		}
+		if ec.DockerImage != "acme/claude" {
+			t.Errorf("DockerImage = %q", ec.DockerImage)
+		}
+	}
+}
+
+func TestDockerStage_MountsLinkedCopies(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, "node_modules", "left-pad"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wtPath := filepath.Join(repo, "worktrees", "a")
+	s := &DockerStage{
+		DockerClient: &mockDockerClient{available: true, imageExists: true},
+		ConfigSyncer: &mockConfigSyncer{},
+		MountBuilder: &mockMountBuilder{},
+	}
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Environment: profile.EnvironmentDocker,
+			Worktree:    &profile.WorktreeConfig{Copy: []profile.CopyEntry{{Path: ".env"}, {Path: "node_modules", Link: true}}},
+		},
+		HomeDir:      t.TempDir(),
+		WorkDir:      wtPath,
+		WorktreePath: wtPath,
+		RepoRoot:     repo,
+	}
+
+	if err := s.Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	want := docker.Mount{Source: filepath.Join(repo, "node_modules"), Target: filepath.Join(repo, "node_modules")}
+	if !slices.Contains(ec.DockerMounts, want) {
+		t.Errorf("DockerMounts = %v, want the link target %s mounted", ec.DockerMounts, want.Source)
+	}
+}
+
+func TestPrepareImage_RepoRootContextIgnoresGit(t *testing.T) {
+	repo := setupReuseRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := profile.Profile{
+		Environment: profile.EnvironmentDocker,
+		Docker:      &profile.DockerConfig{Build: &profile.DockerBuild{Context: "."}},
+		Worktree:    &profile.WorktreeConfig{Base: "main"},
+	}
+	tag := func() string {
+		t.Helper()
+		img, err := prepareImage(context.Background(), p)
+		if err != nil {
+			t.Fatalf("prepareImage() error: %v", err)
+		}
+		return img.name
+	}
+	before := tag()
+
+	// Committing the files and adding a worktree change .git and worktrees/
+	// only, not what is sent to the build.
+	for _, args := range [][]string{
+		{"add", "go.mod"},
+		{"commit", "-q", "-m", "add go.mod"},
+		{"worktree", "add", "-q", "-b", "calm-otter", filepath.Join(repo, "worktrees", "calm-otter"), "main"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if after := tag(); after != before {
+		t.Errorf("tag = %s after a commit and a new worktree, want %s", after, before)
+	}
+}
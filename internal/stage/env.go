@@ -3,8 +3,8 @@ package stage
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/hiragram/agent-workspace/internal/envfile"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
@@ -62,10 +62,44 @@ func (s *EnvStage) Run(_ context.Context, ec *pipeline.ExecutionContext) error {
 		merged[k] = v
 	}
 
+	event := pipeline.Event{Kind: pipeline.EnvLoaded, Fields: map[string]string{"count": strconv.Itoa(len(merged))}}
 	if len(merged) > 0 {
-		fmt.Fprintf(os.Stderr, "Loaded %d custom env var(s)\n", len(merged))
+		event.Message = fmt.Sprintf("Loaded %d custom env var(s)", len(merged))
 	}
+	ec.Emit(event)
 
 	ec.EnvVars = merged
 	return nil
 }
+
+// Plan merges the env vars Run would, without writing .aw-profile-env. An
+// on-create hook that has not run yet may still add vars through .aw-env.
+func (s *EnvStage) Plan(_ context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	merged := make(map[string]string)
+	for _, name := range []string{profileEnvFileName, envFileName} {
+		fileEnv, err := envfile.ParseFile(filepath.Join(ec.WorkDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		for k, v := range fileEnv {
+			merged[k] = v
+		}
+		if name == profileEnvFileName {
+			for k, v := range ec.Profile.Env {
+				merged[k] = v
+			}
+		}
+	}
+
+	var actions []pipeline.Action
+	if len(ec.Profile.Env) > 0 {
+		actions = append(actions, pipeline.Action{Description: "write profile env to " + filepath.Join(ec.WorkDir, profileEnvFileName)})
+	}
+	if ec.Profile.Worktree != nil && ec.Profile.Worktree.OnCreate != "" {
+		actions = append(actions, pipeline.Action{Description: "merge " + envFileName + " written by the on-create hook, if any"})
+	}
+	actions = append(actions, pipeline.Action{Description: fmt.Sprintf("load %d custom env var(s)", len(merged))})
+
+	ec.EnvVars = merged
+	return actions, nil
+}
@@ -270,3 +270,26 @@ func TestEnvStage_InvalidFileReturnsError(t *testing.T) {
 		t.Fatal("expected error for invalid .aw-env file")
 	}
 }
+
+func TestEnvStage_PlanDoesNotWriteProfileEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, envFileName), []byte("FROM_HOOK=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{Env: map[string]string{"FOO": "bar", "FROM_HOOK": "0"}},
+		WorkDir: dir,
+	}
+
+	s := &EnvStage{}
+	if _, err := s.Plan(context.Background(), ec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ec.EnvVars["FOO"] != "bar" || ec.EnvVars["FROM_HOOK"] != "1" {
+		t.Errorf("EnvVars = %v, want FOO=bar and FROM_HOOK=1", ec.EnvVars)
+	}
+	if _, err := os.Stat(filepath.Join(dir, profileEnvFileName)); !os.IsNotExist(err) {
+		t.Errorf("Plan() wrote %s", profileEnvFileName)
+	}
+}
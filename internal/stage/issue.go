@@ -0,0 +1,114 @@
+package stage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// promptFileName is the file in the worktree that describes the issue a
+// run is for, for the agent to read.
+const promptFileName = ".aw-prompt.md"
+
+// issue is a GitHub issue as reported by `gh issue view --json`.
+type issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	URL    string `json:"url"`
+}
+
+// fetchIssue looks up issue n of the repository at repoRoot with gh.
+func fetchIssue(ctx context.Context, repoRoot string, n int) (issue, error) {
+	cmd := execCommand(ctx, "gh", "issue", "view", strconv.Itoa(n), "--json", "number,title,body,url")
+	cmd.Dir = repoRoot
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return issue{}, fmt.Errorf("gh issue view %d: %s", n, msg)
+		}
+		return issue{}, fmt.Errorf("gh issue view %d: %w", n, err)
+	}
+	var is issue
+	if err := json.Unmarshal(out, &is); err != nil {
+		return issue{}, fmt.Errorf("parsing gh output for issue %d: %w", n, err)
+	}
+	return is, nil
+}
+
+// prompt renders the issue as Markdown for the agent.
+func (is issue) prompt() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s (#%d)\n\n", is.Title, is.Number)
+	if is.URL != "" {
+		fmt.Fprintf(&b, "%s\n\n", is.URL)
+	}
+	if body := strings.TrimSpace(is.Body); body != "" {
+		fmt.Fprintf(&b, "%s\n", body)
+	}
+	return b.String()
+}
+
+// writePrompt writes the issue to promptFileName in dir and returns its
+// path. The file is excluded from git, so that it neither makes the
+// worktree dirty nor gets committed by `git add -A`.
+func writePrompt(ctx context.Context, dir string, is issue) (string, error) {
+	path := filepath.Join(dir, promptFileName)
+	if err := os.WriteFile(path, []byte(is.prompt()), 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %w", promptFileName, err)
+	}
+	if err := excludeFromGit(ctx, dir, "/"+promptFileName); err != nil {
+		return "", fmt.Errorf("excluding %s from git: %w", promptFileName, err)
+	}
+	return path, nil
+}
+
+// excludeFromGit adds pattern to the exclude file of the repository dir is
+// in, unless it is listed already. Linked worktrees share the main
+// worktree's exclude file.
+func excludeFromGit(ctx context.Context, dir, pattern string) error {
+	cmd := execCommand(ctx, "git", "rev-parse", "--git-path", "info/exclude")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		pattern = "\n" + pattern
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(pattern + "\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -32,6 +32,24 @@ func (s *LaunchStage) Run(ctx context.Context, ec *pipeline.ExecutionContext) er
 	return l.Launch(ctx, ec)
 }
 
+// Plan reports what the launcher would run, if it supports dry runs.
+func (s *LaunchStage) Plan(ctx context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	factory := s.LauncherFactory
+	if factory == nil {
+		factory = defaultLauncherFactory
+	}
+
+	l, err := factory(ec.Profile.Launch)
+	if err != nil {
+		return nil, err
+	}
+	planner, ok := l.(pipeline.Planner)
+	if !ok {
+		return nil, fmt.Errorf("launch mode %q does not support dry runs", ec.Profile.Launch)
+	}
+	return planner.Plan(ctx, ec)
+}
+
 func defaultLauncherFactory(mode profile.LaunchMode) (launcher.Launcher, error) {
 	switch mode {
 	case profile.LaunchShell:
@@ -3,12 +3,15 @@ package stage
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/hiragram/agent-workspace/internal/launcher"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/session"
 )
 
 type mockLauncher struct {
@@ -101,3 +104,72 @@ func TestLaunchStage_LauncherError(t *testing.T) {
 		t.Errorf("error = %q, want containing 'launch failed'", err.Error())
 	}
 }
+
+func TestLaunchStage_FailedSessionKeepsWorktree(t *testing.T) {
+	repo := setupReuseRepo(t)
+	reg := session.NewRegistry(t.TempDir())
+
+	// The session starts, the user works in the worktree, and the shell
+	// exits non-zero.
+	client := &mockDockerClient{runErr: fmt.Errorf("exit status 1")}
+	s := &LaunchStage{
+		LauncherFactory: func(_ profile.LaunchMode) (launcher.Launcher, error) {
+			return &launcher.ShellLauncher{}, nil
+		},
+	}
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Environment: profile.EnvironmentDocker,
+			Launch:      profile.LaunchShell,
+			Worktree:    &profile.WorktreeConfig{Base: "main"},
+		},
+		Observer:     observerFunc(func(pipeline.Event) {}),
+		OrigWorkDir:  repo,
+		DockerClient: client,
+	}
+	p := pipeline.New(&WorktreeStage{}, &SessionStage{Registry: reg}, s)
+	err := p.Execute(context.Background(), ec)
+	if err == nil || !strings.Contains(err.Error(), "exit status 1") {
+		t.Fatalf("Execute() error = %v, want the session's exit status", err)
+	}
+	if !client.runCalled {
+		t.Fatal("the session was not started")
+	}
+
+	if _, err := os.Stat(filepath.Join(ec.WorktreePath, ".git")); ec.WorktreePath == "" || err != nil {
+		t.Errorf("worktree %q was rolled back after the session started: %v", ec.WorktreePath, err)
+	}
+	if sessions, _ := reg.List(); len(sessions) != 1 {
+		t.Errorf("sessions = %v, want the record kept", sessions)
+	}
+}
+
+func TestLaunchStage_Plan(t *testing.T) {
+	t.Setenv("CLAUDE_HOME", "")
+	ec := &pipeline.ExecutionContext{
+		Profile:       profile.Profile{Environment: profile.EnvironmentDocker, Launch: profile.LaunchShell},
+		HomeDir:       "/home/test",
+		WorkDir:       "/workspace",
+		DockerImage:   "img",
+		ContainerName: "aw-1234",
+	}
+
+	actions, err := (&LaunchStage{}).Plan(context.Background(), ec)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	got := strings.Join(actions[len(actions)-1].Command, " ")
+	want := "docker run -it --rm --name aw-1234 -e HOST_CLAUDE_HOME=/home/test/.claude -e HOST_WORKSPACE=/workspace --workdir /workspace img /bin/bash"
+	if got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+
+	s := &LaunchStage{
+		LauncherFactory: func(_ profile.LaunchMode) (launcher.Launcher, error) {
+			return &mockLauncher{}, nil
+		},
+	}
+	if _, err := s.Plan(context.Background(), ec); err == nil || !strings.Contains(err.Error(), "does not support dry runs") {
+		t.Errorf("Plan() error = %v, want a dry-run error", err)
+	}
+}
@@ -0,0 +1,116 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hiragram/agent-workspace/internal/launcher"
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/session"
+)
+
+// SessionStage records the run in the session registry so that `aw ps`,
+// `aw attach` and `aw stop` can find it later. It runs after every
+// resource-creating stage and right before LaunchStage.
+type SessionStage struct {
+	// Registry stores the session record. If nil, the default registry
+	// under the user's state directory is used.
+	Registry *session.Registry
+}
+
+func (s *SessionStage) Name() string { return "session" }
+
+func (s *SessionStage) Run(_ context.Context, ec *pipeline.ExecutionContext) error {
+	reg := s.registry(ec)
+	rec, err := newSessionRecord(ec)
+	if err != nil {
+		return err
+	}
+
+	if err := reg.Save(rec); err != nil {
+		return fmt.Errorf("recording session: %w", err)
+	}
+
+	ec.SessionID = rec.ID
+	ec.ContainerName = rec.Container
+	fields := map[string]string{"id": rec.ID}
+	if rec.Container != "" {
+		fields["container"] = rec.Container
+	}
+	ec.Emit(pipeline.Event{Kind: pipeline.SessionRecorded, Fields: fields})
+	return nil
+}
+
+// Rollback removes the session record.
+func (s *SessionStage) Rollback(_ context.Context, ec *pipeline.ExecutionContext) error {
+	if ec.SessionID == "" {
+		return nil
+	}
+	if err := s.registry(ec).Remove(ec.SessionID); err != nil {
+		return err
+	}
+	ec.SessionID = ""
+	return nil
+}
+
+// Plan names the session Run would record. The ID is random, so a real run
+// picks a different one.
+func (s *SessionStage) Plan(_ context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	rec, err := newSessionRecord(ec)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf("record session %s (random ID) in %s", rec.ID, s.registry(ec).Dir)
+	if rec.Container != "" {
+		desc += "; container " + rec.Container
+	}
+	ec.SessionID = rec.ID
+	ec.ContainerName = rec.Container
+	return []pipeline.Action{{Description: desc}}, nil
+}
+
+func (s *SessionStage) registry(ec *pipeline.ExecutionContext) *session.Registry {
+	if s.Registry != nil {
+		return s.Registry
+	}
+	return session.NewRegistry(session.DefaultDir(ec.HomeDir))
+}
+
+// newSessionRecord describes the run in ec under a new session ID.
+func newSessionRecord(ec *pipeline.ExecutionContext) (session.Session, error) {
+	id, err := session.NewID()
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	rec := session.Session{
+		ID:             id,
+		Profile:        ec.ProfileName,
+		Environment:    string(ec.Profile.Environment),
+		Launch:         string(ec.Profile.Launch),
+		WorkDir:        ec.WorkDir,
+		RepoRoot:       ec.RepoRoot,
+		WorktreePath:   ec.WorktreePath,
+		WorktreeBranch: ec.WorktreeBranch,
+		WorktreeBase:   ec.WorktreeBase,
+		Image:          ec.DockerImage,
+		PID:            os.Getpid(),
+		CreatedAt:      time.Now(),
+	}
+	// Without a start time the session is still listed, but `aw stop`
+	// cannot tell its process from another one and does not signal it.
+	rec.PIDStart, _ = session.ProcessStart(rec.PID)
+	if ec.Profile.Environment == profile.EnvironmentDocker {
+		rec.Container = "aw-" + id
+		rec.DockerClient = string(ec.Profile.Docker.EffectiveClient())
+		rec.Runtime = ec.DockerRuntime.Name
+	}
+	if ec.Profile.Launch == profile.LaunchZellij {
+		rec.ZellijSession = launcher.ZellijSessionName(ec)
+	}
+	return rec, nil
+}
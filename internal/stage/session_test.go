@@ -0,0 +1,170 @@
+package stage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiragram/agent-workspace/internal/docker"
+	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/session"
+)
+
+func TestSessionStage_Name(t *testing.T) {
+	s := &SessionStage{}
+	if s.Name() != "session" {
+		t.Errorf("Name() = %q, want %q", s.Name(), "session")
+	}
+}
+
+func TestSessionStage_RecordsDockerZellijSession(t *testing.T) {
+	reg := session.NewRegistry(t.TempDir())
+	s := &SessionStage{Registry: reg}
+
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Worktree:    &profile.WorktreeConfig{},
+			Environment: profile.EnvironmentDocker,
+			Launch:      profile.LaunchZellij,
+		},
+		ProfileName:    "worktree-zellij",
+		WorkDir:        "/repo/worktrees/calm-otter-dawn",
+		WorktreePath:   "/repo/worktrees/calm-otter-dawn",
+		WorktreeBranch: "calm-otter-dawn",
+		WorktreeBase:   "origin/main",
+		RepoRoot:       "/repo",
+		DockerImage:    "claude-code-docker:abc123",
+		DockerRuntime:  docker.RuntimeInfo{Name: docker.RuntimePodman, Rootless: true},
+	}
+
+	if err := s.Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if ec.SessionID == "" {
+		t.Fatal("SessionID should be set")
+	}
+	if ec.ContainerName != "aw-"+ec.SessionID {
+		t.Errorf("ContainerName = %q, want %q", ec.ContainerName, "aw-"+ec.SessionID)
+	}
+
+	got, err := reg.Find(ec.SessionID)
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if got.Profile != "worktree-zellij" {
+		t.Errorf("Profile = %q, want %q", got.Profile, "worktree-zellij")
+	}
+	if got.WorktreeBranch != "calm-otter-dawn" || got.WorktreeBase != "origin/main" {
+		t.Errorf("worktree = %q from %q", got.WorktreeBranch, got.WorktreeBase)
+	}
+	if got.Image != "claude-code-docker:abc123" {
+		t.Errorf("Image = %q", got.Image)
+	}
+	if got.Container != ec.ContainerName {
+		t.Errorf("Container = %q, want %q", got.Container, ec.ContainerName)
+	}
+	if got.Runtime != docker.RuntimePodman {
+		t.Errorf("Runtime = %q, want %q", got.Runtime, docker.RuntimePodman)
+	}
+	if got.ZellijSession != "calm-otter-dawn" {
+		t.Errorf("ZellijSession = %q, want %q", got.ZellijSession, "calm-otter-dawn")
+	}
+	if got.PID == 0 {
+		t.Error("PID should be recorded")
+	}
+}
+
+func TestSessionStage_HostShellHasNoContainerOrZellij(t *testing.T) {
+	reg := session.NewRegistry(t.TempDir())
+	s := &SessionStage{Registry: reg}
+
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Environment: profile.EnvironmentHost,
+			Launch:      profile.LaunchShell,
+		},
+		ProfileName: "shell",
+		WorkDir:     "/repo",
+	}
+
+	if err := s.Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if ec.ContainerName != "" {
+		t.Errorf("ContainerName = %q, want empty for host environment", ec.ContainerName)
+	}
+
+	got, err := reg.Find(ec.SessionID)
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if got.Container != "" || got.ZellijSession != "" {
+		t.Errorf("expected no container/zellij session, got %q / %q", got.Container, got.ZellijSession)
+	}
+}
+
+func TestSessionStage_PlanRecordsNothing(t *testing.T) {
+	reg := session.NewRegistry(t.TempDir())
+	s := &SessionStage{Registry: reg}
+
+	ec := &pipeline.ExecutionContext{
+		Profile:     profile.Profile{Environment: profile.EnvironmentDocker, Launch: profile.LaunchClaude},
+		ProfileName: "claude",
+	}
+	if _, err := s.Plan(context.Background(), ec); err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+
+	if ec.ContainerName != "aw-"+ec.SessionID {
+		t.Errorf("ContainerName = %q, want %q", ec.ContainerName, "aw-"+ec.SessionID)
+	}
+	if sessions, err := reg.List(); err != nil || len(sessions) != 0 {
+		t.Errorf("List() = %v, %v; want no sessions", sessions, err)
+	}
+}
+
+func TestSessionStage_EmitsSessionRecorded(t *testing.T) {
+	var events []pipeline.Event
+	ec := &pipeline.ExecutionContext{
+		Profile:  profile.Profile{Environment: profile.EnvironmentDocker, Launch: profile.LaunchClaude},
+		Observer: observerFunc(func(e pipeline.Event) { events = append(events, e) }),
+	}
+	s := &SessionStage{Registry: session.NewRegistry(t.TempDir())}
+	if err := s.Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Kind != pipeline.SessionRecorded {
+		t.Fatalf("events = %+v, want one session_recorded", events)
+	}
+	if f := events[0].Fields; f["id"] != ec.SessionID || f["container"] != ec.ContainerName {
+		t.Errorf("fields = %v, want id %q and container %q", f, ec.SessionID, ec.ContainerName)
+	}
+}
+
+type observerFunc func(pipeline.Event)
+
+func (f observerFunc) Observe(e pipeline.Event) { f(e) }
+
+func TestSessionStage_Rollback(t *testing.T) {
+	reg := session.NewRegistry(t.TempDir())
+	s := &SessionStage{Registry: reg}
+	ec := &pipeline.ExecutionContext{
+		Profile:  profile.Profile{Environment: profile.EnvironmentHost, Launch: profile.LaunchShell},
+		Observer: observerFunc(func(pipeline.Event) {}),
+	}
+	if err := s.Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if err := s.Rollback(context.Background(), ec); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+
+	if sessions, _ := reg.List(); len(sessions) != 0 {
+		t.Errorf("sessions = %v, want none after rollback", sessions)
+	}
+	if ec.SessionID != "" {
+		t.Errorf("SessionID = %q, want it cleared", ec.SessionID)
+	}
+}
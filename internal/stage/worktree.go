@@ -6,28 +6,324 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hiragram/agent-workspace/internal/pipeline"
+	"github.com/hiragram/agent-workspace/internal/profile"
 	"github.com/hiragram/agent-workspace/internal/worktree"
 )
 
-// WorktreeStage creates a git worktree for the workspace.
-type WorktreeStage struct{}
+// WorktreeStage creates a git worktree for the workspace, or resumes one it
+// created earlier (see ExecutionContext.ReuseWorktree and worktree.reuse).
+type WorktreeStage struct {
+	// Choose picks the worktree to resume under worktree.reuse: prompt. wts
+	// is ordered from the most recently used; ok is false to create a new
+	// worktree instead. If nil, a new worktree is always created.
+	Choose func(wts []worktree.Worktree) (w worktree.Worktree, ok bool)
+}
 
 func (s *WorktreeStage) Name() string { return "worktree" }
 
-func (s *WorktreeStage) Run(_ context.Context, ec *pipeline.ExecutionContext) error {
-	// Find git repository root
-	repoRoot, err := gitRepoRoot()
+func (s *WorktreeStage) Run(ctx context.Context, ec *pipeline.ExecutionContext) error {
+	wt, err := s.prepare(ctx, ec, true)
 	if err != nil {
-		return fmt.Errorf("not in a git repository: %w", err)
+		return err
+	}
+
+	// Resume an existing worktree: it was fetched and set up when created.
+	if wt.reused {
+		wt.apply(ec)
+		ec.Logf("Reusing worktree: %s", wt.path)
+		ec.Emit(pipeline.Event{
+			Kind:   pipeline.WorktreeReused,
+			Fields: map[string]string{"path": wt.path, "branch": wt.branch, "base": wt.base},
+		})
+		return nil
+	}
+
+	// Fetch the base ref, or the pull request. Without a fetch, start from
+	// the local ref.
+	switch {
+	case wt.fetch:
+		ec.Logf("Fetching %s...", wt.fetchName())
+		err := gitFetch(ctx, wt.repoRoot, wt.remote, wt.remoteRef)
+		if err == nil {
+			ec.Emit(pipeline.Event{Kind: pipeline.BaseFetched, Fields: map[string]string{"ref": wt.remote + "/" + wt.remoteRef}})
+			break
+		}
+		if wt.pr > 0 || ctx.Err() != nil || !worktree.RefExists(ctx, wt.repoRoot, wt.base) {
+			return fmt.Errorf("fetching %s: %w", wt.fetchName(), err)
+		}
+		ec.Warnf("fetching %s failed (%v); using the local %s, which may be out of date", wt.fetchName(), err, wt.base)
+	case wt.noFetch != "":
+		if !worktree.RefExists(ctx, wt.repoRoot, wt.base) {
+			return fmt.Errorf("base ref %s does not exist locally and is not fetched (%s)", wt.base, wt.noFetch)
+		}
+		ec.Logf("Using the local %s (%s)", wt.base, wt.noFetch)
+	default:
+		if !worktree.RefExists(ctx, wt.repoRoot, wt.base) {
+			return fmt.Errorf("base ref %s does not exist (set worktree.base to a branch, tag or commit, or to <remote>/<branch> for a remote in `git remote`)", wt.base)
+		}
+	}
+
+	if err := os.MkdirAll(wt.dir, 0755); err != nil {
+		return fmt.Errorf("creating worktrees directory: %w", err)
+	}
+
+	// Create worktree, leaving the checkout for later with sparse checkout.
+	// It is recorded first, so that Rollback removes whatever a failed or
+	// interrupted `git worktree add` left behind.
+	sparse := sparseDirs(ec)
+	wt.apply(ec)
+	ec.Logf("Creating worktree: %s", wt.path)
+	if err := gitWorktreeAdd(ctx, wt.repoRoot, wt.branch, wt.path, wt.startPoint(), len(sparse) > 0); err != nil {
+		return fmt.Errorf("creating worktree: %w", err)
+	}
+	if err := worktree.RecordBase(ctx, wt.repoRoot, wt.branch, wt.base); err != nil {
+		ec.Warnf("recording base ref for %s: %v", wt.branch, err)
+	}
+
+	ec.Emit(pipeline.Event{
+		Kind:   pipeline.WorktreeCreated,
+		Fields: map[string]string{"path": wt.path, "branch": wt.branch, "base": wt.base},
+	})
+
+	if len(sparse) > 0 {
+		ec.Logf("Checking out %s...", strings.Join(sparse, ", "))
+		if err := gitSparseCheckout(ctx, wt.path, sparse); err != nil {
+			return fmt.Errorf("sparse checkout: %w", err)
+		}
+	}
+	if ec.Profile.Worktree.EffectiveSubmodules() == profile.WorktreeSubmodulesRecursive {
+		ec.Logf("Updating submodules...")
+		if err := gitSubmoduleUpdate(ctx, wt.path); err != nil {
+			return fmt.Errorf("updating submodules: %w", err)
+		}
+	}
+
+	// Describe the issue for the agent
+	if wt.issue != nil {
+		path, err := writePrompt(ctx, wt.path, *wt.issue)
+		if err != nil {
+			return err
+		}
+		ec.PromptFile = path
+		ec.Logf("Wrote issue #%d to %s", wt.issue.Number, path)
+	}
+
+	// Bring in untracked files such as .env
+	if ec.Profile.Worktree != nil {
+		for _, c := range ec.Profile.Worktree.Copy {
+			start := time.Now()
+			n, err := worktree.CopyFiles(wt.repoRoot, wt.path, c.Path, c.Link)
+			if err != nil {
+				return fmt.Errorf("copying %s into worktree: %w", c.Path, err)
+			}
+			if n == 0 {
+				ec.Logf("Nothing to copy for %s", c.Path)
+				continue
+			}
+			verb := "Copied"
+			if c.Link {
+				verb = "Linked"
+			}
+			ec.Logf("%s %d file(s) matching %s", verb, n, c.Path)
+			ec.Emit(pipeline.Event{
+				Kind:     pipeline.FilesCopied,
+				Duration: time.Since(start),
+				Fields:   map[string]string{"pattern": c.Path, "count": strconv.Itoa(n)},
+			})
+		}
+	}
+
+	// Run on-create hook if configured
+	if ec.Profile.Worktree != nil && ec.Profile.Worktree.OnCreate != "" {
+		ec.Logf("Running on-create hook...")
+		start := time.Now()
+		if err := runOnCreateHook(ctx, ec, wt.repoRoot); err != nil {
+			return fmt.Errorf("on-create hook: %w", err)
+		}
+		ec.Emit(pipeline.Event{
+			Kind:     pipeline.HookFinished,
+			Duration: time.Since(start),
+			Fields:   map[string]string{"hook": "on-create"},
+		})
+	}
+
+	return nil
+}
+
+// Rollback runs the on-end hook, then removes the worktree and branch Run
+// created along with anything the on-create hook left in it. Clearing the
+// worktree from ec keeps the on-end hook from running a second time.
+// A resumed worktree holds earlier work and is left as it was. Run may have
+// failed while creating the worktree, so its directory or branch may be
+// missing.
+func (s *WorktreeStage) Rollback(ctx context.Context, ec *pipeline.ExecutionContext) error {
+	if ec.WorktreePath == "" {
+		return nil
+	}
+	if ec.WorktreeReused {
+		clearWorktree(ec)
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(ec.WorktreePath, ".git")); err == nil &&
+		ec.Profile.Worktree != nil && ec.Profile.Worktree.OnEnd != "" {
+		ec.Logf("Running on-end hook...")
+		if err := RunOnEndHook(ctx, ec); err != nil {
+			ec.Warnf("on-end hook failed: %v", err)
+		}
+	}
+	w := worktree.Worktree{Path: ec.WorktreePath, Branch: ec.WorktreeBranch}
+	if err := removeWorktree(ctx, ec.RepoRoot, w, true); err != nil {
+		return err
+	}
+	clearWorktree(ec)
+	return nil
+}
+
+// clearWorktree forgets the worktree, returning to where aw was invoked.
+func clearWorktree(ec *pipeline.ExecutionContext) {
+	ec.WorkDir = ec.OrigWorkDir
+	ec.WorktreePath = ""
+	ec.WorktreeBranch = ""
+	ec.WorktreeBase = ""
+	ec.WorktreeReused = false
+}
+
+// Plan describes the worktree Run would create or resume. Random words in
+// the name of a new branch differ on a real run. Plan does not prompt: with
+// worktree.reuse: prompt it plans a new worktree.
+func (s *WorktreeStage) Plan(ctx context.Context, ec *pipeline.ExecutionContext) ([]pipeline.Action, error) {
+	wt, err := s.prepare(ctx, ec, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if wt.reused {
+		wt.apply(ec)
+		return []pipeline.Action{{
+			Description: fmt.Sprintf("reuse worktree %s on branch %s (on-create is skipped)", wt.path, wt.branch),
+		}}, nil
+	}
+
+	var actions []pipeline.Action
+	if wt.candidates > 0 {
+		actions = append(actions, pipeline.Action{
+			Description: fmt.Sprintf("ask whether to resume one of %d existing worktree(s); planning a new one", wt.candidates),
+		})
+	}
+	switch {
+	case wt.fetch:
+		fallback := ""
+		if wt.pr == 0 {
+			fallback = " (the local ref is used if this fails)"
+		}
+		actions = append(actions, pipeline.Action{
+			Description: "fetch " + wt.fetchName() + fallback,
+			Command:     []string{"git", "-C", wt.repoRoot, "fetch", wt.remote, wt.remoteRef},
+		})
+	case wt.noFetch != "":
+		actions = append(actions, pipeline.Action{
+			Description: fmt.Sprintf("use the local %s without fetching (%s)", wt.base, wt.noFetch),
+		})
+	}
+	if _, err := os.Stat(wt.dir); err != nil {
+		actions = append(actions, pipeline.Action{Description: "create worktrees directory " + wt.dir})
+	}
+	random := ""
+	if wt.random {
+		random = " (random words)"
+	}
+	sparse := sparseDirs(ec)
+	add := []string{"git", "-C", wt.repoRoot, "worktree", "add"}
+	if len(sparse) > 0 {
+		add = append(add, "--no-checkout")
+	}
+	actions = append(actions,
+		pipeline.Action{
+			Description: fmt.Sprintf("create worktree %s on new branch %s%s from %s", wt.path, wt.branch, random, wt.fetchName()),
+			Command:     append(add, "-b", wt.branch, wt.path, wt.startPoint()),
+		},
+		pipeline.Action{Description: "record base ref " + wt.base + " for branch " + wt.branch},
+	)
+	if len(sparse) > 0 {
+		actions = append(actions,
+			pipeline.Action{
+				Description: "limit the checkout to " + strings.Join(sparse, ", "),
+				Command:     append([]string{"git", "-C", wt.path, "sparse-checkout", "set", "--cone", "--"}, sparse...),
+			},
+			pipeline.Action{
+				Description: "check out the worktree",
+				Command:     []string{"git", "-C", wt.path, "checkout"},
+			},
+		)
+	}
+	if ec.Profile.Worktree.EffectiveSubmodules() == profile.WorktreeSubmodulesRecursive {
+		actions = append(actions, pipeline.Action{
+			Description: "check out submodules",
+			Command:     []string{"git", "-C", wt.path, "submodule", "update", "--init", "--recursive"},
+		})
+	}
+
+	wt.apply(ec)
+
+	if wt.issue != nil {
+		ec.PromptFile = filepath.Join(wt.path, promptFileName)
+		actions = append(actions, pipeline.Action{
+			Description: fmt.Sprintf("write issue #%d (%s) to %s", wt.issue.Number, wt.issue.Title, ec.PromptFile),
+		})
+	}
+
+	if ec.Profile.Worktree != nil {
+		for _, c := range ec.Profile.Worktree.Copy {
+			how := "copy"
+			if c.Link {
+				how = "symlink"
+			}
+			actions = append(actions, pipeline.Action{
+				Description: fmt.Sprintf("%s %s from %s into %s (existing files are kept)", how, c.Path, wt.repoRoot, wt.path),
+			})
+		}
+	}
+
+	if ec.Profile.Worktree != nil && ec.Profile.Worktree.OnCreate != "" {
+		actions = append(actions, pipeline.Action{
+			Description: "run on-create hook in " + wt.path,
+			Command:     []string{"sh", "-c", ec.Profile.Worktree.OnCreate},
+		})
 	}
+	return actions, nil
+}
+
+// plannedWorktree is the worktree a run will create or resume.
+type plannedWorktree struct {
+	repoRoot   string
+	branch     string
+	base       string
+	remote     string // remote to fetch base from; empty for a local ref
+	remoteRef  string
+	fetch      bool   // Run fetches remoteRef from remote
+	noFetch    string // why a remote base is not fetched, e.g. "--offline"
+	dir        string // worktrees directory
+	path       string
+	reused     bool   // path is an existing worktree to resume
+	random     bool   // branch has random words, so a real run names it differently
+	pr         int    // pull request to check out; 0 to start from base
+	issue      *issue // issue the run is for, looked up with gh
+	candidates int    // existing worktrees the user could be asked about
+}
 
-	// Generate random branch name
-	name, err := worktree.GenerateName()
+// prepare works out the worktree to create or resume without touching the
+// repository. It asks s.Choose under worktree.reuse: prompt only if
+// interactive is set.
+func (s *WorktreeStage) prepare(ctx context.Context, ec *pipeline.ExecutionContext, interactive bool) (plannedWorktree, error) {
+	// Find git repository root
+	repoRoot, err := gitRepoRoot(ctx)
 	if err != nil {
-		return fmt.Errorf("generating branch name: %w", err)
+		return plannedWorktree{}, fmt.Errorf("not in a git repository: %w", err)
 	}
 
 	// Determine base ref
@@ -35,55 +331,163 @@ func (s *WorktreeStage) Run(_ context.Context, ec *pipeline.ExecutionContext) er
 	if ec.Profile.Worktree != nil {
 		base = ec.Profile.Worktree.EffectiveBase()
 	}
+	wt := plannedWorktree{repoRoot: repoRoot, base: base}
+
+	// Determine worktrees directory (config-overridable)
+	wt.dir, err = resolveWorktreesDir(ec, repoRoot)
+	if err != nil {
+		return plannedWorktree{}, fmt.Errorf("resolving worktrees directory: %w", err)
+	}
 
-	// Fetch the base ref
-	refParts := strings.SplitN(base, "/", 2)
-	if len(refParts) == 2 {
-		fmt.Fprintf(os.Stderr, "Fetching %s...\n", base)
-		if err := gitFetch(repoRoot, refParts[0], refParts[1]); err != nil {
-			return fmt.Errorf("fetching %s: %w", base, err)
+	existing, ok, err := s.existing(ctx, ec, &wt, interactive)
+	if err != nil {
+		return plannedWorktree{}, err
+	}
+	if ok {
+		wt.path, wt.branch, wt.base, wt.reused = existing.Path, existing.Branch, existing.Base, true
+		return wt, nil
+	}
+
+	// Look up the issue, whose title can go into the branch name
+	data := worktree.NewBranchData(ec.ProfileName)
+	tmpl := ec.Profile.Worktree.EffectiveBranch()
+	custom := ec.Profile.Worktree != nil && ec.Profile.Worktree.Branch != ""
+	if ec.IssueNumber > 0 {
+		is, err := fetchIssue(ctx, repoRoot, ec.IssueNumber)
+		if err != nil {
+			return plannedWorktree{}, err
+		}
+		wt.issue = &is
+		data.Issue, data.Title = strconv.Itoa(is.Number), worktree.Slug(is.Title)
+		if !custom {
+			tmpl = "issue-{{.Issue}}-{{.Title}}"
+			if data.Title == "" {
+				tmpl = "issue-{{.Issue}}"
+			}
 		}
 	}
 
-	// Determine worktrees directory (config-overridable)
-	worktreesDir, err := resolveWorktreesDir(ec, repoRoot)
+	// Check out a pull request from the base's remote, or origin. Only a
+	// base that starts with the name of a remote is fetched.
+	remotes, err := worktree.Remotes(ctx, repoRoot)
 	if err != nil {
-		return fmt.Errorf("resolving worktrees directory: %w", err)
+		return plannedWorktree{}, fmt.Errorf("listing git remotes: %w", err)
 	}
-	if err := os.MkdirAll(worktreesDir, 0755); err != nil {
-		return fmt.Errorf("creating worktrees directory: %w", err)
+	wt.remote, wt.remoteRef = worktree.SplitRemoteRef(remotes, base)
+	if ec.PRNumber > 0 {
+		wt.pr = ec.PRNumber
+		if wt.remote == "" {
+			wt.remote = "origin"
+		}
+		wt.remoteRef = fmt.Sprintf("pull/%d/head", ec.PRNumber)
+		data.PR = strconv.Itoa(ec.PRNumber)
+		if !custom {
+			tmpl = "pr-{{.PR}}"
+		}
+		wt.fetch = true
+	} else if wt.remote != "" {
+		wt.fetch, wt.noFetch = fetchPolicy(ctx, ec, repoRoot, base)
 	}
 
-	// Create worktree
-	worktreePath := filepath.Join(worktreesDir, name)
-	fmt.Fprintf(os.Stderr, "Creating worktree: %s\n", worktreePath)
-	if err := gitWorktreeAdd(repoRoot, name, worktreePath, base); err != nil {
-		return fmt.Errorf("creating worktree: %w", err)
+	// Name the branch
+	if ec.NewBranch != "" {
+		if err := worktree.CheckBranchName(ctx, repoRoot, ec.NewBranch); err != nil {
+			return plannedWorktree{}, err
+		}
+		if worktree.BranchExists(ctx, repoRoot, ec.NewBranch) {
+			return plannedWorktree{}, fmt.Errorf("branch %q already exists (resume its worktree with --worktree)", ec.NewBranch)
+		}
+		wt.branch = ec.NewBranch
+	} else {
+		wt.branch, err = worktree.NewBranch(ctx, repoRoot, wt.dir, tmpl, data)
+		if err != nil {
+			return plannedWorktree{}, fmt.Errorf("naming branch: %w", err)
+		}
+		wt.random = strings.Contains(tmpl, ".Words")
+	}
+	wt.path = filepath.Join(wt.dir, worktree.DirName(wt.branch))
+	if _, err := os.Stat(wt.path); err == nil && ec.NewBranch != "" {
+		return plannedWorktree{}, fmt.Errorf("worktree directory %s already exists", wt.path)
 	}
+	return wt, nil
+}
 
-	// Update execution context
-	ec.WorkDir = worktreePath
-	ec.WorktreePath = worktreePath
-	ec.WorktreeBranch = name
-	ec.WorktreeBase = base
-	ec.RepoRoot = repoRoot
+// existing returns the worktree to resume, if any: the one named by
+// ec.ReuseWorktree, otherwise one picked according to worktree.reuse from
+// those in the profile's worktrees directory.
+func (s *WorktreeStage) existing(ctx context.Context, ec *pipeline.ExecutionContext, wt *plannedWorktree, interactive bool) (worktree.Worktree, bool, error) {
+	policy := ec.Profile.Worktree.EffectiveReuse()
+	if ec.NewBranch != "" || ec.PRNumber > 0 || ec.IssueNumber > 0 {
+		return worktree.Worktree{}, false, nil
+	}
+	if ec.ReuseWorktree == "" && policy == profile.WorktreeReuseNever {
+		return worktree.Worktree{}, false, nil
+	}
 
-	// Run on-create hook if configured
-	if ec.Profile.Worktree != nil && ec.Profile.Worktree.OnCreate != "" {
-		fmt.Fprintf(os.Stderr, "Running on-create hook...\n")
-		if err := runOnCreateHook(ec, repoRoot); err != nil {
-			return fmt.Errorf("on-create hook: %w", err)
+	wts, err := worktree.List(ctx, wt.repoRoot, map[string]string{wt.dir: wt.base})
+	if err != nil {
+		return worktree.Worktree{}, false, err
+	}
+	if ec.ReuseWorktree != "" {
+		w, err := worktree.Find(wts, ec.ReuseWorktree)
+		if err != nil {
+			return worktree.Worktree{}, false, fmt.Errorf("%w in %s (see `aw worktree list`)", err, wt.dir)
 		}
+		return w, true, nil
+	}
+	if len(wts) == 0 {
+		return worktree.Worktree{}, false, nil
 	}
 
-	return nil
+	worktree.SortByLastUsed(wts)
+	switch policy {
+	case profile.WorktreeReuseLatest:
+		return wts[0], true, nil
+	case profile.WorktreeReusePrompt:
+		if !interactive || s.Choose == nil {
+			wt.candidates = len(wts)
+			return worktree.Worktree{}, false, nil
+		}
+		w, ok := s.Choose(wts)
+		return w, ok, nil
+	}
+	return worktree.Worktree{}, false, nil
+}
+
+// fetchName describes what Run fetches: the base ref or the pull request.
+func (wt plannedWorktree) fetchName() string {
+	if wt.pr > 0 {
+		return fmt.Sprintf("pull request #%d", wt.pr)
+	}
+	return wt.base
+}
+
+// startPoint is the commit the new branch starts from.
+func (wt plannedWorktree) startPoint() string {
+	if wt.pr > 0 {
+		return "FETCH_HEAD"
+	}
+	return wt.base
+}
+
+// apply records the worktree in the execution context.
+func (wt plannedWorktree) apply(ec *pipeline.ExecutionContext) {
+	ec.WorkDir = wt.path
+	ec.WorktreePath = wt.path
+	ec.WorktreeBranch = wt.branch
+	ec.WorktreeBase = wt.base
+	ec.WorktreeReused = wt.reused
+	ec.RepoRoot = wt.repoRoot
 }
 
-// execCommand is a package-level var for testing.
-var execCommand = exec.Command
+// Package-level vars for testing.
+var (
+	execCommand    = exec.CommandContext
+	removeWorktree = worktree.Remove
+)
 
-func runOnCreateHook(ec *pipeline.ExecutionContext, repoRoot string) error {
-	cmd := execCommand("sh", "-c", ec.Profile.Worktree.OnCreate)
+func runOnCreateHook(ctx context.Context, ec *pipeline.ExecutionContext, repoRoot string) error {
+	cmd := execCommand(ctx, "sh", "-c", ec.Profile.Worktree.OnCreate)
 	cmd.Dir = ec.WorktreePath
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -94,12 +498,13 @@ func runOnCreateHook(ec *pipeline.ExecutionContext, repoRoot string) error {
 		"AW_PROFILE_NAME="+ec.ProfileName,
 		"AW_ENVIRONMENT="+string(ec.Profile.Environment),
 	)
+	cmd.Env = append(cmd.Env, ec.TaskEnv()...)
 	return cmd.Run()
 }
 
 // RunOnEndHook runs the on-end hook command after the launched process exits.
-func RunOnEndHook(ec *pipeline.ExecutionContext) error {
-	cmd := execCommand("sh", "-c", ec.Profile.Worktree.OnEnd)
+func RunOnEndHook(ctx context.Context, ec *pipeline.ExecutionContext) error {
+	cmd := execCommand(ctx, "sh", "-c", ec.Profile.Worktree.OnEnd)
 	cmd.Dir = ec.WorktreePath
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -110,9 +515,37 @@ func RunOnEndHook(ec *pipeline.ExecutionContext) error {
 		"AW_PROFILE_NAME="+ec.ProfileName,
 		"AW_ENVIRONMENT="+string(ec.Profile.Environment),
 	)
+	cmd.Env = append(cmd.Env, ec.TaskEnv()...)
 	return cmd.Run()
 }
 
+// fetchPolicy decides whether a run fetches the remote ref base, following
+// --offline and worktree.fetch. If not, reason says why.
+func fetchPolicy(ctx context.Context, ec *pipeline.ExecutionContext, repoRoot, base string) (fetch bool, reason string) {
+	if ec.Offline {
+		return false, "--offline"
+	}
+	switch ec.Profile.Worktree.EffectiveFetch() {
+	case profile.WorktreeFetchNever:
+		return false, "worktree.fetch: never"
+	case profile.WorktreeFetchIfStale:
+		age := time.Since(worktree.LastFetched(ctx, repoRoot, base))
+		if age < ec.Profile.Worktree.EffectiveFetchInterval() && worktree.RefExists(ctx, repoRoot, base) {
+			return false, fmt.Sprintf("last fetched %d minute(s) ago", int(age.Minutes()))
+		}
+	}
+	return true, ""
+}
+
+// sparseDirs returns the directories a new worktree's checkout is limited
+// to, or nil for a full checkout.
+func sparseDirs(ec *pipeline.ExecutionContext) []string {
+	if ec.Profile.Worktree == nil {
+		return nil
+	}
+	return ec.Profile.Worktree.Sparse
+}
+
 // resolveWorktreesDir returns the absolute path of the directory under which
 // worktrees are created. If profile.Worktree.Dir is set, it is used (with ~
 // expansion; relative paths are resolved against repoRoot). Otherwise it
@@ -122,25 +555,11 @@ func resolveWorktreesDir(ec *pipeline.ExecutionContext, repoRoot string) (string
 	if ec.Profile.Worktree != nil {
 		dir = ec.Profile.Worktree.Dir
 	}
-	if dir == "" {
-		return filepath.Join(repoRoot, "worktrees"), nil
-	}
-
-	if strings.HasPrefix(dir, "~") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("expanding ~ in worktree dir: %w", err)
-		}
-		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
-	}
-	if !filepath.IsAbs(dir) {
-		dir = filepath.Join(repoRoot, dir)
-	}
-	return filepath.Clean(dir), nil
+	return worktree.ResolveDir(dir, repoRoot)
 }
 
-func gitRepoRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+func gitRepoRoot(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("not in a git repository")
@@ -148,16 +567,48 @@ func gitRepoRoot() (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
-func gitFetch(repoRoot, remote, ref string) error {
-	cmd := exec.Command("git", "-C", repoRoot, "fetch", remote, ref)
+func gitFetch(ctx context.Context, repoRoot, remote, ref string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoRoot, "fetch", remote, ref)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func gitWorktreeAdd(ctx context.Context, repoRoot, branchName, worktreePath, base string, noCheckout bool) error {
+	args := []string{"-C", repoRoot, "worktree", "add"}
+	if noCheckout {
+		args = append(args, "--no-checkout")
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, "-b", branchName, worktreePath, base)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func gitWorktreeAdd(repoRoot, branchName, worktreePath, base string) error {
-	cmd := exec.Command("git", "-C", repoRoot, "worktree", "add",
-		"-b", branchName, worktreePath, base)
+// gitSparseCheckout limits the worktree at worktreePath, created with
+// --no-checkout, to dirs in cone mode and then checks it out. Git keeps the
+// sparse-checkout settings in the worktree's own config
+// (extensions.worktreeConfig), so other worktrees are not affected.
+func gitSparseCheckout(ctx context.Context, worktreePath string, dirs []string) error {
+	for _, args := range [][]string{
+		append([]string{"sparse-checkout", "set", "--cone", "--"}, dirs...),
+		{"checkout"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", worktreePath}, args...)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// gitSubmoduleUpdate checks out the submodules of the worktree at
+// worktreePath, recursively. Their git directories are kept under the main
+// repository's .git/worktrees/<name>/modules.
+func gitSubmoduleUpdate(ctx context.Context, worktreePath string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", worktreePath, "submodule", "update", "--init", "--recursive")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
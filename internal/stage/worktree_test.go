@@ -1,12 +1,18 @@
 package stage
 
 import (
+	"context"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hiragram/agent-workspace/internal/mount"
 	"github.com/hiragram/agent-workspace/internal/pipeline"
 	"github.com/hiragram/agent-workspace/internal/profile"
+	"github.com/hiragram/agent-workspace/internal/worktree"
 )
 
 func TestRunOnCreateHook_ShellInvocation(t *testing.T) {
@@ -15,7 +21,7 @@ func TestRunOnCreateHook_ShellInvocation(t *testing.T) {
 
 	var capturedName string
 	var capturedArgs []string
-	execCommand = func(name string, args ...string) *exec.Cmd {
+	execCommand = func(_ context.Context, name string, args ...string) *exec.Cmd {
 		capturedName = name
 		capturedArgs = args
 		return exec.Command("true")
@@ -31,7 +37,7 @@ func TestRunOnCreateHook_ShellInvocation(t *testing.T) {
 		WorktreeBranch: "test-branch",
 	}
 
-	err := runOnCreateHook(ec, "/fake/repo")
+	err := runOnCreateHook(context.Background(), ec, "/fake/repo")
 	if err != nil {
 		t.Fatalf("runOnCreateHook() error: %v", err)
 	}
@@ -51,7 +57,7 @@ func TestRunOnCreateHook_SetsEnvironmentAndDir(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	// Use a real command that prints env vars
-	execCommand = func(name string, args ...string) *exec.Cmd {
+	execCommand = func(_ context.Context, name string, args ...string) *exec.Cmd {
 		// Create a real command that will succeed and let us inspect env via the Cmd struct
 		cmd := exec.Command("true")
 		return cmd
@@ -68,11 +74,11 @@ func TestRunOnCreateHook_SetsEnvironmentAndDir(t *testing.T) {
 	}
 
 	// Instead of mocking, we test with a real shell command that verifies env vars
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 	// Use a command that checks env vars exist
 	ec.Profile.Worktree.OnCreate = "test -n \"$AW_WORKTREE_PATH\" && test -n \"$AW_WORKTREE_BRANCH\" && test -n \"$AW_REPO_ROOT\" && test -n \"$AW_PROFILE_NAME\" && test -n \"$AW_ENVIRONMENT\""
 
-	err := runOnCreateHook(ec, "/fake/repo")
+	err := runOnCreateHook(context.Background(), ec, "/fake/repo")
 	if err != nil {
 		t.Fatalf("runOnCreateHook() error (env vars missing): %v", err)
 	}
@@ -83,7 +89,7 @@ func TestRunOnCreateHook_EnvVarValues(t *testing.T) {
 	defer func() { execCommand = origExecCommand }()
 
 	tmpDir := t.TempDir()
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -95,7 +101,7 @@ func TestRunOnCreateHook_EnvVarValues(t *testing.T) {
 		WorktreeBranch: "my-branch",
 	}
 
-	err := runOnCreateHook(ec, "/some/repo")
+	err := runOnCreateHook(context.Background(), ec, "/some/repo")
 	if err != nil {
 		t.Fatalf("runOnCreateHook() env var values mismatch: %v", err)
 	}
@@ -106,7 +112,7 @@ func TestRunOnCreateHook_WorkingDirectory(t *testing.T) {
 	defer func() { execCommand = origExecCommand }()
 
 	tmpDir := t.TempDir()
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	// pwd should match the worktree path
 	ec := &pipeline.ExecutionContext{
@@ -119,7 +125,7 @@ func TestRunOnCreateHook_WorkingDirectory(t *testing.T) {
 		WorktreeBranch: "branch",
 	}
 
-	err := runOnCreateHook(ec, "/repo")
+	err := runOnCreateHook(context.Background(), ec, "/repo")
 	if err != nil {
 		t.Fatalf("runOnCreateHook() working directory mismatch: %v", err)
 	}
@@ -129,7 +135,7 @@ func TestRunOnCreateHook_FailureReturnsError(t *testing.T) {
 	origExecCommand := execCommand
 	defer func() { execCommand = origExecCommand }()
 
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -141,7 +147,7 @@ func TestRunOnCreateHook_FailureReturnsError(t *testing.T) {
 		WorktreeBranch: "branch",
 	}
 
-	err := runOnCreateHook(ec, "/repo")
+	err := runOnCreateHook(context.Background(), ec, "/repo")
 	if err == nil {
 		t.Fatal("expected error from failing hook, got nil")
 	}
@@ -153,7 +159,7 @@ func TestRunOnEndHook_ShellInvocation(t *testing.T) {
 
 	var capturedName string
 	var capturedArgs []string
-	execCommand = func(name string, args ...string) *exec.Cmd {
+	execCommand = func(_ context.Context, name string, args ...string) *exec.Cmd {
 		capturedName = name
 		capturedArgs = args
 		return exec.Command("true")
@@ -170,7 +176,7 @@ func TestRunOnEndHook_ShellInvocation(t *testing.T) {
 		RepoRoot:       "/fake/repo",
 	}
 
-	err := RunOnEndHook(ec)
+	err := RunOnEndHook(context.Background(), ec)
 	if err != nil {
 		t.Fatalf("RunOnEndHook() error: %v", err)
 	}
@@ -188,7 +194,7 @@ func TestRunOnEndHook_SetsEnvironmentAndDir(t *testing.T) {
 	defer func() { execCommand = origExecCommand }()
 
 	tmpDir := t.TempDir()
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -201,7 +207,7 @@ func TestRunOnEndHook_SetsEnvironmentAndDir(t *testing.T) {
 		RepoRoot:       "/fake/repo",
 	}
 
-	err := RunOnEndHook(ec)
+	err := RunOnEndHook(context.Background(), ec)
 	if err != nil {
 		t.Fatalf("RunOnEndHook() error (env vars missing): %v", err)
 	}
@@ -212,7 +218,7 @@ func TestRunOnEndHook_EnvVarValues(t *testing.T) {
 	defer func() { execCommand = origExecCommand }()
 
 	tmpDir := t.TempDir()
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -225,7 +231,7 @@ func TestRunOnEndHook_EnvVarValues(t *testing.T) {
 		RepoRoot:       "/some/repo",
 	}
 
-	err := RunOnEndHook(ec)
+	err := RunOnEndHook(context.Background(), ec)
 	if err != nil {
 		t.Fatalf("RunOnEndHook() env var values mismatch: %v", err)
 	}
@@ -236,7 +242,7 @@ func TestRunOnEndHook_WorkingDirectory(t *testing.T) {
 	defer func() { execCommand = origExecCommand }()
 
 	tmpDir := t.TempDir()
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -249,7 +255,7 @@ func TestRunOnEndHook_WorkingDirectory(t *testing.T) {
 		RepoRoot:       "/repo",
 	}
 
-	err := RunOnEndHook(ec)
+	err := RunOnEndHook(context.Background(), ec)
 	if err != nil {
 		t.Fatalf("RunOnEndHook() working directory mismatch: %v", err)
 	}
@@ -259,7 +265,7 @@ func TestRunOnEndHook_FailureReturnsError(t *testing.T) {
 	origExecCommand := execCommand
 	defer func() { execCommand = origExecCommand }()
 
-	execCommand = exec.Command
+	execCommand = exec.CommandContext
 
 	ec := &pipeline.ExecutionContext{
 		Profile: profile.Profile{
@@ -272,7 +278,7 @@ func TestRunOnEndHook_FailureReturnsError(t *testing.T) {
 		RepoRoot:       "/repo",
 	}
 
-	err := RunOnEndHook(ec)
+	err := RunOnEndHook(context.Background(), ec)
 	if err == nil {
 		t.Fatal("expected error from failing hook, got nil")
 	}
@@ -337,3 +343,551 @@ func TestResolveWorktreesDir_TildeExpansion(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestWorktreeStage_Rollback(t *testing.T) {
+	var calls []string
+	var removed worktree.Worktree
+	var forced bool
+	origRemove, origExec := removeWorktree, execCommand
+	removeWorktree = func(_ context.Context, repoRoot string, w worktree.Worktree, force bool) error {
+		calls = append(calls, "remove")
+		removed, forced = w, force
+		return nil
+	}
+	execCommand = func(_ context.Context, name string, args ...string) *exec.Cmd {
+		calls = append(calls, args[len(args)-1])
+		return exec.Command("true")
+	}
+	defer func() { removeWorktree, execCommand = origRemove, origExec }()
+
+	path := filepath.Join(t.TempDir(), "calm-otter")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, ".git"), []byte("gitdir: /repo/.git/worktrees/calm-otter\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ec := &pipeline.ExecutionContext{
+		Profile:        profile.Profile{Worktree: &profile.WorktreeConfig{OnEnd: "./teardown.sh"}},
+		Observer:       observerFunc(func(pipeline.Event) {}),
+		OrigWorkDir:    "/repo",
+		WorkDir:        path,
+		WorktreePath:   path,
+		WorktreeBranch: "calm-otter",
+		WorktreeBase:   "origin/main",
+		RepoRoot:       "/repo",
+	}
+	if err := (&WorktreeStage{}).Rollback(context.Background(), ec); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "./teardown.sh" || calls[1] != "remove" {
+		t.Errorf("calls = %v, want the on-end hook before the removal", calls)
+	}
+	if removed.Path != path || removed.Branch != "calm-otter" || !forced {
+		t.Errorf("removed %+v (force %v), want the created worktree forcibly", removed, forced)
+	}
+	if ec.WorktreePath != "" || ec.WorkDir != "/repo" {
+		t.Errorf("WorktreePath = %q, WorkDir = %q; want the worktree cleared so on-end is skipped", ec.WorktreePath, ec.WorkDir)
+	}
+}
+
+func TestWorktreeStage_RollbackWithoutWorktree(t *testing.T) {
+	orig := removeWorktree
+	removeWorktree = func(context.Context, string, worktree.Worktree, bool) error {
+		t.Error("removeWorktree called without a worktree")
+		return nil
+	}
+	defer func() { removeWorktree = orig }()
+
+	if err := (&WorktreeStage{}).Rollback(context.Background(), &pipeline.ExecutionContext{}); err != nil {
+		t.Errorf("Rollback() error: %v", err)
+	}
+}
+
+// setupReuseRepo creates a repository with aw-style worktrees named names
+// under <repo>/worktrees and makes it the working directory.
+func setupReuseRepo(t *testing.T, names ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "initial")
+	for _, name := range names {
+		git("worktree", "add", "-q", "-b", name, filepath.Join(repo, "worktrees", name), "main")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return repo
+}
+
+func TestWorktreeStage_RunReusesNamedWorktree(t *testing.T) {
+	repo := setupReuseRepo(t, "calm-otter", "bold-heron")
+	path := filepath.Join(repo, "worktrees", "calm-otter")
+
+	var events []pipeline.Event
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Worktree: &profile.WorktreeConfig{Base: "main", OnCreate: "touch on-create-ran"},
+		},
+		Observer:      observerFunc(func(e pipeline.Event) { events = append(events, e) }),
+		OrigWorkDir:   repo,
+		ReuseWorktree: "calm-otter",
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if !ec.WorktreeReused || ec.WorkDir != path || ec.WorktreeBranch != "calm-otter" || ec.WorktreeBase != "main" {
+		t.Errorf("ec = {Reused: %v, WorkDir: %q, Branch: %q, Base: %q}, want the existing calm-otter worktree",
+			ec.WorktreeReused, ec.WorkDir, ec.WorktreeBranch, ec.WorktreeBase)
+	}
+	if _, err := os.Stat(filepath.Join(path, "on-create-ran")); err == nil {
+		t.Error("on-create ran for a reused worktree")
+	}
+	var reused bool
+	for _, e := range events {
+		reused = reused || (e.Kind == pipeline.WorktreeReused && e.Fields["path"] == path)
+	}
+	if !reused {
+		t.Errorf("events = %v, want a WorktreeReused event", events)
+	}
+
+	// Rolling back leaves the worktree in place.
+	if err := (&WorktreeStage{}).Rollback(context.Background(), ec); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("reused worktree removed by rollback: %v", err)
+	}
+	if ec.WorktreePath != "" || ec.WorkDir != repo {
+		t.Errorf("WorktreePath = %q, WorkDir = %q; want the worktree cleared", ec.WorktreePath, ec.WorkDir)
+	}
+}
+
+func TestWorktreeStage_RunUnknownWorktree(t *testing.T) {
+	setupReuseRepo(t, "calm-otter")
+
+	ec := &pipeline.ExecutionContext{
+		Profile:       profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main"}},
+		Observer:      observerFunc(func(pipeline.Event) {}),
+		ReuseWorktree: "nope",
+	}
+	err := (&WorktreeStage{}).Run(context.Background(), ec)
+	if err == nil || !strings.Contains(err.Error(), `worktree "nope" not found`) {
+		t.Errorf("Run() error = %v, want not found", err)
+	}
+}
+
+func TestWorktreeStage_ReusePolicies(t *testing.T) {
+	repo := setupReuseRepo(t, "calm-otter", "bold-heron")
+	old := filepath.Join(repo, "worktrees", "calm-otter")
+	gitDir := filepath.Join(repo, ".git", "worktrees", "calm-otter")
+	past := time.Now().Add(-time.Hour)
+	for _, p := range []string{old, filepath.Join(gitDir, "index"), filepath.Join(gitDir, "HEAD"), filepath.Join(gitDir, "logs", "HEAD")} {
+		if err := os.Chtimes(p, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newEC := func(reuse profile.WorktreeReuse) *pipeline.ExecutionContext {
+		return &pipeline.ExecutionContext{
+			Profile:  profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main", Reuse: reuse}},
+			Observer: observerFunc(func(pipeline.Event) {}),
+		}
+	}
+
+	t.Run("latest", func(t *testing.T) {
+		ec := newEC(profile.WorktreeReuseLatest)
+		if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		if ec.WorktreeBranch != "bold-heron" {
+			t.Errorf("resumed %q, want the most recently used bold-heron", ec.WorktreeBranch)
+		}
+	})
+
+	t.Run("prompt", func(t *testing.T) {
+		var offered []string
+		s := &WorktreeStage{Choose: func(wts []worktree.Worktree) (worktree.Worktree, bool) {
+			for _, w := range wts {
+				offered = append(offered, w.Name())
+			}
+			return wts[1], true
+		}}
+		ec := newEC(profile.WorktreeReusePrompt)
+		if err := s.Run(context.Background(), ec); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		if strings.Join(offered, ",") != "bold-heron,calm-otter" {
+			t.Errorf("offered %v, want the most recently used first", offered)
+		}
+		if ec.WorktreeBranch != "calm-otter" || !ec.WorktreeReused {
+			t.Errorf("resumed %q (reused %v), want the chosen calm-otter", ec.WorktreeBranch, ec.WorktreeReused)
+		}
+	})
+
+	t.Run("plan does not prompt", func(t *testing.T) {
+		s := &WorktreeStage{Choose: func([]worktree.Worktree) (worktree.Worktree, bool) {
+			t.Error("Plan called Choose")
+			return worktree.Worktree{}, false
+		}}
+		ec := newEC(profile.WorktreeReusePrompt)
+		actions, err := s.Plan(context.Background(), ec)
+		if err != nil {
+			t.Skipf("Plan() error (no word list?): %v", err)
+		}
+		if len(actions) == 0 || !strings.Contains(actions[0].Description, "2 existing worktree(s)") {
+			t.Errorf("actions = %v, want a note about the prompt first", actions)
+		}
+		if ec.WorktreeReused {
+			t.Error("Plan resumed a worktree under reuse: prompt")
+		}
+	})
+}
+
+func TestWorktreeStage_RunNewBranch(t *testing.T) {
+	repo := setupReuseRepo(t, "calm-otter")
+
+	ec := &pipeline.ExecutionContext{
+		Profile:   profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main"}},
+		Observer:  observerFunc(func(pipeline.Event) {}),
+		NewBranch: "fix/login",
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if ec.WorktreeBranch != "fix/login" || ec.WorktreePath != filepath.Join(repo, "worktrees", "fix-login") {
+		t.Errorf("created %q at %q, want fix/login in worktrees/fix-login", ec.WorktreeBranch, ec.WorktreePath)
+	}
+
+	ec = &pipeline.ExecutionContext{
+		Profile:   profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main"}},
+		Observer:  observerFunc(func(pipeline.Event) {}),
+		NewBranch: "calm-otter",
+	}
+	err := (&WorktreeStage{}).Run(context.Background(), ec)
+	if err == nil || !strings.Contains(err.Error(), `branch "calm-otter" already exists`) {
+		t.Errorf("Run() with a taken branch: error = %v", err)
+	}
+}
+
+func TestWorktreeStage_RunCopiesFilesBeforeOnCreate(t *testing.T) {
+	repo := setupReuseRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, ".env"), []byte("TOKEN=x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{Worktree: &profile.WorktreeConfig{
+			Base:     "main",
+			Copy:     []profile.CopyEntry{{Path: ".env"}},
+			OnCreate: "cp .env copied-before-hook",
+		}},
+		Observer: observerFunc(func(pipeline.Event) {}),
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(ec.WorktreePath, "copied-before-hook")); err != nil || string(got) != "TOKEN=x\n" {
+		t.Errorf("on-create saw .env = %q (%v), want it copied first", got, err)
+	}
+}
+
+func TestWorktreeStage_RunSparseWithSubmodules(t *testing.T) {
+	repo := setupReuseRepo(t)
+	// Submodules are cloned from a local path, which git only allows when
+	// asked to.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	lib := filepath.Join(t.TempDir(), "lib")
+	git(filepath.Dir(lib), "init", "-q", "-b", "main", lib)
+	git(lib, "commit", "-q", "--allow-empty", "-m", "lib")
+	for _, f := range []string{"services/api/main.go", "services/web/index.js", "README"} {
+		if err := os.MkdirAll(filepath.Join(repo, filepath.Dir(f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(repo, "submodule", "add", "-q", lib, "services/api/lib")
+	git(repo, "add", ".")
+	git(repo, "commit", "-q", "-m", "services")
+
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{Worktree: &profile.WorktreeConfig{
+			Base:       "main",
+			Sparse:     []string{"services/api"},
+			Submodules: profile.WorktreeSubmodulesRecursive,
+		}},
+		Observer: observerFunc(func(pipeline.Event) {}),
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	for _, f := range []string{"README", "services/api/main.go", "services/api/lib/.git"} {
+		if _, err := os.Stat(filepath.Join(ec.WorktreePath, f)); err != nil {
+			t.Errorf("%s is not checked out: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ec.WorktreePath, "services/web")); err == nil {
+		t.Error("services/web is checked out, want it left out of the sparse checkout")
+	}
+
+	// The container mounts the main .git directory, which must hold the
+	// submodule's repository for git to work inside it.
+	mainGitDir, err := mount.DetectWorktree(ec.WorktreePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("git", "-C", filepath.Join(ec.WorktreePath, "services/api/lib"), "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainGitDir, _ = filepath.EvalSymlinks(mainGitDir)
+	if libGitDir := strings.TrimSpace(string(out)); !mount.IsSubpath(mainGitDir, libGitDir) {
+		t.Errorf("submodule git dir %s is outside the mounted %s", libGitDir, mainGitDir)
+	}
+
+	if err := (&WorktreeStage{}).Rollback(context.Background(), ec); err != nil {
+		t.Fatalf("Rollback() error: %v", err)
+	}
+}
+
+func TestWorktreeStage_RunChecksOutPullRequest(t *testing.T) {
+	repo := setupReuseRepo(t)
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	// A bare origin that has the pull request head under refs/pull/.
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	git("init", "-q", "--bare", origin)
+	git("remote", "add", "origin", origin)
+	git("push", "-q", "origin", "main")
+	git("checkout", "-q", "-b", "contributor")
+	git("commit", "-q", "--allow-empty", "-m", "fix")
+	head := git("rev-parse", "HEAD")
+	git("push", "-q", "origin", "HEAD:refs/pull/7/head")
+	git("checkout", "-q", "main")
+	git("branch", "-q", "-D", "contributor")
+
+	ec := &pipeline.ExecutionContext{
+		Profile: profile.Profile{
+			Worktree: &profile.WorktreeConfig{OnCreate: `echo "$AW_PR_NUMBER" > pr.txt`},
+		},
+		Observer: observerFunc(func(pipeline.Event) {}),
+		PRNumber: 7,
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if ec.WorktreeBranch != "pr-7" || ec.WorktreeBase != "origin/main" {
+		t.Errorf("branch %q from %q, want pr-7 with base origin/main", ec.WorktreeBranch, ec.WorktreeBase)
+	}
+	if got := git("-C", ec.WorktreePath, "rev-parse", "HEAD"); got != head {
+		t.Errorf("worktree HEAD = %s, want the pull request head %s", got, head)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ec.WorktreePath, "pr.txt")); strings.TrimSpace(string(data)) != "7" {
+		t.Errorf("on-create saw AW_PR_NUMBER=%q, want 7", data)
+	}
+}
+
+func TestWorktreeStage_FetchPolicy(t *testing.T) {
+	repo := setupReuseRepo(t)
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	git("init", "-q", "--bare", origin)
+	git("remote", "add", "origin", origin)
+	git("push", "-q", "origin", "main")
+	git("fetch", "-q", "origin")
+	git("branch", "feature/foo", "main")
+
+	plan := func(ec *pipeline.ExecutionContext) string {
+		t.Helper()
+		ec.Observer = observerFunc(func(pipeline.Event) {})
+		actions, err := (&WorktreeStage{}).Plan(context.Background(), ec)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		return actions[0].Description
+	}
+	wtc := func(base string, fetch profile.WorktreeFetch) profile.Profile {
+		return profile.Profile{Worktree: &profile.WorktreeConfig{Base: base, Fetch: fetch}}
+	}
+	tests := []struct {
+		name string
+		ec   *pipeline.ExecutionContext
+		want string
+	}{
+		{"always", &pipeline.ExecutionContext{Profile: wtc("origin/main", "")}, "fetch origin/main"},
+		{"offline", &pipeline.ExecutionContext{Profile: wtc("origin/main", ""), Offline: true}, "use the local origin/main without fetching (--offline)"},
+		{"never", &pipeline.ExecutionContext{Profile: wtc("origin/main", profile.WorktreeFetchNever)}, "use the local origin/main without fetching (worktree.fetch: never)"},
+		{"fresh", &pipeline.ExecutionContext{Profile: wtc("origin/main", profile.WorktreeFetchIfStale)}, "use the local origin/main without fetching (last fetched 0 minute(s) ago)"},
+		{"local branch with a slash", &pipeline.ExecutionContext{Profile: wtc("feature/foo", "")}, "create worktree"},
+	}
+	for _, tt := range tests {
+		if got := plan(tt.ec); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: first action %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// A failed fetch falls back to the local ref with a warning.
+	git("remote", "set-url", "origin", filepath.Join(t.TempDir(), "gone.git"))
+	var warnings []string
+	ec := &pipeline.ExecutionContext{
+		Profile: wtc("origin/main", ""),
+		Observer: observerFunc(func(e pipeline.Event) {
+			if e.Kind == pipeline.Warning {
+				warnings = append(warnings, e.Message)
+			}
+		}),
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "using the local origin/main") {
+		t.Errorf("warnings = %q, want one about using the local ref", warnings)
+	}
+
+	// Without a fetch, the base must exist locally.
+	ec = &pipeline.ExecutionContext{Profile: wtc("origin/gone", ""), Offline: true, Observer: observerFunc(func(pipeline.Event) {})}
+	err := (&WorktreeStage{}).Run(context.Background(), ec)
+	if err == nil || !strings.Contains(err.Error(), "origin/gone does not exist locally") {
+		t.Errorf("Run() with a missing base: error = %v", err)
+	}
+}
+
+// stubGh puts a gh on PATH that prints out for `gh issue view`.
+func stubGh(t *testing.T, out string) {
+	t.Helper()
+	bin := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1 $2\" = \"issue view\" ] || exit 1\ncat <<'EOF'\n" + out + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(bin, "gh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestWorktreeStage_RunForIssue(t *testing.T) {
+	repo := setupReuseRepo(t)
+	stubGh(t, `{"number": 123, "title": "Login fails on Safari", "body": "Steps to reproduce...", "url": "https://github.com/o/r/issues/123"}`)
+
+	ec := &pipeline.ExecutionContext{
+		Profile:     profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main"}},
+		Observer:    observerFunc(func(pipeline.Event) {}),
+		IssueNumber: 123,
+	}
+	if err := (&WorktreeStage{}).Run(context.Background(), ec); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if ec.WorktreeBranch != "issue-123-login-fails-on-safari" {
+		t.Errorf("branch = %q, want one named after the issue", ec.WorktreeBranch)
+	}
+	if want := filepath.Join(repo, "worktrees", "issue-123-login-fails-on-safari", promptFileName); ec.PromptFile != want {
+		t.Errorf("PromptFile = %q, want %q", ec.PromptFile, want)
+	}
+	data, err := os.ReadFile(ec.PromptFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Login fails on Safari (#123)\n\nhttps://github.com/o/r/issues/123\n\nSteps to reproduce...\n"
+	if string(data) != want {
+		t.Errorf("prompt file = %q, want %q", data, want)
+	}
+	if env := strings.Join(ec.TaskEnv(), " "); env != "AW_ISSUE_NUMBER=123" {
+		t.Errorf("TaskEnv() = %q", env)
+	}
+
+	// The prompt file is excluded from git, so the worktree stays clean for
+	// `aw worktree prune` and `git add -A` leaves it out.
+	st, err := worktree.GetStatus(context.Background(), repo, worktree.Worktree{Path: ec.WorktreePath})
+	if err != nil {
+		t.Fatalf("GetStatus() error: %v", err)
+	}
+	if st.Dirty {
+		t.Error("the issue worktree is dirty")
+	}
+	if _, err := writePrompt(context.Background(), ec.WorktreePath, issue{Number: 123}); err != nil {
+		t.Fatalf("writePrompt() again: %v", err)
+	}
+	exclude, err := os.ReadFile(filepath.Join(repo, ".git", "info", "exclude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(exclude), "/"+promptFileName); n != 1 {
+		t.Errorf("info/exclude lists the prompt file %d times:\n%s", n, exclude)
+	}
+}
+
+func TestWorktreeStage_RollbackAfterFailedAdd(t *testing.T) {
+	repo := setupReuseRepo(t)
+	// A failing post-checkout hook makes `git worktree add` exit non-zero
+	// after it created the branch and the directory.
+	hooks := t.TempDir()
+	if err := os.WriteFile(filepath.Join(hooks, "post-checkout"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", repo, "config", "core.hooksPath", hooks).CombinedOutput(); err != nil {
+		t.Fatalf("git config: %v\n%s", err, out)
+	}
+
+	ec := &pipeline.ExecutionContext{
+		Profile:     profile.Profile{Worktree: &profile.WorktreeConfig{Base: "main", Branch: "agent/{{.Profile}}"}},
+		ProfileName: "x",
+		Observer:    observerFunc(func(pipeline.Event) {}),
+		OrigWorkDir: repo,
+	}
+	err := pipeline.New(&WorktreeStage{}).Execute(context.Background(), ec)
+	if err == nil || !strings.Contains(err.Error(), "creating worktree") {
+		t.Fatalf("Execute() error = %v, want the failed add", err)
+	}
+
+	path := filepath.Join(repo, "worktrees", "agent-x")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree directory %s left behind (%v)", path, err)
+	}
+	if worktree.BranchExists(context.Background(), repo, "agent/x") {
+		t.Error("branch agent/x left behind")
+	}
+	if ec.WorktreePath != "" || ec.WorkDir != repo {
+		t.Errorf("WorktreePath = %q, WorkDir = %q; want the worktree cleared", ec.WorktreePath, ec.WorkDir)
+	}
+}
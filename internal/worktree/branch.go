@@ -0,0 +1,157 @@
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultBranchTemplate names branches with three random words, e.g.
+// "calm-otter-dawn".
+const DefaultBranchTemplate = "{{.Words}}"
+
+const (
+	maxNameAttempts = 20 // bounds the search for an unused branch name
+	maxSlugLen      = 40
+)
+
+// BranchData is what a worktree.branch template can refer to.
+type BranchData struct {
+	User    string // login name of the current user, made safe for a ref
+	Date    string // today's date, YYYY-MM-DD
+	Words   string // random words joined by hyphens, as GenerateName returns
+	Issue   string // issue number the run is for; empty without one
+	Title   string // issue title made safe for a ref, e.g. "fix-login-on-safari"
+	PR      string // pull request number the run is for; empty without one
+	Profile string // name of the profile being run
+}
+
+// NewBranchData returns the template data for a run of profileName.
+func NewBranchData(profileName string) BranchData {
+	return BranchData{
+		User:    refSafe(currentUser()),
+		Date:    time.Now().Format("2006-01-02"),
+		Words:   GenerateName(),
+		Profile: profileName,
+	}
+}
+
+// RenderBranch expands the branch name template tmpl with data.
+func RenderBranch(tmpl string, data BranchData) (string, error) {
+	t, err := template.New("branch").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing branch template: %w", err)
+	}
+	if data.Issue == "" && (strings.Contains(tmpl, ".Issue") || strings.Contains(tmpl, ".Title")) {
+		return "", fmt.Errorf("branch template %q uses the issue, but the run is not for an issue", tmpl)
+	}
+	if data.PR == "" && strings.Contains(tmpl, ".PR") {
+		return "", fmt.Errorf("branch template %q uses {{.PR}}, but the run is not for a pull request", tmpl)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("expanding branch template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// NewBranch expands tmpl into a branch name that is not taken in repoRoot
+// and whose worktree directory under dir does not exist yet. On a collision
+// it draws new words, or appends "-2", "-3", ... to a template without
+// {{.Words}}.
+func NewBranch(ctx context.Context, repoRoot, dir, tmpl string, data BranchData) (string, error) {
+	random := strings.Contains(tmpl, ".Words")
+	for attempt := 1; attempt <= maxNameAttempts; attempt++ {
+		if attempt > 1 && random {
+			data.Words = GenerateName()
+		}
+		name, err := RenderBranch(tmpl, data)
+		if err != nil {
+			return "", err
+		}
+		if attempt > 1 && !random {
+			name = fmt.Sprintf("%s-%d", name, attempt)
+		}
+		if err := CheckBranchName(ctx, repoRoot, name); err != nil {
+			return "", fmt.Errorf("branch template %q: %w", tmpl, err)
+		}
+		if !BranchExists(ctx, repoRoot, name) && !exists(filepath.Join(dir, DirName(name))) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no unused branch name for template %q after %d attempts", tmpl, maxNameAttempts)
+}
+
+// CheckBranchName returns an error if name is not a valid branch name.
+func CheckBranchName(ctx context.Context, repoRoot, name string) error {
+	if err := git(ctx, repoRoot, "check-ref-format", "--branch", name).Run(); err != nil {
+		return fmt.Errorf("%q is not a valid branch name", name)
+	}
+	return nil
+}
+
+// BranchExists reports whether repoRoot has a local branch called name.
+func BranchExists(ctx context.Context, repoRoot, name string) bool {
+	return git(ctx, repoRoot, "show-ref", "--verify", "--quiet", "refs/heads/"+name).Run() == nil
+}
+
+// DirName returns the worktree directory name for branch. Slashes become
+// hyphens so that every worktree sits directly in the worktrees directory.
+func DirName(branch string) string {
+	return strings.ReplaceAll(branch, "/", "-")
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Slug turns an issue title into a short branch name component: lowercase
+// words joined by hyphens, at most 40 characters, e.g. "fix-login-on-safari".
+func Slug(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	slug := ""
+	for _, w := range words {
+		next := w
+		if slug != "" {
+			next = slug + "-" + w
+		}
+		if len(next) > maxSlugLen {
+			break
+		}
+		slug = next
+	}
+	if slug == "" && len(words) > 0 {
+		slug = words[0][:min(len(words[0]), maxSlugLen)]
+	}
+	return slug
+}
+
+// refSafe lowercases s and replaces anything but letters, digits, '.', '_'
+// and '-' with '-', e.g. for a login name such as "DOMAIN\jane doe".
+func refSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, s)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
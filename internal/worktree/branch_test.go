@@ -0,0 +1,101 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderBranch(t *testing.T) {
+	data := BranchData{User: "jane", Date: "2026-10-16", Words: "calm-otter-dawn", Issue: "42", Title: "fix-login", PR: "7", Profile: "claude"}
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{DefaultBranchTemplate, "calm-otter-dawn", ""},
+		{"agent/{{.User}}/{{.Date}}-{{.Words}}", "agent/jane/2026-10-16-calm-otter-dawn", ""},
+		{"issue-{{.Issue}}-{{.Title}}", "issue-42-fix-login", ""},
+		{"pr-{{.PR}}-{{.Profile}}", "pr-7-claude", ""},
+		{"{{.Usr}}", "", "can't evaluate field Usr"},
+		{"{{.Words", "", "parsing branch template"},
+	}
+	for _, tt := range tests {
+		got, err := RenderBranch(tt.tmpl, data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RenderBranch(%q) error = %v, want containing %q", tt.tmpl, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("RenderBranch(%q) = %q, %v; want %q", tt.tmpl, got, err, tt.want)
+		}
+	}
+
+	data.Issue, data.PR = "", ""
+	if _, err := RenderBranch("{{.Title}}", data); err == nil || !strings.Contains(err.Error(), "not for an issue") {
+		t.Errorf("RenderBranch without an issue: error = %v", err)
+	}
+	if _, err := RenderBranch("pr-{{.PR}}", data); err == nil || !strings.Contains(err.Error(), "not for a pull request") {
+		t.Errorf("RenderBranch without a pull request: error = %v", err)
+	}
+}
+
+func TestNewBranch_Collisions(t *testing.T) {
+	repo := setupRepo(t)
+	dir := filepath.Join(repo, "worktrees")
+	addWorktree(t, repo, "taken")
+	run(t, repo, "git", "branch", "fixed-name")
+
+	// A template without words gets a numeric suffix.
+	data := BranchData{Profile: "fixed-name"}
+	if got, err := NewBranch(context.Background(), repo, dir, "{{.Profile}}", data); err != nil || got != "fixed-name-2" {
+		t.Errorf("NewBranch(fixed) = %q, %v; want fixed-name-2", got, err)
+	}
+
+	// A leftover directory counts as taken too.
+	if err := os.MkdirAll(filepath.Join(dir, "agent-x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewBranch(context.Background(), repo, dir, "agent/{{.Profile}}", BranchData{Profile: "x"}); err != nil || got != "agent/x-2" {
+		t.Errorf("NewBranch(agent/x) = %q, %v; want agent/x-2", got, err)
+	}
+
+	// Random words are drawn again.
+	if got, err := NewBranch(context.Background(), repo, dir, "{{.Words}}", BranchData{Words: "taken"}); err != nil || got == "taken" {
+		t.Errorf("NewBranch(words) = %q, %v; want a fresh name", got, err)
+	}
+
+	if _, err := NewBranch(context.Background(), repo, dir, "bad..name", data); err == nil || !strings.Contains(err.Error(), "not a valid branch name") {
+		t.Errorf("NewBranch(bad..name) error = %v", err)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Fix login on Safari 17!":                                       "fix-login-on-safari-17",
+		"[UI] Button  -- misaligned":                                    "ui-button-misaligned",
+		"Crash when the configuration file contains unicode characters": "crash-when-the-configuration-file",
+		"🙂": "",
+	}
+	for title, want := range tests {
+		if got := Slug(title); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestRefSafe(t *testing.T) {
+	if got := refSafe(`DOMAIN\Jane Doe`); got != "domain-jane-doe" {
+		t.Errorf("refSafe = %q, want domain-jane-doe", got)
+	}
+}
+
+func TestDirName(t *testing.T) {
+	if got := DirName("agent/jane/2026-10-16-calm"); got != "agent-jane-2026-10-16-calm" {
+		t.Errorf("DirName = %q", got)
+	}
+}
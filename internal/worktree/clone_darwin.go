@@ -0,0 +1,12 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is not supported on macOS: clonefile(2) creates the destination
+// itself, so files are copied normally.
+func cloneFile(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,19 @@
+package worktree
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl from <linux/fs.h>, which makes dst share the
+// data blocks of src on filesystems such as Btrfs and XFS.
+const ficlone = 0x40049409
+
+// cloneFile makes dst a reflink of src, or returns an error if the
+// filesystem cannot.
+func cloneFile(dst, src *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		return errno
+	}
+	return nil
+}
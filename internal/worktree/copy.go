@@ -0,0 +1,179 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CopyFiles brings the files of repoRoot matching pattern, a glob relative
+// to repoRoot, into the worktree at dst, at the same relative paths.
+// Directories are copied recursively. With link set, each match is
+// symlinked instead, which suits large caches such as node_modules.
+//
+// Files that already exist in dst are left alone, so checked-out files are
+// never overwritten. Matches inside .git, and matches that contain dst
+// itself (such as the worktrees directory), are skipped. CopyFiles returns
+// the number of files copied or links created.
+func CopyFiles(repoRoot, dst, pattern string, link bool) (int, error) {
+	sources, err := copySources(repoRoot, dst, pattern)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, src := range sources {
+		rel, _ := filepath.Rel(repoRoot, src)
+		target := filepath.Join(dst, rel)
+		if link {
+			created, err := symlink(src, target)
+			if err != nil {
+				return n, err
+			}
+			if created {
+				n++
+			}
+			continue
+		}
+		copied, err := copyTree(src, target)
+		n += copied
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// LinkSources returns the files of repoRoot that CopyFiles links into the
+// worktree at dst for pattern with link set. The links are absolute, so a
+// container needs these paths mounted to follow them.
+func LinkSources(repoRoot, dst, pattern string) ([]string, error) {
+	return copySources(repoRoot, dst, pattern)
+}
+
+// copySources returns the matches of pattern in repoRoot that CopyFiles
+// brings into dst.
+func copySources(repoRoot, dst, pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(repoRoot, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("worktree.copy %q: %w", pattern, err)
+	}
+	var sources []string
+	for _, src := range matches {
+		rel, err := filepath.Rel(repoRoot, src)
+		if err != nil || !filepath.IsLocal(rel) || skipCopy(rel, src, dst) {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// skipCopy reports whether the match src, at rel in the repository, must not
+// be copied into dst.
+func skipCopy(rel, src, dst string) bool {
+	if first, _, _ := strings.Cut(filepath.ToSlash(rel), "/"); first == ".git" {
+		return true
+	}
+	inside, err := filepath.Rel(src, dst)
+	return err == nil && filepath.IsLocal(inside)
+}
+
+// symlink links target to src unless target exists, and reports whether it
+// did.
+func symlink(src, target string) (bool, error) {
+	if _, err := os.Lstat(target); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Symlink(src, target); err != nil {
+		return false, fmt.Errorf("linking %s: %w", target, err)
+	}
+	return true, nil
+}
+
+// copyTree copies the file, symlink or directory at src to target, skipping
+// anything that already exists there, and returns the number of files
+// written.
+func copyTree(src, target string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(target, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(to, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			created, err := symlink(dest, to)
+			if created {
+				n++
+			}
+			return err
+		case d.Type().IsRegular():
+			copied, err := copyFile(path, to, info.Mode().Perm())
+			if copied {
+				n++
+			}
+			return err
+		default:
+			return nil // sockets, FIFOs and devices are not copied
+		}
+	})
+	if err != nil {
+		return n, fmt.Errorf("copying %s: %w", src, err)
+	}
+	return n, nil
+}
+
+// copyFile copies the regular file src to dst with the given permissions,
+// unless dst exists, and reports whether it did. The copy is a reflink,
+// sharing blocks with src until either is changed, where the filesystem
+// supports it.
+func copyFile(src, dst string, perm fs.FileMode) (bool, error) {
+	if _, err := os.Lstat(dst); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if cloneFile(out, in) != nil {
+		if _, err := io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return false, err
+		}
+	}
+	return true, out.Close()
+}
@@ -0,0 +1,88 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyFiles(t *testing.T) {
+	repo := t.TempDir()
+	dst := filepath.Join(repo, "worktrees", "calm-otter-dawn")
+	for _, dir := range []string{dst, filepath.Join(repo, ".git"), filepath.Join(repo, "certs", "dev")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(repo, ".env"), "SECRET=1\n")
+	writeFile(t, filepath.Join(repo, ".envrc"), "dotenv\n")
+	writeFile(t, filepath.Join(repo, ".git", "config"), "")
+	writeFile(t, filepath.Join(repo, "certs", "dev", "key.pem"), "key\n")
+	if err := os.Symlink("dev/key.pem", filepath.Join(repo, "certs", "current.pem")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dst, ".envrc"), "checked out\n")
+
+	n, err := CopyFiles(repo, dst, ".env*", false)
+	if err != nil {
+		t.Fatalf("CopyFiles(.env*): %v", err)
+	}
+	if n != 1 {
+		t.Errorf("CopyFiles(.env*) = %d, want 1 (.envrc exists)", n)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, ".env")); string(got) != "SECRET=1\n" {
+		t.Errorf(".env = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, ".envrc")); string(got) != "checked out\n" {
+		t.Errorf(".envrc = %q, want the existing file kept", got)
+	}
+
+	n, err = CopyFiles(repo, dst, "certs", false)
+	if err != nil {
+		t.Fatalf("CopyFiles(certs): %v", err)
+	}
+	if n != 2 {
+		t.Errorf("CopyFiles(certs) = %d, want 2", n)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "certs", "current.pem")); err != nil || target != "dev/key.pem" {
+		t.Errorf("certs/current.pem links to %q (%v), want dev/key.pem", target, err)
+	}
+
+	// The worktrees directory and .git are never copied.
+	n, err = CopyFiles(repo, dst, "*", false)
+	if err != nil {
+		t.Fatalf("CopyFiles(*): %v", err)
+	}
+	if n != 0 {
+		t.Errorf("CopyFiles(*) = %d, want 0", n)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".git")); err == nil {
+		t.Error(".git was copied")
+	}
+}
+
+func TestCopyFiles_Link(t *testing.T) {
+	repo := t.TempDir()
+	dst := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, "node_modules", "left-pad"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(repo, "node_modules", "left-pad", "index.js"), "")
+
+	n, err := CopyFiles(repo, dst, "node_modules", true)
+	if err != nil {
+		t.Fatalf("CopyFiles: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("CopyFiles = %d, want 1", n)
+	}
+	target, err := os.Readlink(filepath.Join(dst, "node_modules"))
+	if err != nil || target != filepath.Join(repo, "node_modules") {
+		t.Errorf("node_modules links to %q (%v), want %s", target, err, filepath.Join(repo, "node_modules"))
+	}
+
+	// A second run leaves the link alone.
+	if n, err := CopyFiles(repo, dst, "node_modules", true); err != nil || n != 0 {
+		t.Errorf("second CopyFiles = %d, %v; want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,58 @@
+package worktree
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Remotes returns the names of the remotes configured in repoRoot.
+func Remotes(ctx context.Context, repoRoot string) ([]string, error) {
+	out, err := git(ctx, repoRoot, "remote").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// SplitRemoteRef splits ref into the remote it belongs to and the branch on
+// that remote, e.g. "origin/release/1.2" into "origin" and "release/1.2".
+// remote is empty for a ref that does not start with the name of one of
+// remotes, such as a local branch "feature/foo". The longest matching name
+// wins, as remote names may contain slashes.
+func SplitRemoteRef(remotes []string, ref string) (remote, branch string) {
+	for _, r := range remotes {
+		if rest, ok := strings.CutPrefix(ref, r+"/"); ok && rest != "" && len(r) > len(remote) {
+			remote, branch = r, rest
+		}
+	}
+	return remote, branch
+}
+
+// LastFetched returns when the remote-tracking ref, e.g. "origin/main", was
+// last updated by a fetch (or a push), from its reflog, or the zero time if
+// it never was. Fetches of other refs, such as a pull request, do not count,
+// and neither does a fetch that found the ref unchanged.
+func LastFetched(ctx context.Context, repoRoot, ref string) time.Time {
+	out, err := git(ctx, repoRoot, "log", "-g", "-1", "--date=unix", "--format=%gd", "refs/remotes/"+ref, "--").Output()
+	if err != nil {
+		return time.Time{}
+	}
+	// e.g. "origin/main@{1767225600}"
+	selector := strings.TrimSpace(string(out))
+	i := strings.LastIndex(selector, "@{")
+	if i < 0 || !strings.HasSuffix(selector, "}") {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(selector[i+2:len(selector)-1], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// RefExists reports whether ref names a commit in repoRoot.
+func RefExists(ctx context.Context, repoRoot, ref string) bool {
+	return git(ctx, repoRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
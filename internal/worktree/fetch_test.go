@@ -0,0 +1,94 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitRemoteRef(t *testing.T) {
+	remotes := []string{"origin", "upstream", "corp/mirror"}
+	tests := []struct {
+		ref, remote, branch string
+	}{
+		{"origin/main", "origin", "main"},
+		{"upstream/release/1.2", "upstream", "release/1.2"},
+		{"corp/mirror/main", "corp/mirror", "main"},
+		{"feature/foo", "", ""},
+		{"main", "", ""},
+		{"origin/", "", ""},
+	}
+	for _, tt := range tests {
+		remote, branch := SplitRemoteRef(remotes, tt.ref)
+		if remote != tt.remote || branch != tt.branch {
+			t.Errorf("SplitRemoteRef(%q) = %q, %q; want %q, %q", tt.ref, remote, branch, tt.remote, tt.branch)
+		}
+	}
+}
+
+func TestLastFetchedAndRefExists(t *testing.T) {
+	repo := setupRepo(t)
+	if got := LastFetched(context.Background(), repo, "origin/nope"); !got.IsZero() {
+		t.Errorf("LastFetched(origin/nope) = %v, want zero", got)
+	}
+	if RefExists(context.Background(), repo, "origin/nope") {
+		t.Error("RefExists(origin/nope) = true")
+	}
+
+	// origin/main was last updated long ago.
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setRemoteRef(t, repo, "origin/main", "HEAD", old)
+	if got := LastFetched(context.Background(), repo, "origin/main"); !got.Equal(old) {
+		t.Errorf("LastFetched(origin/main) = %v, want %v", got, old)
+	}
+
+	// Fetching another ref, or origin/main unchanged, does not refresh it.
+	run(t, repo, "git", "push", "-q", "origin", "main:other")
+	run(t, repo, "git", "fetch", "-q", "origin", "other", "main")
+	if got := LastFetched(context.Background(), repo, "origin/main"); !got.Equal(old) {
+		t.Errorf("LastFetched(origin/main) after fetching other refs = %v, want %v", got, old)
+	}
+
+	// A fetch that updates origin/main does.
+	clone := filepath.Join(t.TempDir(), "clone")
+	run(t, repo, "git", "clone", "-q", filepath.Join(filepath.Dir(repo), "origin.git"), clone)
+	run(t, clone, "git", "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "next")
+	run(t, clone, "git", "push", "-q", "origin", "main")
+	run(t, repo, "git", "fetch", "-q", "origin", "main")
+	if got := LastFetched(context.Background(), repo, "origin/main"); time.Since(got) > time.Minute {
+		t.Errorf("LastFetched(origin/main) after a fetch = %v", got)
+	}
+	if !RefExists(context.Background(), repo, "origin/main") {
+		t.Error("RefExists(origin/main) = false")
+	}
+	if got, err := Remotes(context.Background(), repo); err != nil || len(got) != 1 || got[0] != "origin" {
+		t.Errorf("Remotes() = %q, %v", got, err)
+	}
+}
+
+// setRemoteRef points the remote-tracking ref at rev, logging the update at
+// time at.
+func setRemoteRef(t *testing.T, repo, ref, rev string, at time.Time) {
+	t.Helper()
+	run(t, repo, "git", "update-ref", "-d", "refs/remotes/"+ref)
+	cmd := exec.Command("git", "-C", repo, "update-ref", "--create-reflog", "-m", "fetch", "refs/remotes/"+ref, rev)
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+at.Format(time.RFC3339))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git update-ref: %v\n%s", err, out)
+	}
+}
+
+func TestRemotes_Canceled(t *testing.T) {
+	repo := setupRepo(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Remotes(ctx, repo); err == nil {
+		t.Error("Remotes() with a canceled context succeeded")
+	}
+	if RefExists(ctx, repo, "main") {
+		t.Error("RefExists() with a canceled context = true")
+	}
+}
@@ -0,0 +1,394 @@
+package worktree
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// baseConfigKey is the per-branch git config key under which aw records the
+// base ref a worktree was created from (branch.<name>.aw-base).
+const baseConfigKey = "aw-base"
+
+// forkConfigKey is the per-branch git config key under which aw records the
+// commit where the branch left its base at creation (branch.<name>.aw-fork).
+const forkConfigKey = "aw-fork"
+
+// Worktree describes a git worktree created by aw.
+type Worktree struct {
+	Path   string
+	Branch string // empty if HEAD is detached
+	Base   string // base ref recorded at creation, or the configured default
+}
+
+// Name returns the worktree's directory name.
+func (w Worktree) Name() string {
+	return filepath.Base(w.Path)
+}
+
+// Status summarizes a worktree relative to its base ref.
+type Status struct {
+	Dirty    bool // uncommitted or untracked changes
+	Ahead    int  // commits on the branch not in base
+	Behind   int  // commits in base not on the branch
+	Unpushed int  // commits on the branch not in base nor on any remote
+	Merged   bool // branch has commits of its own, all of them in base (also squashed or rebased)
+}
+
+// RepoRoot returns the top-level directory of the current git repository.
+func RepoRoot(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveDir returns the absolute path of the directory under which worktrees
+// are created. An empty dir defaults to <repoRoot>/worktrees; ~ is expanded and
+// relative paths are resolved against repoRoot.
+func ResolveDir(dir, repoRoot string) (string, error) {
+	if dir == "" {
+		return filepath.Join(repoRoot, "worktrees"), nil
+	}
+
+	if strings.HasPrefix(dir, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expanding ~ in worktree dir: %w", err)
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoRoot, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// RecordBase stores the base ref of branch in the repository's git config so
+// that later status checks compare against the right ref. It also stores
+// where branch leaves base, so that a branch nobody has committed to is not
+// taken for a merged one.
+func RecordBase(ctx context.Context, repoRoot, branch, base string) error {
+	if err := git(ctx, repoRoot, "config", "branch."+branch+"."+baseConfigKey, base).Run(); err != nil {
+		return err
+	}
+	out, err := git(ctx, repoRoot, "merge-base", branch, base).Output()
+	if err != nil {
+		return fmt.Errorf("finding where %s leaves %s: %w", branch, base, err)
+	}
+	return git(ctx, repoRoot, "config", "branch."+branch+"."+forkConfigKey, strings.TrimSpace(string(out))).Run()
+}
+
+// List returns the worktrees of repoRoot that live directly under one of the
+// given directories. dirs maps each worktrees directory to the base ref to
+// assume for worktrees that have no recorded base.
+func List(ctx context.Context, repoRoot string, dirs map[string]string) ([]Worktree, error) {
+	out, err := git(ctx, repoRoot, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %w", err)
+	}
+
+	resolved := make(map[string]string, len(dirs))
+	for dir, base := range dirs {
+		resolved[canonicalPath(dir)] = base
+	}
+
+	var result []Worktree
+	for _, entry := range parsePorcelain(string(out)) {
+		parent := canonicalPath(filepath.Dir(entry.Path))
+		fallback, ok := resolved[parent]
+		if !ok {
+			continue
+		}
+		entry.Base = fallback
+		if entry.Branch != "" {
+			if recorded := recordedBase(ctx, repoRoot, entry.Branch); recorded != "" {
+				entry.Base = recorded
+			}
+		}
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
+// Find returns the worktree in wts whose directory name, branch or path
+// matches ref.
+func Find(wts []Worktree, ref string) (Worktree, error) {
+	for _, w := range wts {
+		if w.Name() == ref || w.Branch == ref || w.Path == ref {
+			return w, nil
+		}
+	}
+	if abs, err := filepath.Abs(ref); err == nil {
+		for _, w := range wts {
+			if canonicalPath(w.Path) == canonicalPath(abs) {
+				return w, nil
+			}
+		}
+	}
+	return Worktree{}, fmt.Errorf("worktree %q not found", ref)
+}
+
+// LastUsed estimates when w was last worked in: the latest modification time
+// of its directory, its index and its HEAD. It is zero if none can be read.
+func LastUsed(w Worktree) time.Time {
+	paths := []string{w.Path}
+	if gitDir := gitDirOf(w.Path); gitDir != "" {
+		paths = append(paths,
+			filepath.Join(gitDir, "index"),
+			filepath.Join(gitDir, "HEAD"),
+			filepath.Join(gitDir, "logs", "HEAD"))
+	}
+	var latest time.Time
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// SortByLastUsed orders wts from the most to the least recently used.
+func SortByLastUsed(wts []Worktree) {
+	used := make(map[string]time.Time, len(wts))
+	for _, w := range wts {
+		used[w.Path] = LastUsed(w)
+	}
+	sort.SliceStable(wts, func(i, j int) bool { return used[wts[i].Path].After(used[wts[j].Path]) })
+}
+
+// GetStatus computes the status of w relative to its base ref.
+func GetStatus(ctx context.Context, repoRoot string, w Worktree) (Status, error) {
+	var st Status
+
+	out, err := git(ctx, w.Path, "status", "--porcelain").Output()
+	if err != nil {
+		return st, fmt.Errorf("checking status of %s: %w", w.Path, err)
+	}
+	st.Dirty = strings.TrimSpace(string(out)) != ""
+
+	if w.Branch == "" || w.Base == "" {
+		return st, nil
+	}
+
+	out, err = git(ctx, repoRoot, "rev-list", "--left-right", "--count", w.Base+"..."+w.Branch).Output()
+	if err != nil {
+		return st, fmt.Errorf("comparing %s with %s: %w", w.Branch, w.Base, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 2 {
+		st.Behind, _ = strconv.Atoi(fields[0])
+		st.Ahead, _ = strconv.Atoi(fields[1])
+	}
+	if st.Ahead == 0 {
+		st.Merged = hasOwnCommits(ctx, repoRoot, w.Branch)
+	} else {
+		st.Merged = mergedAsCopies(ctx, repoRoot, w.Branch, w.Base)
+	}
+
+	out, err = git(ctx, repoRoot, "rev-list", "--count", w.Branch, "--not", w.Base, "--remotes").Output()
+	if err != nil {
+		return st, fmt.Errorf("counting unpushed commits on %s: %w", w.Branch, err)
+	}
+	st.Unpushed, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+
+	return st, nil
+}
+
+// Remove deletes the worktree and its branch. Unless force is set, it refuses
+// to remove a worktree with uncommitted changes or with unpushed commits
+// that are not merged. With
+// force, it also cleans up after an interrupted `git worktree add`: a
+// directory git has not made a worktree yet is deleted, and a missing
+// directory or branch is not an error.
+func Remove(ctx context.Context, repoRoot string, w Worktree, force bool) error {
+	if !force {
+		st, err := GetStatus(ctx, repoRoot, w)
+		if err != nil {
+			return err
+		}
+		if st.Dirty {
+			return fmt.Errorf("worktree %s has uncommitted changes (use --force to remove anyway)", w.Name())
+		}
+		// The commits of a squash- or rebase-merged branch are in base as copies.
+		if st.Unpushed > 0 && !st.Merged {
+			return fmt.Errorf("branch %s has %d unpushed commit(s) (use --force to remove anyway)", w.Branch, st.Unpushed)
+		}
+	}
+
+	// git refuses to remove a worktree with checked-out submodules unless
+	// forced; the checks above stand in for its own.
+	args := []string{"worktree", "remove"}
+	if force || hasSubmodules(w.Path) {
+		args = append(args, "--force")
+	}
+	args = append(args, w.Path)
+	if _, err := os.Lstat(filepath.Join(w.Path, ".git")); err != nil && force {
+		if err := os.RemoveAll(w.Path); err != nil {
+			return fmt.Errorf("removing worktree %s: %w", w.Path, err)
+		}
+		_ = git(ctx, repoRoot, "worktree", "prune").Run()
+	} else if out, err := git(ctx, repoRoot, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("removing worktree %s: %s", w.Path, strings.TrimSpace(string(out)))
+	}
+
+	if w.Branch != "" && (!force || BranchExists(ctx, repoRoot, w.Branch)) {
+		if out, err := git(ctx, repoRoot, "branch", "-D", w.Branch).CombinedOutput(); err != nil {
+			return fmt.Errorf("deleting branch %s: %s", w.Branch, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+func recordedBase(ctx context.Context, repoRoot, branch string) string {
+	return branchConfig(ctx, repoRoot, branch, baseConfigKey)
+}
+
+func branchConfig(ctx context.Context, repoRoot, branch, key string) string {
+	out, err := git(ctx, repoRoot, "config", "--get", "branch."+branch+"."+key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// hasOwnCommits reports whether branch has moved on from where it left its
+// base, i.e. whether it is more than a fresh branch nobody committed to.
+// Without a recorded fork point, the commit the branch was created at is
+// taken from its reflog; if that is gone too, the branch is assumed to
+// have none.
+func hasOwnCommits(ctx context.Context, repoRoot, branch string) bool {
+	tip, err := git(ctx, repoRoot, "rev-parse", "--verify", "-q", "refs/heads/"+branch).Output()
+	if err != nil {
+		return false
+	}
+	fork := branchConfig(ctx, repoRoot, branch, forkConfigKey)
+	if fork == "" {
+		out, err := git(ctx, repoRoot, "reflog", "show", "--format=%H", "refs/heads/"+branch, "--").Output()
+		if err != nil {
+			return false
+		}
+		lines := strings.Fields(string(out))
+		if len(lines) == 0 {
+			return false
+		}
+		fork = lines[len(lines)-1]
+	}
+	return strings.TrimSpace(string(tip)) != fork
+}
+
+// mergedAsCopies reports whether the commits of branch that are not in base
+// were applied to base as copies: one by one, as a rebase merge does, or
+// all together, as a squash merge does.
+func mergedAsCopies(ctx context.Context, repoRoot, branch, base string) bool {
+	if out, err := git(ctx, repoRoot, "cherry", base, branch).Output(); err == nil && allApplied(string(out)) {
+		return true
+	}
+
+	// Squash the branch onto where it leaves base and look for that commit.
+	fork, err := git(ctx, repoRoot, "merge-base", base, branch).Output()
+	if err != nil {
+		return false
+	}
+	tree, err := git(ctx, repoRoot, "rev-parse", branch+"^{tree}").Output()
+	if err != nil {
+		return false
+	}
+	cmd := git(ctx, repoRoot, "commit-tree", strings.TrimSpace(string(tree)), "-p", strings.TrimSpace(string(fork)), "-m", "squash")
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=aw", "GIT_AUTHOR_EMAIL=aw@localhost",
+		"GIT_COMMITTER_NAME=aw", "GIT_COMMITTER_EMAIL=aw@localhost")
+	squash, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	out, err := git(ctx, repoRoot, "cherry", base, strings.TrimSpace(string(squash))).Output()
+	return err == nil && allApplied(string(out))
+}
+
+// allApplied reports whether every commit listed by `git cherry` has an
+// equivalent in upstream.
+func allApplied(cherry string) bool {
+	lines := strings.Fields(cherry)
+	if len(lines) == 0 {
+		return false
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePorcelain parses the output of `git worktree list --porcelain`.
+func parsePorcelain(out string) []Worktree {
+	var result []Worktree
+	var cur *Worktree
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			if cur != nil {
+				result = append(result, *cur)
+			}
+			cur = &Worktree{Path: strings.TrimPrefix(line, "worktree ")}
+		case strings.HasPrefix(line, "branch ") && cur != nil:
+			cur.Branch = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+		}
+	}
+	if cur != nil {
+		result = append(result, *cur)
+	}
+	return result
+}
+
+// gitDirOf returns the private git directory of the worktree at path, read
+// from its .git file, or "" if path is not a linked worktree.
+func gitDirOf(path string) string {
+	data, err := os.ReadFile(filepath.Join(path, ".git"))
+	if err != nil {
+		return ""
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return ""
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path, dir)
+	}
+	return dir
+}
+
+// hasSubmodules reports whether the worktree at path has checked-out
+// submodules, whose git directories git keeps under its private git
+// directory.
+func hasSubmodules(path string) bool {
+	dir := gitDirOf(path)
+	return dir != "" && exists(filepath.Join(dir, "modules"))
+}
+
+// canonicalPath resolves symlinks where possible so that paths reported by
+// git compare equal to configured ones (e.g. /tmp vs /private/tmp on macOS).
+func canonicalPath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return filepath.Clean(p)
+}
+
+func git(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+}
@@ -0,0 +1,417 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupRepo creates a repository with one commit on main, pushed to a bare
+// "origin" remote. It returns the repository root.
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	tmp := t.TempDir()
+	origin := filepath.Join(tmp, "origin.git")
+	repo := filepath.Join(tmp, "repo")
+
+	run(t, tmp, "git", "init", "-q", "--bare", "-b", "main", origin)
+	run(t, tmp, "git", "init", "-q", "-b", "main", repo)
+	run(t, repo, "git", "config", "user.email", "test@example.com")
+	run(t, repo, "git", "config", "user.name", "test")
+	writeFile(t, filepath.Join(repo, "README"), "hello\n")
+	run(t, repo, "git", "add", ".")
+	run(t, repo, "git", "commit", "-q", "-m", "initial")
+	run(t, repo, "git", "remote", "add", "origin", origin)
+	run(t, repo, "git", "push", "-q", "origin", "main")
+	return repo
+}
+
+func run(t *testing.T, dir string, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %v: %v\n%s", name, args, err, out)
+	}
+	return string(out)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addWorktree creates an aw-style worktree under <repo>/worktrees.
+func addWorktree(t *testing.T, repo, name string) string {
+	t.Helper()
+	path := filepath.Join(repo, "worktrees", name)
+	run(t, repo, "git", "worktree", "add", "-q", "-b", name, path, "origin/main")
+	run(t, path, "git", "config", "user.email", "test@example.com")
+	run(t, path, "git", "config", "user.name", "test")
+	if err := RecordBase(context.Background(), repo, name, "origin/main"); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveDir(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{"", "/repo/worktrees"},
+		{"/abs/wt", "/abs/wt"},
+		{"../shared", "/shared"},
+		{"~/aw-wt", filepath.Join(home, "aw-wt")},
+	}
+	for _, tt := range tests {
+		got, err := ResolveDir(tt.dir, "/repo")
+		if err != nil {
+			t.Fatalf("ResolveDir(%q) error: %v", tt.dir, err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveDir(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestParsePorcelain(t *testing.T) {
+	out := `worktree /repo
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /repo/worktrees/calm-otter-dawn
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/calm-otter-dawn
+
+worktree /repo/worktrees/detached
+HEAD 3333333333333333333333333333333333333333
+detached
+`
+	got := parsePorcelain(out)
+	if len(got) != 3 {
+		t.Fatalf("got %d worktrees, want 3", len(got))
+	}
+	if got[1].Path != "/repo/worktrees/calm-otter-dawn" || got[1].Branch != "calm-otter-dawn" {
+		t.Errorf("got[1] = %+v", got[1])
+	}
+	if got[2].Branch != "" {
+		t.Errorf("detached worktree should have empty branch, got %q", got[2].Branch)
+	}
+}
+
+func TestList_OnlyAwWorktrees(t *testing.T) {
+	repo := setupRepo(t)
+	addWorktree(t, repo, "calm-otter-dawn")
+	run(t, repo, "git", "worktree", "add", "-q", "-b", "elsewhere", filepath.Join(filepath.Dir(repo), "elsewhere"), "main")
+
+	wts, err := List(context.Background(), repo, map[string]string{filepath.Join(repo, "worktrees"): "origin/develop"})
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(wts) != 1 {
+		t.Fatalf("List() returned %d worktrees, want 1: %+v", len(wts), wts)
+	}
+	if wts[0].Branch != "calm-otter-dawn" || wts[0].Name() != "calm-otter-dawn" {
+		t.Errorf("List()[0] = %+v", wts[0])
+	}
+	if wts[0].Base != "origin/main" {
+		t.Errorf("Base = %q, want recorded base %q", wts[0].Base, "origin/main")
+	}
+}
+
+func TestList_FallbackBase(t *testing.T) {
+	repo := setupRepo(t)
+	path := filepath.Join(repo, "worktrees", "old-one")
+	run(t, repo, "git", "worktree", "add", "-q", "-b", "old-one", path, "origin/main")
+
+	wts, err := List(context.Background(), repo, map[string]string{filepath.Join(repo, "worktrees"): "origin/main"})
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(wts) != 1 || wts[0].Base != "origin/main" {
+		t.Errorf("List() = %+v, want fallback base origin/main", wts)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	repo := setupRepo(t)
+	path := addWorktree(t, repo, "busy-bee")
+	wt := Worktree{Path: path, Branch: "busy-bee", Base: "origin/main"}
+
+	st, err := GetStatus(context.Background(), repo, wt)
+	if err != nil {
+		t.Fatalf("GetStatus() error: %v", err)
+	}
+	if st.Dirty || st.Ahead != 0 || st.Behind != 0 || st.Merged {
+		t.Errorf("fresh worktree status = %+v, want clean and not merged", st)
+	}
+
+	writeFile(t, filepath.Join(path, "new.txt"), "x\n")
+	st, err = GetStatus(context.Background(), repo, wt)
+	if err != nil {
+		t.Fatalf("GetStatus() error: %v", err)
+	}
+	if !st.Dirty {
+		t.Error("worktree with untracked file should be dirty")
+	}
+
+	run(t, path, "git", "add", ".")
+	run(t, path, "git", "commit", "-q", "-m", "work")
+	st, err = GetStatus(context.Background(), repo, wt)
+	if err != nil {
+		t.Fatalf("GetStatus() error: %v", err)
+	}
+	if st.Dirty || st.Ahead != 1 || st.Unpushed != 1 || st.Merged {
+		t.Errorf("status after commit = %+v, want ahead=1 unpushed=1 not merged", st)
+	}
+
+	run(t, path, "git", "push", "-q", "origin", "busy-bee")
+	st, err = GetStatus(context.Background(), repo, wt)
+	if err != nil {
+		t.Fatalf("GetStatus() error: %v", err)
+	}
+	if st.Unpushed != 0 {
+		t.Errorf("Unpushed = %d after push, want 0", st.Unpushed)
+	}
+}
+
+// commitOnMain commits a file on main in repo and pushes it to origin.
+func commitOnMain(t *testing.T, repo, name, content string) {
+	t.Helper()
+	writeFile(t, filepath.Join(repo, name), content)
+	run(t, repo, "git", "add", ".")
+	run(t, repo, "git", "commit", "-q", "-m", "add "+name)
+	run(t, repo, "git", "push", "-q", "origin", "main")
+}
+
+func TestGetStatus_Merged(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, repo, path string)
+		merged bool
+	}{
+		{
+			name: "created but untouched",
+			setup: func(t *testing.T, repo, path string) {
+				commitOnMain(t, repo, "other.txt", "x\n")
+			},
+		},
+		{
+			name: "created but untouched, no recorded fork point",
+			setup: func(t *testing.T, repo, path string) {
+				run(t, repo, "git", "config", "--unset", "branch.wt."+forkConfigKey)
+				commitOnMain(t, repo, "other.txt", "x\n")
+			},
+		},
+		{
+			name: "fast-forwarded into base",
+			setup: func(t *testing.T, repo, path string) {
+				writeFile(t, filepath.Join(path, "work.txt"), "x\n")
+				run(t, path, "git", "add", ".")
+				run(t, path, "git", "commit", "-q", "-m", "work")
+				run(t, path, "git", "push", "-q", "origin", "wt:main")
+			},
+			merged: true,
+		},
+		{
+			name: "squash-merged into base",
+			setup: func(t *testing.T, repo, path string) {
+				for _, f := range []string{"a.txt", "b.txt"} {
+					writeFile(t, filepath.Join(path, f), f+"\n")
+					run(t, path, "git", "add", ".")
+					run(t, path, "git", "commit", "-q", "-m", "add "+f)
+				}
+				commitOnMain(t, repo, "other.txt", "x\n")
+				run(t, repo, "git", "merge", "-q", "--squash", "wt")
+				run(t, repo, "git", "commit", "-q", "-m", "squashed")
+				run(t, repo, "git", "push", "-q", "origin", "main")
+			},
+			merged: true,
+		},
+		{
+			name: "with commits not in base",
+			setup: func(t *testing.T, repo, path string) {
+				writeFile(t, filepath.Join(path, "work.txt"), "x\n")
+				run(t, path, "git", "add", ".")
+				run(t, path, "git", "commit", "-q", "-m", "work")
+				commitOnMain(t, repo, "work.txt", "other\n")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupRepo(t)
+			path := addWorktree(t, repo, "wt")
+			tt.setup(t, repo, path)
+			run(t, repo, "git", "fetch", "-q", "origin")
+
+			st, err := GetStatus(context.Background(), repo, Worktree{Path: path, Branch: "wt", Base: "origin/main"})
+			if err != nil {
+				t.Fatalf("GetStatus() error: %v", err)
+			}
+			if st.Merged != tt.merged {
+				t.Errorf("Merged = %v, want %v (status %+v)", st.Merged, tt.merged, st)
+			}
+		})
+	}
+}
+
+func TestRemove_GuardsUnpushedCommits(t *testing.T) {
+	repo := setupRepo(t)
+	path := addWorktree(t, repo, "keep-me")
+	writeFile(t, filepath.Join(path, "work.txt"), "x\n")
+	run(t, path, "git", "add", ".")
+	run(t, path, "git", "commit", "-q", "-m", "work")
+	wt := Worktree{Path: path, Branch: "keep-me", Base: "origin/main"}
+
+	err := Remove(context.Background(), repo, wt, false)
+	if err == nil || !strings.Contains(err.Error(), "unpushed") {
+		t.Fatalf("Remove() error = %v, want unpushed guard", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("worktree should still exist after refused removal")
+	}
+
+	if err := Remove(context.Background(), repo, wt, true); err != nil {
+		t.Fatalf("Remove(force) error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("worktree directory should be removed")
+	}
+	if out := run(t, repo, "git", "branch", "--list", "keep-me"); strings.TrimSpace(out) != "" {
+		t.Errorf("branch should be deleted, got %q", out)
+	}
+}
+
+func TestRemove_SquashMergedBranch(t *testing.T) {
+	repo := setupRepo(t)
+	path := addWorktree(t, repo, "done")
+	writeFile(t, filepath.Join(path, "work.txt"), "x\n")
+	run(t, path, "git", "add", ".")
+	run(t, path, "git", "commit", "-q", "-m", "work")
+	run(t, repo, "git", "merge", "-q", "--squash", "done")
+	run(t, repo, "git", "commit", "-q", "-m", "squashed")
+	run(t, repo, "git", "push", "-q", "origin", "main")
+	run(t, repo, "git", "fetch", "-q", "origin")
+
+	if err := Remove(context.Background(), repo, Worktree{Path: path, Branch: "done", Base: "origin/main"}, false); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if BranchExists(context.Background(), repo, "done") {
+		t.Error("branch done should be deleted")
+	}
+}
+
+func TestRemove_GuardsDirtyWorktree(t *testing.T) {
+	repo := setupRepo(t)
+	path := addWorktree(t, repo, "dirty-one")
+	writeFile(t, filepath.Join(path, "scratch.txt"), "x\n")
+
+	err := Remove(context.Background(), repo, Worktree{Path: path, Branch: "dirty-one", Base: "origin/main"}, false)
+	if err == nil || !strings.Contains(err.Error(), "uncommitted") {
+		t.Fatalf("Remove() error = %v, want uncommitted guard", err)
+	}
+}
+
+func TestRemove_WorktreeWithSubmodules(t *testing.T) {
+	repo := setupRepo(t)
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+	path := addWorktree(t, repo, "with-lib")
+	run(t, path, "git", "submodule", "add", "-q", filepath.Join(filepath.Dir(repo), "origin.git"), "lib")
+	run(t, path, "git", "commit", "-q", "-m", "add lib")
+	run(t, path, "git", "push", "-q", "origin", "with-lib")
+
+	// git itself refuses without --force once submodules are checked out.
+	if err := Remove(context.Background(), repo, Worktree{Path: path, Branch: "with-lib", Base: "origin/main"}, false); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("worktree directory should be removed")
+	}
+}
+
+func TestRemove_ForcePartialWorktree(t *testing.T) {
+	repo := setupRepo(t)
+
+	// Nothing was created.
+	if err := Remove(context.Background(), repo, Worktree{Path: filepath.Join(repo, "worktrees", "none"), Branch: "none"}, true); err != nil {
+		t.Errorf("Remove(force) of a missing worktree error: %v", err)
+	}
+
+	// Only the branch and an empty directory were created.
+	path := filepath.Join(repo, "worktrees", "half")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run(t, repo, "git", "branch", "half")
+	if err := Remove(context.Background(), repo, Worktree{Path: path, Branch: "half"}, true); err != nil {
+		t.Fatalf("Remove(force) error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("worktree directory should be removed")
+	}
+	if BranchExists(context.Background(), repo, "half") {
+		t.Error("branch should be deleted")
+	}
+}
+
+func TestFind(t *testing.T) {
+	wts := []Worktree{
+		{Path: "/repo/worktrees/calm-otter-dawn", Branch: "calm-otter-dawn"},
+		{Path: "/repo/worktrees/x", Branch: "feature/y"},
+	}
+	for _, ref := range []string{"calm-otter-dawn", "/repo/worktrees/calm-otter-dawn"} {
+		if got, err := Find(wts, ref); err != nil || got.Branch != "calm-otter-dawn" {
+			t.Errorf("Find(%q) = %+v, %v", ref, got, err)
+		}
+	}
+	if got, err := Find(wts, "feature/y"); err != nil || got.Name() != "x" {
+		t.Errorf("Find(feature/y) = %+v, %v", got, err)
+	}
+	if _, err := Find(wts, "nope"); err == nil {
+		t.Error("Find(nope) should error")
+	}
+}
+
+func TestSortByLastUsed(t *testing.T) {
+	repo := setupRepo(t)
+	old := addWorktree(t, repo, "old")
+	recent := addWorktree(t, repo, "recent")
+
+	// Age everything "old" touches, then commit in "recent".
+	past := time.Now().Add(-24 * time.Hour)
+	gitDir := gitDirOf(old)
+	for _, p := range []string{old, filepath.Join(gitDir, "index"), filepath.Join(gitDir, "HEAD"), filepath.Join(gitDir, "logs", "HEAD")} {
+		if err := os.Chtimes(p, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(recent, "NEW"), "x\n")
+	run(t, recent, "git", "add", ".")
+	run(t, recent, "git", "commit", "-q", "-m", "work")
+
+	if got := LastUsed(Worktree{Path: old}); got.After(past.Add(time.Second)) {
+		t.Errorf("LastUsed(old) = %v, want about %v", got, past)
+	}
+
+	wts := []Worktree{{Path: old}, {Path: recent}}
+	SortByLastUsed(wts)
+	if wts[0].Path != recent || wts[1].Path != old {
+		t.Errorf("SortByLastUsed = %v, want recent first", wts)
+	}
+}
@@ -2,45 +2,72 @@ package worktree
 
 import (
 	"bufio"
-	"fmt"
+	_ "embed"
 	"math/rand"
 	"os"
 	"strings"
 )
 
+// DictPath is the system word list GenerateName picks words from.
+const DictPath = "/usr/share/dict/words"
+
 const (
-	dictPath   = "/usr/share/dict/words"
 	maxWordLen = 6
 	wordCount  = 3
 )
 
+// fallbackWords is used when the system dictionary is missing or too small,
+// as on minimal Linux installs and CI images.
+//
+//go:embed embed/words.txt
+var fallbackWords string
+
 // GenerateName creates a random branch name by picking 3 short words
-// from the system dictionary, joined by hyphens.
-func GenerateName() (string, error) {
-	f, err := os.Open(dictPath)
+// from the system dictionary, or from a built-in list without one, joined
+// by hyphens.
+func GenerateName() string {
+	words := dictWords(DictPath)
+	if len(words) < wordCount {
+		words = strings.Fields(fallbackWords)
+	}
+
+	picked := make([]string, wordCount)
+	for i := 0; i < wordCount; i++ {
+		picked[i] = words[rand.Intn(len(words))]
+	}
+	return strings.Join(picked, "-")
+}
+
+// dictWords returns the short, purely alphabetic words of the dictionary at
+// path, lowercased, or nil if it cannot be read.
+func dictWords(path string) []string {
+	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("opening dictionary %s: %w", dictPath, err)
+		return nil
 	}
 	defer func() { _ = f.Close() }()
 
 	var words []string
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		w := strings.TrimSpace(scanner.Text())
-		if len(w) > 0 && len(w) <= maxWordLen {
-			words = append(words, strings.ToLower(w))
+		w := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if len(w) > 0 && len(w) <= maxWordLen && isLetters(w) {
+			words = append(words, w)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("reading dictionary: %w", err)
-	}
-	if len(words) < wordCount {
-		return "", fmt.Errorf("not enough words in dictionary (found %d, need %d)", len(words), wordCount)
+	if scanner.Err() != nil {
+		return nil
 	}
+	return words
+}
 
-	picked := make([]string, wordCount)
-	for i := 0; i < wordCount; i++ {
-		picked[i] = words[rand.Intn(len(words))]
+// isLetters reports whether s consists of ASCII letters only, so that names
+// are valid branch names (dictionaries list words such as "o'clock").
+func isLetters(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
 	}
-	return strings.Join(picked, "-"), nil
+	return true
 }
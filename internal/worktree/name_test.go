@@ -1,15 +1,14 @@
 package worktree
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 func TestGenerateName(t *testing.T) {
-	name, err := GenerateName()
-	if err != nil {
-		t.Skipf("Skipping on systems without %s: %v", dictPath, err)
-	}
+	name := GenerateName()
 	parts := strings.Split(name, "-")
 	if len(parts) != 3 {
 		t.Errorf("expected 3 words joined by hyphens, got %q (%d parts)", name, len(parts))
@@ -18,19 +17,40 @@ func TestGenerateName(t *testing.T) {
 		if len(p) == 0 || len(p) > maxWordLen {
 			t.Errorf("word %q has invalid length (expected 1-%d)", p, maxWordLen)
 		}
-		if p != strings.ToLower(p) {
-			t.Errorf("word %q should be lowercase", p)
+		if !isLetters(p) {
+			t.Errorf("word %q should be lowercase letters", p)
 		}
 	}
 }
 
 func TestGenerateName_Uniqueness(t *testing.T) {
-	name1, err1 := GenerateName()
-	name2, err2 := GenerateName()
-	if err1 != nil || err2 != nil {
-		t.Skipf("Skipping on systems without dictionary")
-	}
+	name1, name2 := GenerateName(), GenerateName()
 	if name1 == name2 {
 		t.Logf("Warning: same name generated twice: %q (statistically unlikely but possible)", name1)
 	}
 }
+
+func TestDictWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words")
+	if err := os.WriteFile(path, []byte("Apple\no'clock\nbanana\nlengthy\nfig\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(dictWords(path), ","); got != "apple,banana,fig" {
+		t.Errorf("dictWords = %q, want apple,banana,fig", got)
+	}
+	if got := dictWords(filepath.Join(t.TempDir(), "missing")); got != nil {
+		t.Errorf("dictWords(missing) = %v, want nil", got)
+	}
+}
+
+func TestFallbackWords(t *testing.T) {
+	words := strings.Fields(fallbackWords)
+	if len(words) < 100 {
+		t.Fatalf("built-in word list has %d words", len(words))
+	}
+	for _, w := range words {
+		if len(w) > maxWordLen || !isLetters(w) {
+			t.Errorf("built-in word %q is not a short lowercase word", w)
+		}
+	}
+}